@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// globMeta defines characters of glob patterns
+const globMeta = "*?["
+
+// expandArchives returns paths of archives matching the glob pattern.
+// Path without glob characters, stdin and URL are returned as is
+func expandArchives(pattern string) ([]string, error) {
+	if pattern == "-" || isURL(pattern) || !strings.ContainsAny(pattern, globMeta) {
+		return []string{pattern}, nil
+	}
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %s: %v", pattern, err)
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no archives match %s", pattern)
+	}
+	return paths, nil
+}
+
+// ArchiveTotal defines totals of the archive analyzed in the batch
+type ArchiveTotal struct {
+	Archive string `json:"archive"`
+	Size    uint64 `json:"size"`
+	Layers  int    `json:"layers"`
+	Files   int    `json:"files"`
+}
+
+// ArchiveResult defines the report of the archive of the batch
+type ArchiveResult struct {
+	Archive string          `json:"archive"`
+	Report  json.RawMessage `json:"report"`
+}
+
+// BatchReport defines JSON output of archives analyzed in the batch
+type BatchReport struct {
+	Archives []ArchiveResult `json:"archives"`
+	// Totals are set with -batch-summary
+	Totals []ArchiveTotal `json:"totals,omitempty"`
+}
+
+// newArchiveTotal returns totals of the archive by its summary
+func newArchiveTotal(path string, s Summary) ArchiveTotal {
+	return ArchiveTotal{Archive: path, Size: s.Size, Layers: s.Layers, Files: s.Files}
+}
+
+// sortTotals provides sorting of archives by size in descending order
+func sortTotals(totals []ArchiveTotal) {
+	sort.SliceStable(totals, func(i, j int) bool {
+		return totals[i].Size > totals[j].Size
+	})
+}
+
+// printTotals provides human-readable output of totals of archives
+func printTotals(w io.Writer, totals []ArchiveTotal, opts TextOptions) {
+	opts.separator(w)
+	fmt.Fprintln(w, theme.Header.Sprintf("%s%s\t layers\t files\t archive", opts.prefix(), pad("size", humanizedWidth)))
+	opts.separator(w)
+	var total uint64
+	for _, t := range totals {
+		fmt.Fprintf(w, "%s\t %6d\t %5d\t %s\n", humanizeBytes(t.Size), t.Layers, t.Files, t.Archive)
+		total += t.Size
+	}
+	opts.separator(w)
+	fmt.Fprintln(w, theme.Header.Sprintf("%s%s\t total: %d archives", opts.prefix(), humanizeBytes(total), len(totals)))
+}
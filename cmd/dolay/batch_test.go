@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExpandArchives(t *testing.T) {
+	dir := t.TempDir()
+	small := writeArchive(t, dir, "small.tar", nil)
+	big := writeArchive(t, dir, "big.tar", nil)
+	writeArchive(t, dir, "notes.txt", nil)
+	tests := []struct {
+		pattern string
+		want    []string
+		err     string
+	}{
+		{filepath.Join(dir, "*.tar"), []string{big, small}, ""},
+		{filepath.Join(dir, "s?all.tar"), []string{small}, ""},
+		{filepath.Join(dir, "missing.tar"), []string{filepath.Join(dir, "missing.tar")}, ""},
+		{"-", []string{"-"}, ""},
+		{"https://example.com/images/*.tar", []string{"https://example.com/images/*.tar"}, ""},
+		{filepath.Join(dir, "*.tar.gz"), nil, "no archives match"},
+		{filepath.Join(dir, "[.tar"), nil, "invalid pattern"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			got, err := expandArchives(tt.pattern)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("expandArchives() error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expandArchives() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expandArchives() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunBatch(t *testing.T) {
+	dir := t.TempDir()
+	small := writeArchive(t, dir, "alpine.tar", testArchive(t, testTar(t, testFile("bin/busybox", 900))))
+	big := writeArchive(t, dir, "debian.tar", testArchive(t,
+		testTar(t, testFile("bin/bash", 1200), testFile("usr/lib/libc.so", 2000)),
+		testTar(t, testFile("usr/bin/curl", 300))))
+	paths, err := expandArchives(filepath.Join(dir, "*.tar"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := testCLIFlags()
+	f.tarPath = filepath.Join(dir, "*.tar")
+	out, err := runAnalyzer(t, f, paths...)
+	if err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+	first, second := strings.Index(out, "archive: "+small), strings.Index(out, "archive: "+big)
+	if first < 0 || second < first || !strings.Contains(out[first:second], "busybox") || !strings.Contains(out[second:], "libc.so") {
+		t.Errorf("run() output isn't split by archives:\n%s", out)
+	}
+
+	f = testCLIFlags()
+	f.tarPath, f.output, f.batchSummary = filepath.Join(dir, "*.tar"), outputJSON, true
+	out, err = runAnalyzer(t, f, paths...)
+	if err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+	// the output is the single document of reports of archives and sorted totals
+	var batch struct {
+		Archives []struct {
+			Archive string
+			Report  []LayerReport
+		}
+		Totals []ArchiveTotal
+	}
+	if err := json.Unmarshal([]byte(out), &batch); err != nil {
+		t.Fatalf("output isn't a valid JSON document: %v\n%s", err, out)
+	}
+	if len(batch.Archives) != 2 || batch.Archives[0].Archive != small || batch.Archives[1].Archive != big {
+		t.Fatalf("archives = %+v, want %s and %s", batch.Archives, small, big)
+	}
+	if r := batch.Archives[1].Report; len(r) != 2 || r[0].Files[0].Name != "usr/lib/libc.so" {
+		t.Errorf("report of %s = %+v, want two layers", big, r)
+	}
+	totals := batch.Totals
+	want := []ArchiveTotal{
+		{Archive: big, Size: 3500, Layers: 2, Files: 3},
+		{Archive: small, Size: 900, Layers: 1, Files: 1},
+	}
+	if !reflect.DeepEqual(totals, want) {
+		t.Errorf("totals = %+v, want %+v", totals, want)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/saromanov/dolay"
+)
+
+// chartBar defines character of bars of the chart
+const chartBar = "#"
+
+// minBarWidth defines the least width of bars,
+// so they are still comparable on narrow terminals
+const minBarWidth = 10
+
+// barLength returns length of the bar of the size, which is scaled
+// to the width by the largest size. Non-empty layers get at least
+// one character, so they are not lost next to the large ones
+func barLength(size, largest uint64, width int) int {
+	if largest == 0 || size == 0 {
+		return 0
+	}
+	n := int((size*uint64(width) + largest/2) / largest)
+	if n == 0 {
+		return 1
+	}
+	return n
+}
+
+// printChart provides output of sizes of layers as a bar chart.
+// Bars take half of the line and the largest layer fills it
+func printChart(w io.Writer, layers []*dolay.Layer, opts TextOptions) {
+	var largest uint64
+	for _, l := range layers {
+		if l.Size > largest {
+			largest = l.Size
+		}
+	}
+	width := (opts.LineWidth - humanizedWidth - 2) / 2
+	if width < minBarWidth {
+		width = minBarWidth
+	}
+	cmdWidth := opts.commandWidth(humanizedWidth + width + 4)
+	opts.separator(w)
+	for _, l := range layers {
+		bar := strings.Repeat(chartBar, barLength(l.Size, largest, width))
+		cmd := dolay.Command(l.History)
+		if cmd == "" {
+			cmd = noCommand
+		}
+		fmt.Fprintf(w, "%s %s%s $ %s\n", humanizeBytes(l.Size),
+			theme.layer(l.Size).Sprint(bar), strings.Repeat(" ", width-len(bar)),
+			opts.truncate(singleLine(cmd), cmdWidth))
+	}
+	opts.separator(w)
+	summary := summarize(layers)
+	fmt.Fprintln(w, theme.Header.Sprintf("%s%s total: %d layers", opts.prefix(), humanizeBytes(summary.Size), summary.Layers))
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/saromanov/dolay"
+)
+
+func TestBarLength(t *testing.T) {
+	tests := []struct {
+		size, largest uint64
+		width         int
+		want          int
+	}{
+		{1000, 1000, 50, 50},
+		{500, 1000, 50, 25},
+		{250, 1000, 40, 10},
+		{333, 1000, 30, 10},
+		{1, 1000000, 50, 1},
+		{0, 1000, 50, 0},
+		{0, 0, 50, 0},
+	}
+	for _, tt := range tests {
+		if got := barLength(tt.size, tt.largest, tt.width); got != tt.want {
+			t.Errorf("barLength(%d, %d, %d) = %d, want %d", tt.size, tt.largest, tt.width, got, tt.want)
+		}
+	}
+}
+
+func TestPrintChart(t *testing.T) {
+	withoutColor(t)
+	sizes := []int64{4000, 2000, 1000, 1, 0}
+	var layers []*dolay.Layer
+	for i, size := range sizes {
+		l := testLayer(i, fmt.Sprintf("/bin/sh -c step %d", i))
+		if size > 0 {
+			l = testLayer(i, fmt.Sprintf("/bin/sh -c step %d", i), testFile("file", size))
+		}
+		layers = append(layers, l)
+	}
+	opts := testTextOptions()
+	// bars are 44 characters wide, so the halves are exact
+	opts.LineWidth = 97
+	var buf bytes.Buffer
+	printChart(&buf, layers, opts)
+	var bars []int
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if strings.Contains(line, " $ ") {
+			bars = append(bars, strings.Count(line, chartBar))
+		}
+	}
+	width := (opts.LineWidth - humanizedWidth - 2) / 2
+	want := []int{width, width / 2, width / 4, 1, 0}
+	if fmt.Sprint(bars) != fmt.Sprint(want) {
+		t.Errorf("printChart() bars = %v, want %v\n%s", bars, want, buf.String())
+	}
+	// bars are aligned, so commands start at the same column
+	var columns []int
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if i := strings.Index(line, " $ "); i >= 0 {
+			columns = append(columns, i)
+		}
+	}
+	for _, c := range columns {
+		if c != columns[0] {
+			t.Errorf("printChart() commands start at columns %v", columns)
+			break
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// configNames defines names of the config file, which is searched
+// in the current directory and then in the home directory
+var configNames = []string{".dolay.yaml", ".dolay.yml", ".dolay.toml"}
+
+// configFlag defines the flag of the config path
+const configFlag = "config"
+
+// configDenied defines flags which can't be defaults, since they start
+// other actions than the analysis. -watch re-runs dolay, and the child
+// would read the config and watch again
+var configDenied = map[string]bool{
+	configFlag:        true,
+	"watch":           true,
+	"tui":             true,
+	"schema":          true,
+	"s":               true,
+	"update-snapshot": true,
+}
+
+// findConfig returns path of the config. It's the path set by -config,
+// or the first found config of the current or home directory. Empty
+// path is returned if there is no config
+func findConfig(path string) string {
+	if path != "" {
+		return path
+	}
+	dirs := []string{"."}
+	if home, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs, home)
+	}
+	for _, dir := range dirs {
+		for _, name := range configNames {
+			path := filepath.Join(dir, name)
+			if _, err := os.Stat(path); err == nil {
+				return path
+			}
+		}
+	}
+	return ""
+}
+
+// loadConfig provides setting of flags by the config file. It's called
+// after the command line is parsed, and flags set by the command line
+// are skipped, so they override the config. Values of the list are set
+// in order, so repeatable flags contain all of them
+func loadConfig(fs *flag.FlagSet, path string) error {
+	if path == "" {
+		return nil
+	}
+	values, err := readConfig(path)
+	if err != nil {
+		return err
+	}
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	for _, v := range values {
+		switch {
+		case fs.Lookup(v.name) == nil:
+			return fmt.Errorf("%s:%d: unknown flag %s", path, v.line, v.name)
+		case configDenied[v.name]:
+			return fmt.Errorf("%s:%d: flag %s can't be set in config", path, v.line, v.name)
+		case set[v.name]:
+			continue
+		}
+		if err := fs.Set(v.name, v.value); err != nil {
+			return fmt.Errorf("%s:%d: invalid value of %s: %v", path, v.line, v.name, err)
+		}
+	}
+	return nil
+}
+
+// configValue defines value of the flag in the config
+type configValue struct {
+	name  string
+	value string
+	line  int
+}
+
+// readConfig returns values of flags of the config. Config is the flat
+// list of flag names with values, "name: value" lines for yaml or
+// "name = value" lines for toml. Lists like [a, b] (or "- a" lines
+// following "name:" for yaml) set repeatable flags several times, and
+// text after # is a comment. Nested values and tables are not supported
+func readConfig(path string) ([]configValue, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open config: %v", err)
+	}
+	defer f.Close()
+	toml := filepath.Ext(path) == ".toml"
+	sep, format := ":", "name: value"
+	if toml {
+		sep, format = "=", "name = value"
+	}
+	var values []configValue
+	// list is the yaml flag which values are "- value" lines
+	var list *configValue
+	items := 0
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		text := scanner.Text()
+		line := strings.TrimSpace(text)
+		if line == "" || strings.HasPrefix(line, "#") || (!toml && line == "---") {
+			continue
+		}
+		errorf := func(msg string, args ...interface{}) error {
+			return fmt.Errorf("%s:%d: %s", path, n, fmt.Sprintf(msg, args...))
+		}
+		if list != nil && strings.HasPrefix(line, "- ") {
+			value, err := configString(strings.TrimSpace(line[2:]))
+			if err != nil {
+				return nil, errorf("%v", err)
+			}
+			values = append(values, configValue{name: list.name, value: value, line: n})
+			items++
+			continue
+		}
+		switch {
+		case toml && strings.HasPrefix(line, "["):
+			return nil, errorf("tables are not supported, config contains only flag names with values")
+		case text != strings.TrimLeft(text, " \t"):
+			return nil, errorf("nested values are not supported, config contains only flag names with values")
+		case list != nil && items == 0:
+			return nil, fmt.Errorf("%s:%d: no value of %s", path, list.line, list.name)
+		}
+		list = nil
+		i := strings.Index(line, sep)
+		if i <= 0 {
+			return nil, errorf("expected %q", format)
+		}
+		name := strings.Trim(strings.TrimSpace(line[:i]), `"`)
+		raw := strings.TrimSpace(line[i+1:])
+		if !toml && (raw == "" || strings.HasPrefix(raw, "#")) {
+			list, items = &configValue{name: name, line: n}, 0
+			continue
+		}
+		parts := []string{raw}
+		if strings.HasPrefix(raw, "[") {
+			if parts, err = configList(raw); err != nil {
+				return nil, errorf("%v", err)
+			}
+		}
+		for _, item := range parts {
+			value, err := configString(item)
+			if err != nil {
+				return nil, errorf("%v", err)
+			}
+			values = append(values, configValue{name: name, value: value, line: n})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read config: %v", err)
+	}
+	if list != nil && items == 0 {
+		return nil, fmt.Errorf("%s:%d: no value of %s", path, list.line, list.name)
+	}
+	return values, nil
+}
+
+// configList returns items of the list written on one line,
+// like ["a", 'b', c]. Commas inside of quotes don't split items
+func configList(s string) ([]string, error) {
+	var items []string
+	var item strings.Builder
+	var quote rune
+	for i, r := range s[1:] {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+			item.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote = r
+			item.WriteRune(r)
+		case r == ',' || r == ']':
+			if v := strings.TrimSpace(item.String()); v != "" {
+				items = append(items, v)
+			}
+			item.Reset()
+			if r == ']' {
+				if rest := strings.TrimSpace(s[i+2:]); rest != "" && !strings.HasPrefix(rest, "#") {
+					return nil, fmt.Errorf("unexpected %s after the list", rest)
+				}
+				return items, nil
+			}
+		default:
+			item.WriteRune(r)
+		}
+	}
+	return nil, fmt.Errorf("list %s isn't closed, lists are written on one line", s)
+}
+
+// configString returns the value without quotes and the trailing comment
+func configString(s string) (string, error) {
+	switch {
+	case strings.HasPrefix(s, `"`):
+		end := strings.LastIndex(s, `"`)
+		if end == 0 {
+			return "", fmt.Errorf("unterminated string %s", s)
+		}
+		return strconv.Unquote(s[:end+1])
+	case strings.HasPrefix(s, "'"):
+		end := strings.LastIndex(s, "'")
+		if end == 0 {
+			return "", fmt.Errorf("unterminated string %s", s)
+		}
+		return s[1:end], nil
+	}
+	if i := strings.Index(s, " #"); i >= 0 {
+		s = strings.TrimSpace(s[:i])
+	}
+	return s, nil
+}
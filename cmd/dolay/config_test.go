@@ -0,0 +1,170 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeConfig returns path of the config with the content in the directory
+func writeConfig(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReadConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    []string
+		err     string
+	}{
+		{
+			name:    "yaml values",
+			file:    ".dolay.yaml",
+			content: "---\n# defaults\nn: 20\no: 'json'\nsort: \"name\" # comment\nno-color: true\n",
+			want:    []string{"n=20", "o=json", "sort=name", "no-color=true"},
+		},
+		{
+			name:    "yaml lists",
+			file:    ".dolay.yml",
+			content: "exclude: [\"*.pyc\", 'a,b']\ninclude:\n  - \"*.so\"\n  - lib/*\nn: 5\n",
+			want:    []string{"exclude=*.pyc", "exclude=a,b", "include=*.so", "include=lib/*", "n=5"},
+		},
+		{
+			name:    "toml",
+			file:    ".dolay.toml",
+			content: "# defaults\nn = 20\nsize-format = \"bytes\"\nexclude = [\"*.pyc\", \"var/cache/*\"] # caches\n",
+			want:    []string{"n=20", "size-format=bytes", "exclude=*.pyc", "exclude=var/cache/*"},
+		},
+		{name: "nested yaml", file: ".dolay.yaml", content: "sort:\n  key: size\n", err: "2: nested values are not supported"},
+		{name: "toml table", file: ".dolay.toml", content: "n = 1\n[output]\n", err: "2: tables are not supported"},
+		{name: "multiline list", file: ".dolay.toml", content: "exclude = [\n  \"a\",\n]\n", err: "1: list [ isn't closed"},
+		{name: "yaml without value", file: ".dolay.yaml", content: "n: 1\nexclude:\nsort: name\n", err: "2: no value of exclude"},
+		{name: "toml separator", file: ".dolay.toml", content: "n: 1\n", err: `1: expected "name = value"`},
+		{name: "unterminated", file: ".dolay.yaml", content: "o: \"json\n", err: "1: unterminated string"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, err := readConfig(writeConfig(t, t.TempDir(), tt.file, tt.content))
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("readConfig() error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readConfig() error = %v", err)
+			}
+			var got []string
+			for _, v := range values {
+				got = append(got, v.name+"="+v.value)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("readConfig() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// testFlags defines flags of the command line being parsed
+type testFlags struct {
+	fs      *flag.FlagSet
+	n       *int
+	output  *string
+	watch   *bool
+	exclude stringsFlag
+}
+
+func newTestFlags() *testFlags {
+	f := &testFlags{fs: flag.NewFlagSet("dolay", flag.ContinueOnError)}
+	f.fs.SetOutput(io.Discard)
+	f.fs.String("p", "-", "")
+	f.n = f.fs.Int("n", 10, "")
+	f.output = f.fs.String("o", outputText, "")
+	f.watch = f.fs.Bool("watch", false, "")
+	f.fs.Var(&f.exclude, "exclude", "")
+	return f
+}
+
+func TestLoadConfig(t *testing.T) {
+	config := writeConfig(t, t.TempDir(), ".dolay.yaml", "n: 20\no: json\nexclude:\n  - \"*.pyc\"\n  - var/cache/*\n")
+	tests := []struct {
+		name    string
+		args    []string
+		n       int
+		output  string
+		exclude []string
+	}{
+		{"config", []string{"-p", "img.tar"}, 20, outputJSON, []string{"*.pyc", "var/cache/*"}},
+		{"flag overrides config", []string{"-p", "img.tar", "-n", "3"}, 3, outputJSON, []string{"*.pyc", "var/cache/*"}},
+		{"flag replaces list", []string{"-exclude", "*.so", "-o", outputCSV}, 20, outputCSV, []string{"*.so"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newTestFlags()
+			if err := f.fs.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			if err := loadConfig(f.fs, config); err != nil {
+				t.Fatalf("loadConfig() error = %v", err)
+			}
+			if *f.n != tt.n || *f.output != tt.output || !reflect.DeepEqual([]string(f.exclude), tt.exclude) {
+				t.Errorf("flags = -n %d -o %s -exclude %v, want -n %d -o %s -exclude %v",
+					*f.n, *f.output, f.exclude, tt.n, tt.output, tt.exclude)
+			}
+		})
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		err     string
+	}{
+		{"unknown flag", "colour: false\n", "1: unknown flag colour"},
+		{"watch", "n: 1\nwatch: true\n", "2: flag watch can't be set in config"},
+		{"config", "config: other.yaml\n", "1: flag config can't be set in config"},
+		{"invalid value", "n: many\n", "1: invalid value of n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newTestFlags()
+			f.fs.String(configFlag, "", "")
+			err := loadConfig(f.fs, writeConfig(t, t.TempDir(), ".dolay.yaml", tt.content))
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("loadConfig() error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestFindConfig(t *testing.T) {
+	home, cwd := t.TempDir(), t.TempDir()
+	t.Setenv("HOME", home)
+	t.Chdir(cwd)
+	if got := findConfig(""); got != "" {
+		t.Errorf("findConfig() = %q without configs", got)
+	}
+	homeConfig := writeConfig(t, home, ".dolay.toml", "n = 1\n")
+	if got := findConfig(""); got != homeConfig {
+		t.Errorf("findConfig() = %q, want %q", got, homeConfig)
+	}
+	writeConfig(t, cwd, ".dolay.yaml", "n: 2\n")
+	if got := findConfig(""); got != filepath.Join(".", ".dolay.yaml") {
+		t.Errorf("findConfig() = %q, want config of the current directory", got)
+	}
+	if got := findConfig("custom.yaml"); got != "custom.yaml" {
+		t.Errorf("findConfig(custom.yaml) = %q", got)
+	}
+}
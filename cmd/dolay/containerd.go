@@ -0,0 +1,186 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/saromanov/dolay"
+)
+
+// defaultContainerdRoot is the root directory of containerd
+const defaultContainerdRoot = "/var/lib/containerd"
+
+// contentStore defines path of blobs in the root of containerd
+const contentStore = "io.containerd.content.v1.content/blobs"
+
+// containerdImage defines image of the local containerd content store
+type containerdImage struct {
+	Root      string
+	Namespace string
+	// Ref is name of the image or digest of its manifest
+	Ref string
+}
+
+// ociPlatform defines platform of the manifest in the image index
+type ociPlatform struct {
+	OS           string `json:"os"`
+	Architecture string `json:"architecture"`
+}
+
+// ociIndexEntry defines manifest of the image index with its platform
+type ociIndexEntry struct {
+	dolay.Descriptor
+	Platform *ociPlatform `json:"platform,omitempty"`
+}
+
+// blob returns path of the blob in the content store by digest
+func (c containerdImage) blob(digest string) string {
+	return filepath.Join(c.Root, contentStore, filepath.FromSlash(strings.Replace(digest, ":", "/", 1)))
+}
+
+// open returns stream of the image as OCI layout archive, which is
+// composed from blobs of the content store, so the image isn't exported
+func (c containerdImage) open() (io.ReadCloser, error) {
+	store := filepath.Join(c.Root, contentStore)
+	if _, err := os.Stat(store); err != nil {
+		return nil, fmt.Errorf("containerd content store is not found at %s (set -containerd-root): %v", store, err)
+	}
+	digest, err := c.resolve()
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := c.manifest(digest)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(c.blob(manifest.Digest))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read manifest: %v", err)
+	}
+	var m dolay.OCIManifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("unable to decode manifest %s: %v", manifest.Digest, err)
+	}
+	manifest.Annotations = map[string]string{"org.opencontainers.image.ref.name": c.Ref}
+	index, err := json.Marshal(dolay.OCIIndex{Manifests: []dolay.Descriptor{manifest}})
+	if err != nil {
+		return nil, err
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		tw := tar.NewWriter(pw)
+		err := writeEntry(tw, "oci-layout", bytes.NewReader([]byte(`{"imageLayoutVersion":"1.0.0"}`)))
+		if err == nil {
+			err = writeEntry(tw, "index.json", bytes.NewReader(index))
+		}
+		blobs := append([]dolay.Descriptor{manifest, m.Config}, m.Layers...)
+		for _, d := range blobs {
+			if err != nil {
+				break
+			}
+			err = c.writeBlob(tw, d.Digest)
+		}
+		if err == nil {
+			err = tw.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+	return pr, nil
+}
+
+// resolve returns digest of the image by the reference. Names are
+// resolved by ctr, since names are kept in the metadata database
+func (c containerdImage) resolve() (string, error) {
+	if strings.HasPrefix(c.Ref, "sha256:") {
+		return c.Ref, nil
+	}
+	out, err := exec.Command("ctr", "--namespace", c.Namespace, "images", "list").Output()
+	if err != nil {
+		return "", fmt.Errorf("unable to resolve %s with ctr (pass digest of the manifest instead): %v", c.Ref, err)
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		// columns are REF TYPE DIGEST SIZE PLATFORMS LABELS
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 3 && fields[0] == c.Ref {
+			return fields[2], nil
+		}
+	}
+	return "", fmt.Errorf("image %s is not found in namespace %s of containerd", c.Ref, c.Namespace)
+}
+
+// manifest returns descriptor of the image manifest by digest. Manifest
+// of the current platform is selected from the image index, and other
+// platforms are used only if their blobs are in the store
+func (c containerdImage) manifest(digest string) (dolay.Descriptor, error) {
+	data, err := os.ReadFile(c.blob(digest))
+	if err != nil {
+		return dolay.Descriptor{}, fmt.Errorf("unable to read blob %s: %v", digest, err)
+	}
+	var doc struct {
+		MediaType string          `json:"mediaType"`
+		Manifests []ociIndexEntry `json:"manifests"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return dolay.Descriptor{}, fmt.Errorf("unable to decode blob %s: %v", digest, err)
+	}
+	if doc.Manifests == nil {
+		return dolay.Descriptor{MediaType: doc.MediaType, Digest: digest, Size: int64(len(data))}, nil
+	}
+	var found *dolay.Descriptor
+	for i, m := range doc.Manifests {
+		if _, err := os.Stat(c.blob(m.Digest)); err != nil {
+			continue
+		}
+		if m.Platform != nil && m.Platform.OS == runtime.GOOS && m.Platform.Architecture == runtime.GOARCH {
+			return c.manifest(m.Digest)
+		}
+		if found == nil {
+			found = &doc.Manifests[i].Descriptor
+		}
+	}
+	if found == nil {
+		return dolay.Descriptor{}, fmt.Errorf("manifests of the index %s are not in the content store", digest)
+	}
+	return c.manifest(found.Digest)
+}
+
+// writeBlob provides writing of the blob from the content store to the archive
+func (c containerdImage) writeBlob(tw *tar.Writer, digest string) error {
+	f, err := os.Open(c.blob(digest))
+	if err != nil {
+		return fmt.Errorf("unable to read blob %s: %v", digest, err)
+	}
+	defer f.Close()
+	return writeEntry(tw, "blobs/"+strings.Replace(digest, ":", "/", 1), f)
+}
+
+// writeEntry provides writing of the file entry to the archive
+func writeEntry(tw *tar.Writer, name string, r io.Reader) error {
+	var size int64
+	switch v := r.(type) {
+	case *bytes.Reader:
+		size = v.Size()
+	case *os.File:
+		fi, err := v.Stat()
+		if err != nil {
+			return err
+		}
+		size = fi.Size()
+	}
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: size, Typeflag: tar.TypeReg}); err != nil {
+		return err
+	}
+	_, err := io.Copy(tw, r)
+	return err
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+)
+
+// csvHeader defines columns of the CSV output
+var csvHeader = []string{"layer_index", "command", "layer_size_bytes", "file_path", "file_size_bytes"}
+
+// writeCSV provides output of reports as CSV with a row per file.
+// Sizes are raw bytes, so columns can be sorted. Layer without
+// listed files has a single row with empty file columns
+func writeCSV(w io.Writer, reports []LayerReport) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, r := range reports {
+		layer := []string{strconv.Itoa(r.Index), r.Command, strconv.FormatUint(r.Size, 10)}
+		if len(r.Files) == 0 {
+			if err := cw.Write(append(layer, "", "")); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, f := range r.Files {
+			row := append(layer[:3:3], f.Name, strconv.FormatInt(f.Size, 10))
+			if err := cw.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"reflect"
+	"testing"
+
+	"github.com/saromanov/dolay"
+)
+
+func TestWriteCSV(t *testing.T) {
+	layers := []*dolay.Layer{
+		testLayer(0, `/bin/sh -c echo "a, b" > /etc/motd`, testFile("etc/motd", 5), testFile("bin/busybox", 900)),
+		testLayer(1, "/bin/sh -c #(nop)  ENV A=1"),
+	}
+	opts := testReportOptions(t)
+	opts.MaxFiles = 1 << 20
+	var buf bytes.Buffer
+	if err := writeCSV(&buf, buildReports(layers, opts)); err != nil {
+		t.Fatalf("writeCSV() error = %v", err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("output isn't valid CSV: %v", err)
+	}
+	want := [][]string{
+		csvHeader,
+		{"0", `echo "a, b" > /etc/motd`, "905", "bin/busybox", "900"},
+		{"0", `echo "a, b" > /etc/motd`, "905", "etc/motd", "5"},
+		{"1", "ENV A=1", "0", "", ""},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("decoded rows = %q, want %q", rows, want)
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+const defaultDockerHost = "unix:///var/run/docker.sock"
+
+// dockerClient returns http client and base URL
+// of the docker daemon API from DOCKER_HOST
+func dockerClient() (*http.Client, string, error) {
+	host := os.Getenv("DOCKER_HOST")
+	if host == "" {
+		host = defaultDockerHost
+	}
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid DOCKER_HOST %q: %v", host, err)
+	}
+	transport := &http.Transport{}
+	switch u.Scheme {
+	case "unix":
+		socket := u.Path
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket)
+		}
+		return &http.Client{Transport: transport}, "http://docker", nil
+	case "tcp", "http":
+		return &http.Client{Transport: transport}, "http://" + u.Host, nil
+	case "https":
+		return &http.Client{Transport: transport}, "https://" + u.Host, nil
+	default:
+		return nil, "", fmt.Errorf("unsupported DOCKER_HOST scheme %q", u.Scheme)
+	}
+}
+
+// openImage returns stream of the image archive,
+// which is exported by the docker daemon
+func openImage(name string) (io.ReadCloser, error) {
+	client, base, err := dockerClient()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Get(base + "/images/" + strings.TrimPrefix(name, "/") + "/get")
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to docker daemon: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		var msg struct {
+			Message string `json:"message"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&msg); err != nil || msg.Message == "" {
+			msg.Message = resp.Status
+		}
+		return nil, fmt.Errorf("unable to get image %s: %s", name, msg.Message)
+	}
+	return resp.Body, nil
+}
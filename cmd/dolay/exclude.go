@@ -0,0 +1,74 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/saromanov/dolay"
+)
+
+// layerFilter defines layers excluded from the report,
+// like layers of the base image
+type layerFilter struct {
+	// base is number of the bottom layers to exclude
+	base    int
+	indexes map[int]bool
+	// digests contains digests or their prefixes
+	digests []string
+}
+
+// newLayerFilter returns filter of layers. Each value is a comma-separated
+// list of zero-based indexes and digests of layers
+func newLayerFilter(base int, values []string) *layerFilter {
+	f := &layerFilter{base: base, indexes: make(map[int]bool)}
+	for _, v := range values {
+		for _, item := range strings.Split(v, ",") {
+			item = strings.TrimSpace(item)
+			if item == "" {
+				continue
+			}
+			if i, err := strconv.Atoi(item); err == nil {
+				f.indexes[i] = true
+				continue
+			}
+			f.digests = append(f.digests, item)
+		}
+	}
+	return f
+}
+
+// excluded returns true if the layer is excluded from the report.
+// Digest without algorithm matches hex part of the digest
+func (f *layerFilter) excluded(l *dolay.Layer) bool {
+	if f == nil {
+		return false
+	}
+	if l.Index < f.base || f.indexes[l.Index] {
+		return true
+	}
+	if len(f.digests) == 0 {
+		return false
+	}
+	digest := dolay.Digest(l.Path)
+	hex := digest[strings.Index(digest, ":")+1:]
+	for _, d := range f.digests {
+		if strings.HasPrefix(digest, d) || strings.HasPrefix(hex, d) {
+			return true
+		}
+	}
+	return false
+}
+
+// apply returns layers which are not excluded and number of excluded ones
+func (f *layerFilter) apply(layers []*dolay.Layer) ([]*dolay.Layer, int) {
+	if f == nil {
+		return layers, 0
+	}
+	kept := make([]*dolay.Layer, 0, len(layers))
+	for _, l := range layers {
+		if !f.excluded(l) {
+			kept = append(kept, l)
+		}
+	}
+	return kept, len(layers) - len(kept)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/saromanov/dolay"
+)
+
+func TestLayerFilter(t *testing.T) {
+	hex := strings.Repeat("ab", 32)
+	layers := []*dolay.Layer{
+		{Index: 0, Path: strings.Repeat("cd", 32) + "/layer.tar"},
+		{Index: 1, Path: "blobs/sha256/" + hex},
+		{Index: 2, Path: "2/layer.tar"},
+		{Index: 3, Path: "3/layer.tar"},
+	}
+	tests := []struct {
+		name   string
+		base   int
+		values []string
+		want   []int
+	}{
+		{"none", 0, nil, []int{0, 1, 2, 3}},
+		{"base", 2, nil, []int{2, 3}},
+		{"indexes", 0, []string{"0, 3"}, []int{1, 2}},
+		{"repeated", 0, []string{"0", "2"}, []int{1, 3}},
+		{"digest", 0, []string{"sha256:" + hex[:12]}, []int{0, 2, 3}},
+		{"hex prefix", 0, []string{"cdcd"}, []int{1, 2, 3}},
+		{"base and index", 1, []string{"3"}, []int{1, 2}},
+		{"unknown digest", 0, []string{"ffff"}, []int{0, 1, 2, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kept, skipped := newLayerFilter(tt.base, tt.values).apply(layers)
+			got := []int{}
+			for _, l := range kept {
+				got = append(got, l.Index)
+			}
+			if !reflect.DeepEqual(got, tt.want) || skipped != len(layers)-len(tt.want) {
+				t.Errorf("apply() = %v with %d skipped, want %v", got, skipped, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunExcludeLayers(t *testing.T) {
+	path := writeArchive(t, t.TempDir(), "image.tar", testArchive(t,
+		testTar(t, testFile("bin/busybox", 900)),
+		testTar(t, testFile("usr/lib/libssl.so", 500)),
+		testTar(t, testFile("app/main", 300)),
+	))
+	tests := []struct {
+		name    string
+		set     func(f *cliFlags)
+		indexes []int
+		size    uint64
+	}{
+		{"all", func(f *cliFlags) {}, []int{0, 1, 2}, 1700},
+		{"skip base", func(f *cliFlags) { f.skipBase = 1 }, []int{1, 2}, 800},
+		{"exclude layer", func(f *cliFlags) { f.excludeLayers = stringsFlag{"1"} }, []int{0, 2}, 1200},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := testCLIFlags()
+			f.output, f.tarPath = outputImageJSON, path
+			tt.set(f)
+			out, err := runAnalyzer(t, f, path)
+			if err != nil {
+				t.Fatalf("run() error = %v", err)
+			}
+			var image ImageReport
+			if err := json.Unmarshal([]byte(out), &image); err != nil {
+				t.Fatalf("output isn't valid JSON: %v\n%s", err, out)
+			}
+			var indexes []int
+			for _, l := range image.Layers {
+				indexes = append(indexes, l.Index)
+			}
+			if !reflect.DeepEqual(indexes, tt.indexes) || image.TotalSize != tt.size || image.LayerCount != len(tt.indexes) {
+				t.Errorf("image = layers %v, total %d, count %d, want %v, %d and %d",
+					indexes, image.TotalSize, image.LayerCount, tt.indexes, tt.size, len(tt.indexes))
+			}
+		})
+	}
+}
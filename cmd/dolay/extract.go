@@ -0,0 +1,91 @@
+package main
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/saromanov/dolay"
+)
+
+// extractPath returns path of the file under dir. Names which
+// point outside of dir, like "../etc/passwd", are rejected
+func extractPath(dir, name string) (string, error) {
+	clean := strings.TrimPrefix(path.Clean("/"+name), "/")
+	if clean == "" || clean != strings.TrimPrefix(path.Clean(name), "/") {
+		return "", fmt.Errorf("unsafe path of the file: %s", name)
+	}
+	return filepath.Join(dir, filepath.FromSlash(clean)), nil
+}
+
+// extractFiles provides writing of contents of entries to dir with
+// their relative paths. The archive is read again, because contents
+// are not held after the analysis. If several entries have the same
+// path, the entry of the upper layer is written
+func extractFiles(r io.Reader, analysis dolay.Options, layers []*dolay.Layer, entries []GlobalEntry, dir string) error {
+	paths := make(map[int]string, len(layers))
+	for _, l := range layers {
+		paths[l.Index] = l.Path
+	}
+	wanted := make(map[string]map[string]bool)
+	upper := make(map[string]int)
+	for _, e := range entries {
+		if _, err := extractPath(dir, e.Name); err != nil {
+			return err
+		}
+		if i, ok := upper[e.Name]; !ok || e.Layer > i {
+			upper[e.Name] = e.Layer
+		}
+	}
+	for name, i := range upper {
+		layer := paths[i]
+		if wanted[layer] == nil {
+			wanted[layer] = make(map[string]bool)
+		}
+		wanted[layer][name] = true
+	}
+
+	analysis.Keep = func(l *dolay.Layer) *dolay.Layer {
+		return &dolay.Layer{Path: l.Path}
+	}
+	// expanded archives are not passed to Content
+	analysis.Recurse = 0
+	// failed keeps the writing error, so it's not reported
+	// as the error of the layer
+	var failed error
+	analysis.Content = func(layer string, h *tar.Header, content io.Reader) error {
+		if !wanted[layer][h.Name] {
+			return nil
+		}
+		dest, _ := extractPath(dir, h.Name)
+		failed = writeFile(dest, content)
+		return failed
+	}
+	if _, err := dolay.AnalyzeWithOptions(r, analysis); err != nil {
+		if failed != nil {
+			return failed
+		}
+		return err
+	}
+	return nil
+}
+
+// writeFile provides writing of the content to the file at path
+func writeFile(dest string, content io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return fmt.Errorf("unable to create directory: %v", err)
+	}
+	f, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("unable to create file: %v", err)
+	}
+	if _, err := io.Copy(f, content); err != nil {
+		f.Close()
+		return fmt.Errorf("unable to write %s: %v", dest, err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// contentTar returns tar archive of files with the contents
+func contentTar(t *testing.T, files ...[2]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, f := range files {
+		if err := tw.WriteHeader(testFile(f[0], int64(len(f[1])))); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(f[1])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestExtractPath(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+		err  bool
+	}{
+		{"etc/passwd", filepath.Join("out", "etc", "passwd"), false},
+		{"./usr/bin/curl", filepath.Join("out", "usr", "bin", "curl"), false},
+		{"/app/main", filepath.Join("out", "app", "main"), false},
+		{"../etc/passwd", "", true},
+		{"app/../../etc/passwd", "", true},
+		{".", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := extractPath("out", tt.name)
+			if (err != nil) != tt.err || got != tt.want {
+				t.Errorf("extractPath(%q) = %q, %v, want %q", tt.name, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunExtractTop(t *testing.T) {
+	dir := t.TempDir()
+	path := writeArchive(t, dir, "image.tar", testArchive(t,
+		contentTar(t, [2]string{"etc/os-release", "NAME=Alpine\n"}, [2]string{"etc/motd", "hi\n"}),
+		contentTar(t, [2]string{"etc/os-release", "NAME=Alpine Linux v3\n"}, [2]string{"app/config.yaml", "port: 80\n"}),
+	))
+	out := filepath.Join(dir, "out")
+	f := testCLIFlags()
+	f.tarPath, f.extractTop, f.outputDir = path, 3, out
+	if _, err := runAnalyzer(t, f, path); err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+	tests := []struct {
+		name    string
+		content string
+	}{
+		// the file of the upper layer is written
+		{"etc/os-release", "NAME=Alpine Linux v3\n"},
+		{"app/config.yaml", "port: 80\n"},
+	}
+	for _, tt := range tests {
+		data, err := os.ReadFile(filepath.Join(out, filepath.FromSlash(tt.name)))
+		if err != nil || string(data) != tt.content {
+			t.Errorf("extracted %s = %q, %v, want %q", tt.name, data, err, tt.content)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(out, "etc", "motd")); !os.IsNotExist(err) {
+		t.Errorf("etc/motd isn't among top files, but it's extracted: %v", err)
+	}
+
+	f.extractTop, f.outputDir = 1, ""
+	if _, err := runAnalyzer(t, f, path); err == nil || !strings.Contains(err.Error(), "-output-dir") {
+		t.Errorf("run() error = %v, want error of missing -output-dir", err)
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// field defines column of the per-file output. File is nil
+// for the row of the layer without listed files
+type field struct {
+	name  string
+	value func(r LayerReport, f *FileEntry) interface{}
+}
+
+// fileValue returns value of the file field, which is nil without file
+func fileValue(get func(f *FileEntry) interface{}) func(LayerReport, *FileEntry) interface{} {
+	return func(_ LayerReport, f *FileEntry) interface{} {
+		if f == nil {
+			return nil
+		}
+		return get(f)
+	}
+}
+
+// fields contains columns which can be selected by -fields
+var fields = []field{
+	{"layer", func(r LayerReport, _ *FileEntry) interface{} { return r.Index }},
+	{"command", func(r LayerReport, _ *FileEntry) interface{} { return r.Command }},
+	{"layer_size", func(r LayerReport, _ *FileEntry) interface{} { return r.Size }},
+	{"name", fileValue(func(f *FileEntry) interface{} { return f.Name })},
+	{"size", fileValue(func(f *FileEntry) interface{} { return f.Size })},
+	{"mode", fileValue(func(f *FileEntry) interface{} { return f.Mode })},
+	{"uid", fileValue(func(f *FileEntry) interface{} { return f.UID })},
+	{"gid", fileValue(func(f *FileEntry) interface{} { return f.GID })},
+	{"owner", fileValue(func(f *FileEntry) interface{} { return owner(*f) })},
+	{"link", fileValue(func(f *FileEntry) interface{} { return f.Link })},
+}
+
+// parseFields returns columns by comma-separated names in the given order
+func parseFields(s string) ([]field, error) {
+	byName := make(map[string]field, len(fields))
+	names := make([]string, 0, len(fields))
+	for _, f := range fields {
+		byName[f.name] = f
+		names = append(names, f.name)
+	}
+	var selected []field
+	for _, name := range strings.Split(s, ",") {
+		f, ok := byName[strings.TrimSpace(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown field: %s (valid fields: %s)", name, strings.Join(names, ", "))
+		}
+		selected = append(selected, f)
+	}
+	return selected, nil
+}
+
+// fieldRows provides calling of fn for each listed file of reports,
+// and once with nil file for layers without listed files
+func fieldRows(reports []LayerReport, fn func(r LayerReport, f *FileEntry) error) error {
+	for _, r := range reports {
+		if len(r.Files) == 0 {
+			if err := fn(r, nil); err != nil {
+				return err
+			}
+			continue
+		}
+		for i := range r.Files {
+			if err := fn(r, &r.Files[i]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// writeFieldsCSV provides output of selected columns as CSV with a row per file
+func writeFieldsCSV(w io.Writer, reports []LayerReport, selected []field) error {
+	cw := csv.NewWriter(w)
+	header := make([]string, 0, len(selected))
+	for _, f := range selected {
+		header = append(header, f.name)
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	err := fieldRows(reports, func(r LayerReport, file *FileEntry) error {
+		row := make([]string, 0, len(selected))
+		for _, f := range selected {
+			row = append(row, csvValue(f.value(r, file)))
+		}
+		return cw.Write(row)
+	})
+	if err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// csvValue returns value of the CSV cell
+func csvValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case uint64:
+		return strconv.FormatUint(v, 10)
+	}
+	return fmt.Sprint(v)
+}
+
+// writeFieldsJSON provides output of selected fields as JSON array with
+// an object per file. Keys of objects are in order of the selection
+func writeFieldsJSON(w io.Writer, reports []LayerReport, selected []field) error {
+	var buf bytes.Buffer
+	buf.WriteByte('[')
+	first := true
+	err := fieldRows(reports, func(r LayerReport, file *FileEntry) error {
+		if !first {
+			buf.WriteByte(',')
+		}
+		first = false
+		buf.WriteByte('{')
+		for i, f := range selected {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			key, _ := json.Marshal(f.name)
+			value, err := json.Marshal(f.value(r, file))
+			if err != nil {
+				return err
+			}
+			buf.Write(key)
+			buf.WriteByte(':')
+			buf.Write(value)
+		}
+		buf.WriteByte('}')
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	buf.WriteByte(']')
+	var out bytes.Buffer
+	if err := json.Indent(&out, buf.Bytes(), "", "  "); err != nil {
+		return err
+	}
+	out.WriteByte('\n')
+	_, err = out.WriteTo(w)
+	return err
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/saromanov/dolay"
+)
+
+func TestParseFields(t *testing.T) {
+	tests := []struct {
+		value string
+		want  []string
+		err   string
+	}{
+		{"name", []string{"name"}, ""},
+		{"size, name,layer", []string{"size", "name", "layer"}, ""},
+		{"name,colour", nil, "unknown field: colour"},
+		{"", nil, "unknown field: "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			selected, err := parseFields(tt.value)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("parseFields() error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseFields() error = %v", err)
+			}
+			var got []string
+			for _, f := range selected {
+				got = append(got, f.name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseFields() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteFields(t *testing.T) {
+	layers := []*dolay.Layer{
+		testLayer(0, "/bin/sh -c #(nop) ADD file:abc in /", testFile("bin/busybox", 900)),
+		testLayer(1, "/bin/sh -c #(nop)  ENV A=b"),
+	}
+	reports := buildReports(layers, testReportOptions(t))
+	selected, err := parseFields("size,name,layer")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := writeFieldsCSV(&buf, reports, selected); err != nil {
+		t.Fatalf("writeFieldsCSV() error = %v", err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("output isn't valid CSV: %v", err)
+	}
+	want := [][]string{{"size", "name", "layer"}, {"900", "bin/busybox", "0"}, {"", "", "1"}}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("writeFieldsCSV() = %v, want %v", rows, want)
+	}
+
+	buf.Reset()
+	if err := writeFieldsJSON(&buf, reports, selected); err != nil {
+		t.Fatalf("writeFieldsJSON() error = %v", err)
+	}
+	var records []map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &records); err != nil {
+		t.Fatalf("output isn't valid JSON: %v\n%s", err, buf.String())
+	}
+	wantRecords := []map[string]interface{}{
+		{"size": 900.0, "name": "bin/busybox", "layer": 0.0},
+		{"size": nil, "name": nil, "layer": 1.0},
+	}
+	if !reflect.DeepEqual(records, wantRecords) {
+		t.Errorf("writeFieldsJSON() = %v, want %v", records, wantRecords)
+	}
+	// keys are in order of the selection
+	if i, j, k := strings.Index(buf.String(), `"size"`), strings.Index(buf.String(), `"name"`), strings.Index(buf.String(), `"layer"`); !(i < j && j < k) {
+		t.Errorf("writeFieldsJSON() keys aren't in order of the selection:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	if err := writeFieldsJSON(&buf, nil, selected); err != nil || strings.TrimSpace(buf.String()) != "[]" {
+		t.Errorf("writeFieldsJSON() without reports = %q, %v, want []", buf.String(), err)
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"flag"
+
+	"github.com/dustin/go-humanize"
+	"github.com/saromanov/dolay"
+)
+
+// cliFlags defines values of flags of the command line
+type cliFlags struct {
+	tarPath         string
+	batchSummary    bool
+	image           string
+	ctrd            containerdImage
+	repoTag         string
+	imageIndex      int
+	jobs            int
+	watch           bool
+	quiet           bool
+	logFormatName   string
+	fieldList       string
+	printSchema     bool
+	validate        bool
+	verify          bool
+	noHistory       bool
+	rawLayer        bool
+	squashfs        bool
+	maxFiles        int
+	layerIndex      int
+	largest         bool
+	lineWidth       string
+	saveImage       string
+	output          string
+	iec             bool
+	outFile         string
+	sizeFormat      string
+	comma           bool
+	noColor         bool
+	themeName       string
+	mediumLayer     string
+	largeLayer      string
+	dedupeHardlinks bool
+	showWhiteouts   bool
+	duplicates      bool
+	diff            string
+	sortKey         string
+	reverse         bool
+	include         stringsFlag
+	exclude         stringsFlag
+	skipBase        int
+	excludeLayers   stringsFlag
+	grep            string
+	ignoreCase      bool
+	regex           bool
+	minSize         string
+	long            bool
+	tree            bool
+	maxTotalSize    string
+	maxLayers       int
+	snapshot        string
+	updateSnapshot  bool
+	maxGrowth       string
+	timed           bool
+	wide            bool
+	truncation      string
+	showCreated     bool
+	showAge         bool
+	noHumanize      bool
+	compact         bool
+	inspect         bool
+	interactive     bool
+	filesOnly       bool
+	brokenLinks     bool
+	sinceLayer      int
+	chart           bool
+	byInstruction   bool
+	effective       bool
+	byOwner         bool
+	topCommands     bool
+	rulesFile       string
+	lint            bool
+	top             bool
+	recurse         int
+	extractTop      int
+	outputDir       string
+	topDirs         bool
+	dirsRecursive   bool
+	after           string
+	before          string
+	onlyAdded       bool
+	stream          bool
+	xattrs          bool
+	digests         bool
+	showEmpty       bool
+	byExt           bool
+	config          string
+}
+
+// parseFlags returns flags of the command line. Flags which are not
+// set by the command line take defaults of the config
+func parseFlags() (*cliFlags, error) {
+	f := &cliFlags{}
+	flag.StringVar(&f.tarPath, "p", "-", "path or HTTP(S) URL of the archive, or glob pattern of archives to analyze each of them")
+	flag.BoolVar(&f.batchSummary, "batch-summary", false, "show total sizes of archives matched by the glob pattern after their reports")
+	flag.DurationVar(&httpTimeout, "timeout", httpTimeout, "timeout of connecting to the server for HTTP(S) archives")
+	flag.StringVar(&f.image, "image", "", "analyze image from the docker daemon (DOCKER_HOST) by name")
+	flag.StringVar(&f.ctrd.Ref, "containerd-ref", "", "analyze image from the local containerd content store by name or digest of the manifest")
+	flag.StringVar(&f.ctrd.Root, "containerd-root", defaultContainerdRoot, "root directory of containerd")
+	flag.StringVar(&f.ctrd.Namespace, "containerd-namespace", "default", "namespace of containerd images (k8s.io for kubernetes)")
+	flag.StringVar(&f.repoTag, "repo-tag", "", "analyze image with the repo tag from multi-image archive")
+	flag.IntVar(&f.imageIndex, "index", -1, "analyze image by index from multi-image archive")
+	flag.IntVar(&f.jobs, "jobs", 1, "number of layers decoded in parallel")
+	flag.BoolVar(&f.watch, "watch", false, "re-run the analysis whenever the archive file changes")
+	flag.BoolVar(&f.quiet, "quiet", false, "don't show progress of reading at stderr")
+	flag.StringVar(&f.logFormatName, "log-format", logText, "format of errors and timings at stderr: text or json (progress isn't shown for json)")
+	flag.StringVar(&f.fieldList, "fields", "", "comma-separated fields of files in json and csv output (like name,size,layer)")
+	flag.BoolVar(&f.printSchema, "schema", false, "print JSON Schema of the json, image-json or ndjson output and exit")
+	flag.BoolVar(&f.validate, "validate", false, "check integrity of the archive and print PASS or FAIL instead of the listing")
+	flag.BoolVar(&f.verify, "verify-digests", false, "compare sha256 of layer blobs (compressed as stored) with digests of the manifest or diff IDs of the config")
+	flag.BoolVar(&f.noHistory, "no-history", false, "list layers of archives without image config or history")
+	flag.BoolVar(&f.rawLayer, "raw-layer", false, "analyze standalone layer tar instead of image archive")
+	flag.BoolVar(&f.squashfs, "squashfs", false, "analyze squashfs image or SIF image of Singularity/Apptainer instead of image archive (names and sizes of files only)")
+	flag.IntVar(&f.maxFiles, "n", 10, "max files (all files for csv output or -layer unless it's set)")
+	flag.IntVar(&f.layerIndex, "layer", -1, "show all files of the layer by zero-based index with permissions and ownership")
+	flag.BoolVar(&f.largest, "largest-layer", false, "show only the largest layer with its top -n files")
+	flag.StringVar(&f.lineWidth, "l", widthAuto, "screen line width, auto is the width of the terminal")
+	flag.StringVar(&f.saveImage, "s", "", "save of the image")
+	flag.StringVar(&f.output, "o", outputText, "output format (text, json, image-json, ndjson, csv, markdown, summary or prometheus)")
+	flag.BoolVar(&f.iec, "iec", false, "show sizes in IEC units (KiB, MiB) like docker")
+	flag.StringVar(&f.outFile, "output", "", "write the report to the file instead of stdout")
+	flag.StringVar(&f.outFile, "O", "", "shorthand for -output")
+	flag.StringVar(&f.sizeFormat, "size-format", sizeHuman, "format of sizes: human, bytes or si-fixed (two decimals)")
+	flag.BoolVar(&f.comma, "comma", false, "group digits of byte counts by thousands (1,234,567) with -size-format bytes")
+	flag.BoolVar(&f.noColor, "no-color", false, "disable colored output")
+	flag.StringVar(&f.themeName, "theme", "default", "colors of the output: default, dark, light or mono")
+	flag.StringVar(&f.mediumLayer, "medium-layer", humanize.Bytes(defaultMediumSize), "color layers of the size as medium")
+	flag.StringVar(&f.largeLayer, "large-layer", humanize.Bytes(defaultLargeSize), "color layers of the size as large")
+	flag.BoolVar(&f.dedupeHardlinks, "dedupe-hardlinks", false, "show hardlinks of layers and total size counting every link by its target")
+	flag.BoolVar(&f.showWhiteouts, "show-whiteouts", false, "show files deleted by the layer")
+	flag.BoolVar(&f.duplicates, "duplicates", false, "show files which are duplicated across layers")
+	flag.StringVar(&f.diff, "diff", "", "compare the archive with other archive")
+	flag.StringVar(&f.sortKey, "sort", dolay.SortBySize, "sort files by size, name, mtime or type")
+	flag.BoolVar(&f.reverse, "reverse", false, "reverse order of files")
+	flag.Var(&f.include, "include", "show only files matching the glob pattern (can be repeated)")
+	flag.Var(&f.exclude, "exclude", "hide files matching the glob pattern (can be repeated)")
+	flag.IntVar(&f.skipBase, "skip-base", 0, "exclude the first N layers, like layers of the base image")
+	flag.Var(&f.excludeLayers, "exclude-layer", "exclude layers by comma-separated indexes or digest prefixes (can be repeated)")
+	flag.StringVar(&f.grep, "grep", "", "show only layers with commands containing the substring")
+	flag.BoolVar(&f.ignoreCase, "ignore-case", false, "match -grep case-insensitively")
+	flag.BoolVar(&f.regex, "regex", false, "treat -include and -exclude patterns as regular expressions")
+	flag.StringVar(&f.minSize, "min-size", "0", "hide files smaller than the size (like 10MB)")
+	flag.BoolVar(&f.long, "long", false, "show permissions and ownership of files")
+	flag.BoolVar(&f.long, "L", false, "shorthand for -long")
+	flag.BoolVar(&f.tree, "tree", false, "show files as a tree with sizes of directories (-n limits entries per directory)")
+	flag.StringVar(&f.maxTotalSize, "max-total-size", "", "fail if total size of the image exceeds the size (like 100MB)")
+	flag.IntVar(&f.maxLayers, "max-layers", 0, "fail if the image has more layers than the number")
+	flag.StringVar(&f.snapshot, "snapshot", "", "write sizes of layers to the lockfile, or compare with it if it exists")
+	flag.BoolVar(&f.updateSnapshot, "update-snapshot", false, "rewrite the lockfile of -snapshot with current sizes")
+	flag.StringVar(&f.maxGrowth, "max-growth", "", "fail if total size grew from the -snapshot by the size (like 10MB) or percents (like 5%)")
+	flag.BoolVar(&f.timed, "timings", false, "show time of reading of the archive and of decoding of layers at stderr")
+	flag.BoolVar(&f.wide, "wide", false, "don't truncate commands (same as -truncate none)")
+	flag.StringVar(&f.truncation, "truncate", truncateEnd, "truncation of long commands: end, middle or none")
+	flag.BoolVar(&f.showCreated, "created", false, "show creation time of the image and of layers")
+	flag.BoolVar(&f.showAge, "age", false, "show age of the image in the total line, like \"built 3 days ago\"")
+	flag.BoolVar(&f.noHumanize, "no-humanize", false, "show times in RFC3339 only instead of relative time")
+	flag.BoolVar(&f.compact, "compact", false, "don't print separator lines and blank lines")
+	flag.BoolVar(&f.inspect, "inspect", false, "show entrypoint, cmd, env, ports and labels of the image before layers")
+	flag.BoolVar(&f.interactive, "tui", false, "explore layers in the interactive terminal view")
+	flag.BoolVar(&f.filesOnly, "files-only", false, "list all files of the final filesystem of the image by path")
+	flag.BoolVar(&f.brokenLinks, "broken-links", false, "show symlinks which targets don't exist in the image filesystem")
+	flag.IntVar(&f.sinceLayer, "since-layer", -1, "show changes of the filesystem made by layers from the index onward")
+	flag.BoolVar(&f.chart, "chart", false, "show sizes of layers as a bar chart")
+	flag.BoolVar(&f.byInstruction, "by-instruction", false, "show total size of layers by the Dockerfile instruction (RUN, COPY, ADD)")
+	flag.BoolVar(&f.effective, "effective", false, "show number of files of the composed filesystem, and of overwritten and deleted files in the total")
+	flag.BoolVar(&f.byOwner, "group-by-owner", false, "show total size and number of files of layers by the uid and gid of the owner")
+	flag.BoolVar(&f.topCommands, "top-commands", false, "show total size of layers by the command, counted across all archives matched by -p")
+	flag.StringVar(&f.rulesFile, "rules", "", "check files of the image filesystem by rules of the JSON file")
+	flag.BoolVar(&f.lint, "lint", false, "warn about package-manager caches left in layers")
+	flag.BoolVar(&f.top, "global-top", false, "show top -n files across all layers instead of files of each layer")
+	flag.IntVar(&f.recurse, "recurse", 0, "list files of tar archives inside of layers up to N levels of nesting")
+	flag.IntVar(&f.extractTop, "extract-top", 0, "write top N files across all layers to -output-dir")
+	flag.StringVar(&f.outputDir, "output-dir", "", "directory of files written by -extract-top")
+	flag.BoolVar(&f.topDirs, "top-dirs", false, "show top directories by total size of their files instead of files")
+	flag.BoolVar(&f.dirsRecursive, "dirs-recursive", false, "count files of subdirectories in -top-dirs")
+	flag.StringVar(&f.after, "after", "", "list only files modified after the RFC3339 time or the duration before now (24h)")
+	flag.StringVar(&f.before, "before", "", "list only files modified before the RFC3339 time or the duration before now (24h)")
+	flag.BoolVar(&f.onlyAdded, "only-added", false, "list only files added or modified by each layer, hiding files which squashed layers inherit unchanged")
+	flag.BoolVar(&f.stream, "stream", false, "hold only top -n files of each layer while reading, so memory doesn't grow with number of files")
+	flag.BoolVar(&f.xattrs, "xattrs", false, "show extended attributes and capabilities of files")
+	flag.BoolVar(&f.digests, "digests", false, "show digests of layers and of the image config")
+	flag.BoolVar(&f.showEmpty, "show-empty", false, "list history records which didn't change the filesystem (like ENV or LABEL)")
+	flag.BoolVar(&f.byExt, "by-ext", false, "show top extensions of files by total size instead of files")
+	flag.StringVar(&f.config, configFlag, "", "file of default values of flags (.dolay.yaml or .dolay.toml of the current or home directory by default)")
+	flag.Parse()
+	if err := loadConfig(flag.CommandLine, findConfig(f.config)); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// httpTimeout limits connecting to the server and waiting for
+// the response headers. Reading of the archive isn't limited,
+// since large archives are streamed for a long time
+var httpTimeout = 30 * time.Second
+
+// isURL returns true if the archive path is HTTP(S) URL
+func isURL(path string) bool {
+	return strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://")
+}
+
+// openURL returns stream of the archive downloaded by URL.
+// Redirects are followed by the client
+func openURL(url string) (io.ReadCloser, error) {
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           (&net.Dialer{Timeout: httpTimeout}).DialContext,
+		TLSHandshakeTimeout:   httpTimeout,
+		ResponseHeaderTimeout: httpTimeout,
+	}
+	client := &http.Client{Transport: transport}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("unable to download archive: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("unable to download archive %s: %s", url, resp.Status)
+	}
+	return resp.Body, nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+
+	"github.com/saromanov/dolay"
+)
+
+// analyzeIndexed returns report of the archive file read by the index
+// of layers, so tar headers are read and content of files of uncompressed
+// layers is skipped. Archive which isn't a regular file or is compressed,
+// and options which need content of files, fall back to the single pass
+func analyzeIndexed(r io.ReadCloser, opts dolay.Options) (*dolay.Report, error) {
+	src := io.Reader(r)
+	if p, ok := r.(*progress); ok {
+		src = p.r
+	}
+	f, ok := src.(*os.File)
+	if !ok || opts.Recurse > 0 || opts.Content != nil || opts.MaxFiles > 0 {
+		return analyze(r, nil, opts)
+	}
+	info, err := f.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		return analyze(r, nil, opts)
+	}
+	// index is read at offsets, so the file is still
+	// at the start if it can't be indexed
+	index, err := dolay.OpenIndex(f, info.Size(), opts)
+	if errors.Is(err, dolay.ErrUnsupportedFormat) {
+		return analyze(r, nil, opts)
+	}
+	defer r.Close()
+	if err != nil {
+		return nil, err
+	}
+	report := &dolay.Report{
+		Manifest:  index.Manifest,
+		Manifests: index.Manifests,
+		Image:     index.Image,
+		History:   index.Image.History,
+	}
+	for i := 0; i < index.Len(); i++ {
+		layer, err := index.ReadLayer(i)
+		if err != nil {
+			return nil, err
+		}
+		if opts.Keep != nil {
+			layer = opts.Keep(layer)
+		}
+		report.Layers = append(report.Layers, layer)
+	}
+	return report, nil
+}
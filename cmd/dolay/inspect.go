@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/saromanov/dolay"
+)
+
+// printInspect provides output of the image configuration
+// like docker inspect. Empty fields are omitted
+func printInspect(w io.Writer, img dolay.Image, opts TextOptions) {
+	opts.separator(w)
+	fmt.Fprintln(w, theme.Header.Sprintf("%simage config", opts.prefix()))
+	opts.separator(w)
+	field := func(name, value string) {
+		if value != "" {
+			fmt.Fprintf(w, "%-12s %s\n", name+":", value)
+		}
+	}
+	if img.OS != "" || img.Architecture != "" {
+		field("Platform", strings.Trim(img.OS+"/"+img.Architecture, "/"))
+	}
+	c := img.Config
+	field("Entrypoint", command(c.Entrypoint))
+	field("Cmd", command(c.Cmd))
+	field("WorkingDir", c.WorkingDir)
+	field("User", c.User)
+	ports := make([]string, 0, len(c.ExposedPorts))
+	for p := range c.ExposedPorts {
+		ports = append(ports, p)
+	}
+	sort.Strings(ports)
+	field("Ports", strings.Join(ports, ", "))
+	if len(c.Env) > 0 {
+		fmt.Fprintln(w, "Env:")
+		for _, e := range c.Env {
+			fmt.Fprintln(w, "  "+e)
+		}
+	}
+	if len(c.Labels) > 0 {
+		keys := make([]string, 0, len(c.Labels))
+		for k := range c.Labels {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		fmt.Fprintln(w, "Labels:")
+		for _, k := range keys {
+			fmt.Fprintf(w, "  %s=%s\n", k, c.Labels[k])
+		}
+	}
+}
+
+// command returns exec form of the command as JSON array,
+// like it's written in Dockerfile
+func command(args []string) string {
+	if len(args) == 0 {
+		return ""
+	}
+	data, err := json.Marshal(args)
+	if err != nil {
+		return strings.Join(args, " ")
+	}
+	return string(data)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+)
+
+// Formats of records written to stderr
+const (
+	logText = "text"
+	logJSON = "json"
+)
+
+// Levels of records
+const (
+	levelInfo  = "info"
+	levelWarn  = "warn"
+	levelError = "error"
+)
+
+// logFormat is the format of records written to stderr
+var logFormat = logText
+
+// logOutput is the writer of records
+var logOutput io.Writer = os.Stderr
+
+// setLogFormat switches format of records
+func setLogFormat(format string) error {
+	switch format {
+	case logText, logJSON:
+		logFormat = format
+		return nil
+	}
+	return fmt.Errorf("unknown log format: %s", format)
+}
+
+// logf provides writing of the formatted message to stderr. Message
+// is written as is in the text format, and as the JSON line with
+// time, level and fields in the json format
+func logf(level string, fields map[string]interface{}, format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	if logFormat != logJSON {
+		fmt.Fprintln(logOutput, msg)
+		return
+	}
+	record := map[string]interface{}{
+		"time":  time.Now().Format(time.RFC3339Nano),
+		"level": level,
+		"msg":   msg,
+	}
+	for k, v := range fields {
+		record[k] = v
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		fmt.Fprintln(logOutput, msg)
+		return
+	}
+	fmt.Fprintln(logOutput, string(data))
+}
+
+// fatal provides writing of the error and exit with code 1. The error
+// is prefixed with time in the text format like log.Fatal does
+func fatal(err error) {
+	if logFormat != logJSON {
+		log.Fatal(err)
+	}
+	logf(levelError, map[string]interface{}{"error": err.Error()}, "dolay failed")
+	os.Exit(1)
+}
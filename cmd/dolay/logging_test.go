@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+// withLog provides capturing of records of the format for the test
+func withLog(t *testing.T, format string) *bytes.Buffer {
+	t.Helper()
+	savedFormat, savedOutput := logFormat, logOutput
+	t.Cleanup(func() { logFormat, logOutput = savedFormat, savedOutput })
+	if err := setLogFormat(format); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	logOutput = &buf
+	return &buf
+}
+
+func TestLogf(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		level  string
+		fields map[string]interface{}
+		want   map[string]interface{}
+		text   string
+	}{
+		{"text", logText, levelWarn, nil, nil, "3 files have zero time\n"},
+		{"json", logJSON, levelWarn, nil, map[string]interface{}{"level": "warn", "msg": "3 files have zero time"}, ""},
+		{"json fields", logJSON, levelError, map[string]interface{}{"error": "corrupt layer"},
+			map[string]interface{}{"level": "error", "msg": "3 files have zero time", "error": "corrupt layer"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := withLog(t, tt.format)
+			logf(tt.level, tt.fields, "%d files have zero time", 3)
+			if tt.want == nil {
+				if buf.String() != tt.text {
+					t.Errorf("logf() = %q, want %q", buf.String(), tt.text)
+				}
+				return
+			}
+			var record map[string]interface{}
+			if err := json.Unmarshal(buf.Bytes(), &record); err != nil || strings.Count(buf.String(), "\n") != 1 {
+				t.Fatalf("logf() = %q isn't a JSON line: %v", buf.String(), err)
+			}
+			if _, err := time.Parse(time.RFC3339Nano, record["time"].(string)); err != nil {
+				t.Errorf("time of the record: %v", err)
+			}
+			delete(record, "time")
+			for k, v := range tt.want {
+				if record[k] != v {
+					t.Errorf("record[%s] = %v, want %v", k, record[k], v)
+				}
+			}
+			if len(record) != len(tt.want) {
+				t.Errorf("record = %v, want %v with time", record, tt.want)
+			}
+		})
+	}
+	if err := setLogFormat("xml"); err == nil {
+		t.Error("setLogFormat(xml) error = nil")
+	}
+}
+
+func TestFatalJSON(t *testing.T) {
+	if os.Getenv("DOLAY_TEST_FATAL") == "1" {
+		logFormat = logJSON
+		fatal(errors.New("corrupt layer 1/layer.tar: unexpected EOF"))
+		return
+	}
+	cmd := exec.Command(os.Args[0], "-test.run=^TestFatalJSON$")
+	cmd.Env = append(os.Environ(), "DOLAY_TEST_FATAL=1")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	var exit *exec.ExitError
+	if !errors.As(err, &exit) || exit.ExitCode() != 1 {
+		t.Fatalf("fatal() exit = %v, want the exit code 1", err)
+	}
+	var record map[string]interface{}
+	if err := json.Unmarshal(stderr.Bytes(), &record); err != nil || strings.Count(stderr.String(), "\n") != 1 {
+		t.Fatalf("stderr = %q isn't a single JSON line: %v", stderr.String(), err)
+	}
+	if record["level"] != levelError || record["error"] != "corrupt layer 1/layer.tar: unexpected EOF" {
+		t.Errorf("error record = %v", record)
+	}
+}
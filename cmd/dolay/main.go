@@ -0,0 +1,754 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/fatih/color"
+	"github.com/mattn/go-isatty"
+	"github.com/saromanov/dolay"
+	"golang.org/x/term"
+)
+
+// Output formats
+const (
+	outputText       = "text"
+	outputJSON       = "json"
+	outputNDJSON     = "ndjson"
+	outputCSV        = "csv"
+	outputMarkdown   = "markdown"
+	outputSummary    = "summary"
+	outputPrometheus = "prometheus"
+	outputImageJSON  = "image-json"
+)
+
+// stringsFlag defines flag which can be repeated
+type stringsFlag []string
+
+// String returns values of the flag
+func (s *stringsFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+// Set provides adding of the value to the flag
+func (s *stringsFlag) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+// openArchive returns reader of the archive by path.
+// "-" means reading of the archive from stdin, and
+// HTTP(S) URL means downloading of the archive
+func openArchive(path string) (io.ReadCloser, error) {
+	if path == "-" {
+		return io.NopCloser(os.Stdin), nil
+	}
+	if isURL(path) {
+		return openURL(path)
+	}
+	r, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open file: %v", err)
+	}
+	return r, nil
+}
+
+// openSource returns reader of the image from the docker daemon
+// if image is set, from the containerd content store if its
+// reference is set, or of the archive by path otherwise
+func openSource(path, image string, ctrd containerdImage) (io.ReadCloser, error) {
+	if image != "" {
+		return openImage(image)
+	}
+	if ctrd.Ref != "" {
+		return ctrd.open()
+	}
+	return openArchive(path)
+}
+
+// loadArchive provides opening and analysis of the archive by path
+func loadArchive(path string, opts dolay.Options) (*dolay.Report, error) {
+	r, err := openArchive(path)
+	return analyze(r, err, opts)
+}
+
+func analyze(r io.ReadCloser, err error, opts dolay.Options) (*dolay.Report, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return dolay.AnalyzeWithOptions(r, opts)
+}
+
+// loadRules returns rules of the custom lint from the file
+func loadRules(path string) ([]dolay.PolicyRule, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open rules: %v", err)
+	}
+	defer f.Close()
+	return dolay.LoadRules(f)
+}
+
+// analyzeLayer returns report with the single layer,
+// when the archive is a standalone layer tar
+func analyzeLayer(r io.ReadCloser, err error) (*dolay.Report, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	layer, err := dolay.ReadLayer(r)
+	if err != nil {
+		return nil, err
+	}
+	return &dolay.Report{Layers: []*dolay.Layer{layer}}, nil
+}
+
+// analyzeSquashfs returns report with the single layer of the
+// squashfs or SIF image. Image which isn't a file is read to memory,
+// since metadata tables are read at their offsets
+func analyzeSquashfs(r io.ReadCloser) (*dolay.Report, error) {
+	defer r.Close()
+	ra, ok := r.(io.ReaderAt)
+	if !ok {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		ra = bytes.NewReader(data)
+	}
+	layer, err := dolay.ReadSquashfs(ra)
+	if err != nil {
+		return nil, err
+	}
+	return &dolay.Report{Layers: []*dolay.Layer{layer}}, nil
+}
+
+// selectImage returns selector of the image by repo tag or index.
+// If none of them is set, the first image is selected
+// and the choice is printed for archives with several images
+func selectImage(tag string, index int) func([]dolay.ManifestItem) (int, error) {
+	switch {
+	case tag != "":
+		return dolay.SelectByRepoTag(tag)
+	case index >= 0:
+		return dolay.SelectByIndex(index)
+	}
+	return func(manifests []dolay.ManifestItem) (int, error) {
+		if len(manifests) > 1 {
+			tags := dolay.RepoTags(manifests)
+			logf(levelWarn, map[string]interface{}{"images": len(manifests), "repo_tags": tags},
+				"archive contains %d images, analyzing the first one (use -repo-tag or -index): %s",
+				len(manifests), strings.Join(tags, ", "))
+		}
+		return 0, nil
+	}
+}
+
+// setupColor disables colored output if it was asked by flag
+// or NO_COLOR environment variable, or if stdout is not a terminal
+func setupColor(noColor bool) {
+	if _, ok := os.LookupEnv("NO_COLOR"); ok {
+		noColor = true
+	}
+	fd := os.Stdout.Fd()
+	if !isatty.IsTerminal(fd) && !isatty.IsCygwinTerminal(fd) {
+		noColor = true
+	}
+	color.NoColor = noColor
+}
+
+// widthAuto is the line width of the terminal
+const widthAuto = "auto"
+
+// defaultWidth defines line width when stdout is not a terminal
+const defaultWidth = 100
+
+// parseLineWidth returns line width by the flag value. Width of the
+// terminal is used for "auto" if output goes to stdout and it's a terminal
+func parseLineWidth(s string, stdout bool) (int, error) {
+	if s == widthAuto {
+		fd := int(os.Stdout.Fd())
+		if !stdout || !term.IsTerminal(fd) {
+			return defaultWidth, nil
+		}
+		width, _, err := term.GetSize(fd)
+		if err != nil || width <= 0 {
+			return defaultWidth, nil
+		}
+		return width, nil
+	}
+	width, err := strconv.Atoi(s)
+	if err != nil || width <= 0 {
+		return 0, fmt.Errorf("invalid line width: %s", s)
+	}
+	return width, nil
+}
+
+// isFlagSet returns true if the flag was set on the command line or by the config
+func isFlagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+func run() (err error) {
+	f, err := parseFlags()
+	if err != nil {
+		return err
+	}
+	if err := setLogFormat(f.logFormatName); err != nil {
+		return err
+	}
+	paths := []string{f.tarPath}
+	if f.image == "" && f.ctrd.Ref == "" {
+		if paths, err = expandArchives(f.tarPath); err != nil {
+			return err
+		}
+	}
+	mode, err := f.selectMode()
+	if err != nil {
+		return err
+	}
+	if err := f.check(paths, mode); err != nil {
+		return err
+	}
+	if f.watch {
+		return watchArchive(f.tarPath)
+	}
+	setupColor(f.noColor)
+	out := io.Writer(os.Stdout)
+	if f.outFile != "" {
+		file, ferr := os.Create(f.outFile)
+		if ferr != nil {
+			return fmt.Errorf("unable to create output file: %v", ferr)
+		}
+		defer func() {
+			if cerr := file.Close(); cerr != nil && err == nil {
+				err = fmt.Errorf("unable to close output file: %v", cerr)
+			}
+		}()
+		out = file
+		color.NoColor = true
+	}
+	relativeTime = !f.noHumanize
+	if err := setSizeFormat(f.sizeFormat, f.iec, f.comma); err != nil {
+		return err
+	}
+	mediumBytes, err := humanize.ParseBytes(f.mediumLayer)
+	if err != nil {
+		return fmt.Errorf("invalid medium layer size: %v", err)
+	}
+	largeBytes, err := humanize.ParseBytes(f.largeLayer)
+	if err != nil {
+		return fmt.Errorf("invalid large layer size: %v", err)
+	}
+	if err := setTheme(f.themeName, mediumBytes, largeBytes); err != nil {
+		return err
+	}
+	if mode.name == modeSchema {
+		return printJSONSchema(out, f.output)
+	}
+	a, err := newAnalyzer(f, mode, paths, out)
+	if err != nil {
+		return err
+	}
+	if f.saveImage != "" {
+		cmd := exec.Command("/bin/sh", "-c", fmt.Sprintf("sudo docker save -o image.tar %s", f.saveImage))
+		output, _ := cmd.Output()
+		fmt.Fprintf(os.Stderr, "OUTPUT: %s", output)
+	}
+	if a.window.enabled() {
+		defer a.window.warn()
+	}
+	return a.run()
+}
+
+// analyzer defines the analysis of archives by flags of the command line
+type analyzer struct {
+	*cliFlags
+	mode     reportMode
+	paths    []string
+	out      io.Writer
+	opts     ReportOptions
+	text     TextOptions
+	analysis dolay.Options
+	window   timeWindow
+	selected []field
+	rules    []dolay.PolicyRule
+	growth   growthLimit
+	budget   Budget
+	totals   []ArchiveTotal
+	// batchLayers are layers of all archives for -top-commands
+	batchLayers []*dolay.Layer
+}
+
+// newAnalyzer returns the analyzer with options parsed from flags
+func newAnalyzer(f *cliFlags, mode reportMode, paths []string, out io.Writer) (*analyzer, error) {
+	a := &analyzer{cliFlags: f, mode: mode, paths: paths, out: out}
+	var err error
+	if f.maxFiles < 0 {
+		return nil, fmt.Errorf("invalid max files: %d", f.maxFiles)
+	}
+	if f.fieldList != "" {
+		if a.selected, err = parseFields(f.fieldList); err != nil {
+			return nil, err
+		}
+	}
+	order, err := dolay.OrderBy(f.sortKey)
+	if err != nil {
+		return nil, err
+	}
+	filter, err := dolay.NewPathFilter(f.include, f.exclude, f.regex)
+	if err != nil {
+		return nil, err
+	}
+	minBytes, err := humanize.ParseBytes(f.minSize)
+	if err != nil {
+		return nil, fmt.Errorf("invalid min size: %v", err)
+	}
+	if f.rulesFile != "" {
+		if a.rules, err = loadRules(f.rulesFile); err != nil {
+			return nil, err
+		}
+	}
+	if a.growth, err = parseGrowth(f.maxGrowth); err != nil {
+		return nil, err
+	}
+	a.budget = Budget{MaxLayers: f.maxLayers}
+	if f.maxTotalSize != "" {
+		a.budget.MaxSize, err = humanize.ParseBytes(f.maxTotalSize)
+		if err != nil {
+			return nil, fmt.Errorf("invalid max total size: %v", err)
+		}
+	}
+	if (f.output == outputCSV || f.layerIndex >= 0) && !isFlagSet("n") {
+		f.maxFiles = math.MaxInt32
+	}
+	if f.layerIndex >= 0 && !isFlagSet("long") && !isFlagSet("L") {
+		f.long = true
+	}
+	now := time.Now()
+	if a.window.After, err = parseTime(f.after, now); err != nil {
+		return nil, err
+	}
+	if a.window.Before, err = parseTime(f.before, now); err != nil {
+		return nil, err
+	}
+	a.opts = ReportOptions{
+		MaxFiles:      f.maxFiles,
+		ShowWhiteouts: f.showWhiteouts,
+		Order:         order,
+		Reverse:       f.reverse,
+		Filter:        filter,
+		MinSize:       minBytes,
+		Tree:          f.tree,
+		ByExt:         f.byExt,
+		Digests:       f.digests,
+		TopDirs:       f.topDirs,
+		DirsRecursive: f.dirsRecursive,
+		Command:       f.grep,
+		IgnoreCase:    f.ignoreCase,
+		Hardlinks:     f.dedupeHardlinks,
+		Xattrs:        f.xattrs,
+	}
+	if f.skipBase > 0 || len(f.excludeLayers) > 0 {
+		a.opts.Exclude = newLayerFilter(f.skipBase, f.excludeLayers)
+	}
+	width, err := parseLineWidth(f.lineWidth, f.outFile == "")
+	if err != nil {
+		return nil, err
+	}
+	a.text = TextOptions{
+		LineWidth:  width,
+		MaxEntries: f.maxFiles,
+		Long:       f.long,
+		Compact:    f.compact,
+		Truncate:   f.truncation,
+		Created:    f.showCreated,
+	}
+	if f.wide {
+		a.text.Truncate = truncateNone
+	}
+	a.analysis = dolay.Options{
+		Select:    selectImage(f.repoTag, f.imageIndex),
+		Jobs:      f.jobs,
+		NoHistory: f.noHistory,
+		Recurse:   f.recurse,
+	}
+	if a.window.enabled() {
+		a.analysis = a.window.keep(a.analysis)
+	}
+	if f.stream {
+		a.analysis.MaxFiles = a.opts.MaxFiles
+		a.analysis.Match = func(h *tar.Header) bool {
+			return a.opts.Filter.Match(h.Name) && uint64(h.Size) >= a.opts.MinSize
+		}
+	}
+	return a, nil
+}
+
+// run provides the analysis of each archive, and totals of the batch
+func (a *analyzer) run() error {
+	if len(a.paths) == 1 {
+		return a.analyzePath(a.paths[0])
+	}
+	if (a.output == outputJSON || a.output == outputImageJSON) && a.mode.name != modeTopCommands {
+		return a.runJSON()
+	}
+	for i, path := range a.paths {
+		if a.output == outputText && a.mode.name != modeTopCommands {
+			if i > 0 {
+				fmt.Fprintln(a.out)
+			}
+			fmt.Fprintln(a.out, theme.Header.Sprintf("archive: %s", path))
+		}
+		if err := a.analyzePath(path); err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+	}
+	if a.mode.name == modeTopCommands {
+		result := dolay.ByCommand(a.batchLayers)
+		if err := a.write(result, func() { printCommands(a.out, result, a.text) }); err != nil {
+			return err
+		}
+	}
+	if !a.batchSummary {
+		return nil
+	}
+	sortTotals(a.totals)
+	if a.output == outputJSON {
+		return writeJSON(a.out, a.totals)
+	}
+	fmt.Fprintln(a.out)
+	printTotals(a.out, a.totals, a.text)
+	return nil
+}
+
+// runJSON provides the analysis of each archive with the output
+// of their reports, and totals of the batch, as the single document
+func (a *analyzer) runJSON() error {
+	out := a.out
+	defer func() { a.out = out }()
+	var batch BatchReport
+	for _, path := range a.paths {
+		var buf bytes.Buffer
+		a.out = &buf
+		if err := a.analyzePath(path); err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		batch.Archives = append(batch.Archives, ArchiveResult{Archive: path, Report: buf.Bytes()})
+	}
+	if a.batchSummary {
+		sortTotals(a.totals)
+		batch.Totals = a.totals
+	}
+	return writeJSON(out, batch)
+}
+
+// checkBudget returns error if the image of the archive exceeds the budget
+func (a *analyzer) checkBudget(path string, summary Summary) error {
+	a.totals = append(a.totals, newArchiveTotal(path, summary))
+	return a.budget.Check(summary)
+}
+
+// analyzePath provides reading of the archive and printing of its report
+func (a *analyzer) analyzePath(path string) error {
+	analysis := a.analysis
+	var measure *timings
+	if a.timed {
+		measure = newTimings()
+		analysis = measure.track(analysis)
+		defer measure.print(os.Stderr)
+	}
+	r, err := openSource(path, a.image, a.ctrd)
+	if err != nil {
+		return err
+	}
+	if !a.quiet && !a.squashfs && logFormat == logText && isatty.IsTerminal(os.Stderr.Fd()) {
+		p := newProgress(r)
+		defer p.stop()
+		r = p
+		analysis = p.track(analysis)
+	}
+	// stop provides the end of timings of reading, which are
+	// printed after the report
+	stop := func() {
+		if measure != nil {
+			measure.stop()
+		}
+	}
+	switch {
+	case a.mode.name == modeVerify:
+		defer r.Close()
+		err := verifyDigests(a.out, r, analysis, a.output == outputJSON)
+		stop()
+		return err
+	case a.mode.name == modeValidate:
+		defer r.Close()
+		err := validateArchive(a.out, r, analysis, a.rawLayer)
+		stop()
+		return err
+	case a.mode.name != modeList || a.rawLayer || a.onlyAdded || a.squashfs:
+	case a.output == outputNDJSON && a.layerIndex < 0 && !a.largest:
+		defer r.Close()
+		summary, err := streamNDJSON(a.out, r, analysis, a.opts)
+		stop()
+		if err != nil {
+			return err
+		}
+		return a.checkBudget(path, summary)
+	case a.output == outputSummary && !a.effective:
+		defer r.Close()
+		summary, err := streamSummary(r, analysis, a.opts.Exclude)
+		stop()
+		if err != nil {
+			return err
+		}
+		if err := writeSummary(a.out, summary); err != nil {
+			return err
+		}
+		return a.checkBudget(path, summary)
+	}
+
+	var report *dolay.Report
+	switch {
+	case a.squashfs:
+		report, err = analyzeSquashfs(r)
+	case a.rawLayer:
+		report, err = analyzeLayer(r, nil)
+	case a.mode.name == modeTUI || a.layerIndex >= 0:
+		report, err = analyzeIndexed(r, analysis)
+	default:
+		report, err = analyze(r, nil, analysis)
+	}
+	if p, ok := r.(*progress); ok {
+		p.stop()
+	}
+	if err != nil {
+		return err
+	}
+	if measure != nil {
+		if a.rawLayer || a.squashfs {
+			measure.record(report.Layers[0])
+		}
+		measure.stop()
+	}
+	layers := report.Layers
+	if a.layerIndex >= len(layers) {
+		return fmt.Errorf("layer %d is out of range, image contains %d layers", a.layerIndex, len(layers))
+	}
+	if a.sinceLayer >= len(layers) {
+		return fmt.Errorf("layer %d is out of range, image contains %d layers", a.sinceLayer, len(layers))
+	}
+	if a.onlyAdded {
+		var inherited int
+		if layers, inherited = dolay.OnlyAdded(layers); inherited > 0 {
+			logf(levelInfo, map[string]interface{}{"files": inherited},
+				"layers are squashed, %d files inherited unchanged from lower layers are hidden", inherited)
+		}
+	}
+	layers, skipped := a.opts.Exclude.apply(layers)
+	if a.mode.name == modeTUI {
+		return runTUI(layers)
+	}
+	if err := a.render(path, report, layers, skipped); err != nil {
+		return err
+	}
+	if a.snapshot != "" {
+		// deltas are written to stderr, so machine output isn't broken
+		w := a.out
+		if a.output != outputText {
+			w = os.Stderr
+		}
+		if err := compareSnapshot(w, a.snapshot, layers, a.growth, a.updateSnapshot, a.text); err != nil {
+			return err
+		}
+	}
+	return a.checkBudget(path, summarize(layers))
+}
+
+// write provides writing of the result as JSON for json output,
+// or printing of it as text
+func (a *analyzer) write(result interface{}, print func()) error {
+	if a.output == outputJSON {
+		return writeJSON(a.out, result)
+	}
+	print()
+	return nil
+}
+
+// render provides printing of the report of the mode
+func (a *analyzer) render(path string, report *dolay.Report, layers []*dolay.Layer, skipped int) error {
+	switch a.mode.name {
+	case modeDiff:
+		other, err := loadArchive(a.diff, dolay.Options{})
+		if err != nil {
+			return fmt.Errorf("unable to read %s: %v", a.diff, err)
+		}
+		result := dolay.Diff(layers, other.Layers)
+		return a.write(result, func() { printDiff(a.out, result, a.text) })
+	case modeBrokenLinks:
+		result := dolay.BrokenLinks(layers)
+		return a.write(result, func() { printBrokenLinks(a.out, result, a.text) })
+	case modeSince:
+		result := dolay.Since(layers, a.sinceLayer)
+		return a.write(result, func() { printChanges(a.out, result, a.text) })
+	case modeDuplicates:
+		result := dolay.FindDuplicates(layers)
+		return a.write(result, func() { printDuplicates(a.out, result, a.text) })
+	case modeFilesOnly:
+		result := mergedFiles(layers, a.opts)
+		return a.write(result, func() { printFiles(a.out, result, a.text) })
+	case modeExtractTop:
+		return a.renderExtractTop(path, layers)
+	case modeChart:
+		printChart(a.out, layers, a.text)
+		return nil
+	case modeTopCommands:
+		if len(a.paths) > 1 {
+			a.batchLayers = append(a.batchLayers, layers...)
+			return nil
+		}
+		result := dolay.ByCommand(layers)
+		return a.write(result, func() { printCommands(a.out, result, a.text) })
+	case modeByOwner:
+		result := dolay.ByOwner(layers)
+		return a.write(result, func() { printOwners(a.out, result, a.text) })
+	case modeByInstruction:
+		result := dolay.ByInstruction(layers)
+		return a.write(result, func() { printInstructions(a.out, result, a.text) })
+	case modeRules:
+		result, err := dolay.Check(layers, a.rules)
+		if err != nil {
+			return err
+		}
+		return a.write(result, func() { printViolations(a.out, result, a.text) })
+	case modeLint:
+		result := dolay.Lint(layers, dolay.CacheRules)
+		return a.write(result, func() { printLint(a.out, result, a.text) })
+	case modeGlobalTop:
+		result := globalTop(layers, a.opts)
+		return a.write(result, func() { printGlobalTop(a.out, result, a.text) })
+	}
+	return a.renderList(report, layers, skipped)
+}
+
+// renderExtractTop provides writing of top files across layers to -output-dir.
+// Files are read by the second pass over the archive
+func (a *analyzer) renderExtractTop(path string, layers []*dolay.Layer) error {
+	limited := a.opts
+	limited.MaxFiles = a.extractTop
+	result := globalTop(layers, limited)
+	src, err := openSource(path, a.image, a.ctrd)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	if err := extractFiles(src, a.analysis, layers, result, a.outputDir); err != nil {
+		return fmt.Errorf("unable to extract files: %v", err)
+	}
+	return a.write(result, func() { printGlobalTop(a.out, result, a.text) })
+}
+
+// renderList provides printing of the listing of layers in the output format
+func (a *analyzer) renderList(report *dolay.Report, layers []*dolay.Layer, skipped int) error {
+	switch a.output {
+	case outputSummary:
+		summary := summarize(layers)
+		if a.effective {
+			merged := dolay.MergeStats(layers)
+			summary.Merged = &merged
+		}
+		return writeSummary(a.out, summary)
+	case outputPrometheus:
+		var tag string
+		if len(report.Manifest.RepoTags) > 0 {
+			tag = report.Manifest.RepoTags[0]
+		}
+		return writePrometheus(a.out, layers, tag)
+	}
+	reports := buildReports(layers, a.opts)
+	if a.showEmpty && a.opts.MinSize == 0 {
+		reports = withEmpty(reports, layers, report.History, a.opts)
+	}
+	if a.layerIndex >= 0 {
+		reports = selectLayer(reports, a.layerIndex)
+	}
+	if a.largest {
+		reports = selectLayer(reports, largestLayer(layers))
+	}
+	switch {
+	case a.selected != nil && a.output == outputJSON:
+		return writeFieldsJSON(a.out, reports, a.selected)
+	case a.selected != nil:
+		return writeFieldsCSV(a.out, reports, a.selected)
+	}
+	switch a.output {
+	case outputJSON:
+		return writeJSON(a.out, reports)
+	case outputImageJSON:
+		return writeJSON(a.out, newImageReport(report, reports, summarize(layers)))
+	case outputNDJSON:
+		enc := json.NewEncoder(a.out)
+		for _, r := range reports {
+			if err := enc.Encode(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	case outputCSV:
+		return writeCSV(a.out, reports)
+	case outputMarkdown:
+		return writeMarkdown(a.out, reports, summarize(layers))
+	}
+	if a.inspect {
+		printInspect(a.out, report.Image, a.text)
+	}
+	if a.showCreated && !report.Image.Created.IsZero() {
+		fmt.Fprintln(a.out, theme.Header.Sprintf("created: %s", created(report.Image.Created)))
+	}
+	if digest := dolay.Digest(report.Manifest.Config); a.digests && digest != "" {
+		fmt.Fprintln(a.out, theme.Header.Sprintf("config: %s", digest))
+	}
+	if a.largest && len(reports) > 0 {
+		fmt.Fprintln(a.out, theme.Header.Sprintf("largest layer: %d", reports[0].Index))
+	}
+	summary := summarize(layers)
+	summary.Skipped = skipped
+	if a.showAge {
+		summary.Built = report.Image.Created
+	}
+	if a.effective {
+		merged := dolay.MergeStats(layers)
+		summary.Merged = &merged
+	}
+	if a.dedupeHardlinks {
+		for _, l := range layers {
+			summary.Linked += dolay.Hardlinks(l.Files).Size
+		}
+	}
+	printText(a.out, reports, summary, a.text)
+	return nil
+}
+
+func main() {
+	if err := run(); err != nil {
+		fatal(err)
+	}
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/saromanov/dolay"
+	"github.com/ulikunitz/xz"
+)
+
+func TestOpenArchiveStdin(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "image.tar")
+	if err := os.WriteFile(path, []byte("archive"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	stdin, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stdin.Close()
+	saved := os.Stdin
+	os.Stdin = stdin
+	defer func() { os.Stdin = saved }()
+
+	r, err := openArchive("-")
+	if err != nil {
+		t.Fatalf("openArchive(-) error = %v", err)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil || string(data) != "archive" {
+		t.Errorf("openArchive(-) read %q, %v, want content of stdin", data, err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	// stdin isn't closed with the archive
+	if _, err := stdin.Stat(); err != nil {
+		t.Errorf("stdin is closed: %v", err)
+	}
+}
+
+// testTar returns tar archive of files with content of their sizes
+func testTar(t *testing.T, files ...*tar.Header) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, h := range files {
+		if err := tw.WriteHeader(h); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(make([]byte, h.Size)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestAnalyzeLayer(t *testing.T) {
+	data := testTar(t, testFile("etc/passwd", 100), testFile("usr/bin/curl", 300))
+	report, err := analyzeLayer(io.NopCloser(bytes.NewReader(data)), nil)
+	if err != nil {
+		t.Fatalf("analyzeLayer() error = %v", err)
+	}
+	if len(report.Layers) != 1 || report.Layers[0].Index != 0 || report.Layers[0].Size != 400 || len(report.History) != 0 {
+		t.Fatalf("analyzeLayer() = %+v, want the single layer of 400 bytes", report)
+	}
+	reports := buildReports(report.Layers, testReportOptions(t))
+	if len(reports) != 1 || reports[0].Command != "" || len(reports[0].Files) != 2 {
+		t.Errorf("reports of the layer = %+v", reports)
+	}
+}
+
+// testArchive returns archive of the image with the layers in the
+// layout of "docker save". Layer i is "<i>/layer.tar" created by
+// "/bin/sh -c step <i>"
+func testArchive(t *testing.T, layers ...[]byte) []byte {
+	t.Helper()
+	var config dolay.Image
+	item := dolay.ManifestItem{Config: "config.json", RepoTags: []string{"app:latest"}}
+	var entries [][2]string
+	for i, l := range layers {
+		config.History = append(config.History, dolay.History{Created: testTime, CreatedBy: fmt.Sprintf("/bin/sh -c step %d", i)})
+		path := fmt.Sprintf("%d/layer.tar", i)
+		item.Layers = append(item.Layers, path)
+		entries = append(entries, [2]string{path, string(l)})
+	}
+	data, err := json.Marshal(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := json.Marshal([]dolay.ManifestItem{item})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries = append(entries, [2]string{item.Config, string(data)}, [2]string{"manifest.json", string(manifest)})
+	return tarFiles(t, entries...)
+}
+
+// tarFiles returns tar archive of regular files by pairs of
+// their names and contents
+func tarFiles(t *testing.T, entries ...[2]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range entries {
+		if err := tw.WriteHeader(&tar.Header{Name: e[0], Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(e[1]))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(tw, e[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// writeArchive returns path of the file with the data in the directory
+func writeArchive(t *testing.T, dir, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// runAnalyzer returns output of the analysis of archives of the paths
+// with the flags like it's run from the command line
+func runAnalyzer(t *testing.T, f *cliFlags, paths ...string) (string, error) {
+	t.Helper()
+	withoutColor(t)
+	mode, err := f.selectMode()
+	if err != nil {
+		return "", err
+	}
+	if err := f.check(paths, mode); err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	a, err := newAnalyzer(f, mode, paths, &buf)
+	if err != nil {
+		return "", err
+	}
+	err = a.run()
+	return buf.String(), err
+}
+
+func TestRunCompressedArchive(t *testing.T) {
+	archive := testArchive(t, testTar(t, testFile("bin/busybox", 900)), testTar(t, testFile("app/main", 300)))
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	if _, err := zw.Write(archive); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	var xzData bytes.Buffer
+	xw, err := xz.NewWriter(&xzData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := xw.Write(archive); err != nil {
+		t.Fatal(err)
+	}
+	if err := xw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"image.tar", archive},
+		{"image.tar.gz", gz.Bytes()},
+		{"image.tar.xz", xzData.Bytes()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := testCLIFlags()
+			f.output = outputJSON
+			f.tarPath = writeArchive(t, dir, tt.name, tt.data)
+			out, err := runAnalyzer(t, f, f.tarPath)
+			if err != nil {
+				t.Fatalf("run() error = %v", err)
+			}
+			var reports []LayerReport
+			if err := json.Unmarshal([]byte(out), &reports); err != nil {
+				t.Fatalf("output isn't valid JSON: %v\n%s", err, out)
+			}
+			if len(reports) != 2 || reports[0].Size != 900 || reports[1].Command != "step 1" || reports[1].Files[0].Name != "app/main" {
+				t.Errorf("reports = %+v, want layers of bin/busybox and app/main", reports)
+			}
+		})
+	}
+}
+
+func TestRunDeterministic(t *testing.T) {
+	var files []*tar.Header
+	for _, name := range []string{"b.py", "a.so", "c.txt", "lib/d.so", "lib/e.py", "usr/f.txt", "usr/g"} {
+		files = append(files, testFile(name, 100))
+	}
+	path := writeArchive(t, t.TempDir(), "image.tar", testArchive(t, testTar(t, files...), testTar(t, files[:4]...), testTar(t, files[3:]...)))
+	tests := []struct {
+		name string
+		set  func(f *cliFlags)
+	}{
+		{"text", func(f *cliFlags) {}},
+		{"json by ext and dirs", func(f *cliFlags) { f.output, f.byExt, f.topDirs = outputJSON, true, true }},
+		{"tree", func(f *cliFlags) { f.tree = true }},
+		{"csv", func(f *cliFlags) { f.output = outputCSV }},
+		{"duplicates", func(f *cliFlags) { f.duplicates = true }},
+		{"global top", func(f *cliFlags) { f.top = true }},
+		{"by owner", func(f *cliFlags) { f.output, f.byOwner = outputJSON, true }},
+		{"by instruction", func(f *cliFlags) { f.byInstruction = true }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var first string
+			for i := 0; i < 5; i++ {
+				f := testCLIFlags()
+				f.tarPath = path
+				tt.set(f)
+				out, err := runAnalyzer(t, f, path)
+				if err != nil {
+					t.Fatalf("run() error = %v", err)
+				}
+				if i == 0 {
+					if out == "" {
+						t.Fatal("run() output is empty")
+					}
+					first = out
+					continue
+				}
+				if out != first {
+					t.Fatalf("run %d output differs:\n%s\nfirst run:\n%s", i, out, first)
+				}
+			}
+		})
+	}
+}
+
+func TestRunLongNames(t *testing.T) {
+	name := strings.Repeat("very-long-directory-name/", 12) + "lib/libexample.so.1.2.3"
+	dir := t.TempDir()
+	for _, format := range []tar.Format{tar.FormatPAX, tar.FormatGNU} {
+		t.Run(format.String(), func(t *testing.T) {
+			long := testFile(name, 700)
+			long.Format = format
+			f := testCLIFlags()
+			f.tarPath = writeArchive(t, dir, "image.tar", testArchive(t, testTar(t, testFile("bin/sh", 100), long)))
+			out, err := runAnalyzer(t, f, f.tarPath)
+			if err != nil {
+				t.Fatalf("run() error = %v", err)
+			}
+			if !strings.Contains(out, " 700 B") || !strings.Contains(out, name+"\n") {
+				t.Errorf("run() output doesn't list %s of 700 bytes:\n%s", name, out)
+			}
+		})
+	}
+}
+
+func TestRunDigests(t *testing.T) {
+	dir := t.TempDir()
+	hex := strings.Repeat("ab", 32)
+	layer := string(testTar(t, testFile("bin/busybox", 900)))
+	config := `{"history":[{"created_by":"/bin/sh -c #(nop) ADD file:abc in /"}]}`
+	tests := []struct {
+		name    string
+		archive []byte
+		want    []string
+	}{
+		{"content-addressed", tarFiles(t,
+			[2]string{hex + "/layer.tar", layer},
+			[2]string{hex + ".json", config},
+			[2]string{"manifest.json", fmt.Sprintf(`[{"Config":"%s.json","Layers":["%s/layer.tar"]}]`, hex, hex)}),
+			[]string{"sha256:" + hex, "config: sha256:" + hex}},
+		// names of legacy archives aren't digests
+		{"named", testArchive(t, []byte(layer)), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := testCLIFlags()
+			f.digests = true
+			f.tarPath = writeArchive(t, dir, tt.name+".tar", tt.archive)
+			out, err := runAnalyzer(t, f, f.tarPath)
+			if err != nil {
+				t.Fatalf("run() error = %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(out, want) {
+					t.Errorf("output doesn't contain %q:\n%s", want, out)
+				}
+			}
+			if tt.want == nil && strings.Contains(out, "config:") {
+				t.Errorf("output contains the config line without digest:\n%s", out)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// markdownReplacer escapes characters which break markdown tables
+var markdownReplacer = strings.NewReplacer("|", "\\|", "`", "\\`")
+
+// markdownCell returns text prepared for the markdown table cell
+func markdownCell(s string) string {
+	return markdownReplacer.Replace(singleLine(s))
+}
+
+// writeMarkdown provides output of reports as markdown with the summary
+// table of layers and a section with top files for each layer
+func writeMarkdown(w io.Writer, reports []LayerReport, summary Summary) error {
+	var b strings.Builder
+	b.WriteString("# Layers\n\n")
+	b.WriteString("| # | Size | Cumulative | Files | Command |\n")
+	b.WriteString("|---:|---:|---:|---:|---|\n")
+	for _, r := range reports {
+		fmt.Fprintf(&b, "| %d | %s | %s | %d | %s |\n", r.Index, formatBytes(r.Size),
+			formatBytes(r.CumulativeSize), r.FileCount, markdownCell(r.Command))
+	}
+	fmt.Fprintf(&b, "\nTotal: %s, %d layers, %d files\n", formatBytes(summary.Size), summary.Layers, summary.Files)
+
+	for _, r := range reports {
+		fmt.Fprintf(&b, "\n## Layer %d\n\n", r.Index)
+		if r.Command != "" {
+			fmt.Fprintf(&b, "`%s`\n\n", strings.Replace(singleLine(r.Command), "`", "'", -1))
+		}
+		b.WriteString("| Size | File |\n")
+		b.WriteString("|---:|---|\n")
+		for _, f := range r.Files {
+			fmt.Fprintf(&b, "| %s | %s |\n", formatBytes(uint64(f.Size)), markdownCell(displayName(f)))
+		}
+		for _, d := range r.Deleted {
+			fmt.Fprintf(&b, "| - | %s (deleted) |\n", markdownCell(d))
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/saromanov/dolay"
+)
+
+// Report modes, named by their flags
+const (
+	modeList          = "list"
+	modeVerify        = "-verify-digests"
+	modeValidate      = "-validate"
+	modeSchema        = "-schema"
+	modeTUI           = "-tui"
+	modeDiff          = "-diff"
+	modeBrokenLinks   = "-broken-links"
+	modeSince         = "-since-layer"
+	modeDuplicates    = "-duplicates"
+	modeFilesOnly     = "-files-only"
+	modeExtractTop    = "-extract-top"
+	modeChart         = "-chart"
+	modeTopCommands   = "-top-commands"
+	modeByOwner       = "-group-by-owner"
+	modeByInstruction = "-by-instruction"
+	modeRules         = "-rules"
+	modeLint          = "-lint"
+	modeGlobalTop     = "-global-top"
+)
+
+// textJSON defines output formats of reports other than the listing
+var textJSON = []string{outputText, outputJSON}
+
+// reportMode defines the report printed instead of the listing of layers
+type reportMode struct {
+	name string
+	// formats are supported output formats, all formats are supported if it's nil
+	formats []string
+	// allFiles is true if the report requires all files of layers, so can't be streamed
+	allFiles bool
+	set      func(f *cliFlags) bool
+}
+
+// supports returns true if the mode supports the output format
+func (m reportMode) supports(output string) bool {
+	if m.formats == nil {
+		return true
+	}
+	for _, format := range m.formats {
+		if format == output {
+			return true
+		}
+	}
+	return false
+}
+
+// listMode defines the listing of layers, which is printed if there is no other mode
+var listMode = reportMode{name: modeList}
+
+// reportModes defines modes selected by flags
+var reportModes = []reportMode{
+	{modeVerify, textJSON, false, func(f *cliFlags) bool { return f.verify }},
+	{modeValidate, nil, false, func(f *cliFlags) bool { return f.validate }},
+	{modeSchema, nil, false, func(f *cliFlags) bool { return f.printSchema }},
+	{modeTUI, nil, true, func(f *cliFlags) bool { return f.interactive }},
+	{modeDiff, textJSON, true, func(f *cliFlags) bool { return f.diff != "" }},
+	{modeBrokenLinks, textJSON, true, func(f *cliFlags) bool { return f.brokenLinks }},
+	{modeSince, textJSON, true, func(f *cliFlags) bool { return f.sinceLayer >= 0 }},
+	{modeDuplicates, textJSON, true, func(f *cliFlags) bool { return f.duplicates }},
+	{modeFilesOnly, textJSON, true, func(f *cliFlags) bool { return f.filesOnly }},
+	{modeExtractTop, textJSON, true, func(f *cliFlags) bool { return f.extractTop > 0 }},
+	{modeChart, []string{outputText}, false, func(f *cliFlags) bool { return f.chart }},
+	{modeTopCommands, textJSON, false, func(f *cliFlags) bool { return f.topCommands }},
+	{modeByOwner, textJSON, true, func(f *cliFlags) bool { return f.byOwner }},
+	{modeByInstruction, textJSON, false, func(f *cliFlags) bool { return f.byInstruction }},
+	{modeRules, textJSON, true, func(f *cliFlags) bool { return f.rulesFile != "" }},
+	{modeLint, textJSON, true, func(f *cliFlags) bool { return f.lint }},
+	{modeGlobalTop, textJSON, true, func(f *cliFlags) bool { return f.top }},
+}
+
+// selectMode returns the single mode set by flags, or the listing of layers.
+// Error is returned if several modes are set, or the mode doesn't support
+// the output format
+func (f *cliFlags) selectMode() (reportMode, error) {
+	switch f.output {
+	case outputText, outputJSON, outputNDJSON, outputCSV, outputMarkdown, outputSummary, outputPrometheus, outputImageJSON:
+	default:
+		return reportMode{}, fmt.Errorf("unknown output format: %s", f.output)
+	}
+	mode := listMode
+	var set []string
+	for _, m := range reportModes {
+		if m.set(f) {
+			mode = m
+			set = append(set, m.name)
+		}
+	}
+	if len(set) > 1 {
+		return reportMode{}, fmt.Errorf("%s can't be used together", strings.Join(set, ", "))
+	}
+	if !mode.supports(f.output) {
+		return reportMode{}, fmt.Errorf("%s output doesn't support %s", f.output, mode.name)
+	}
+	return mode, nil
+}
+
+// check returns error if flags conflict with each other or with the mode
+func (f *cliFlags) check(paths []string, mode reportMode) error {
+	local := f.image == "" && f.ctrd.Ref == ""
+	if len(paths) > 1 && (f.watch || mode.name == modeTUI) {
+		return fmt.Errorf("-watch and -tui require a single archive, %s matches %d", f.tarPath, len(paths))
+	}
+	if f.watch && !local {
+		return fmt.Errorf("-watch requires path of the archive file")
+	}
+	if mode.name == modeExtractTop {
+		if f.outputDir == "" {
+			return fmt.Errorf("-extract-top requires -output-dir")
+		}
+		if (f.tarPath == "-" && local) || f.rawLayer {
+			return fmt.Errorf("-extract-top requires the image archive, which can be read again")
+		}
+	}
+	if f.squashfs && (f.rawLayer || f.validate || f.verify || f.stream || f.extractTop > 0 || !local) {
+		return fmt.Errorf("-squashfs requires the file of the image and can't be used with -raw-layer, -validate, -verify-digests, -stream or -extract-top")
+	}
+	if f.verify && f.rawLayer {
+		return fmt.Errorf("-verify-digests requires the image archive")
+	}
+	if f.showEmpty && f.output != outputText && f.output != outputJSON && f.output != outputImageJSON {
+		return fmt.Errorf("%s output doesn't support -show-empty", f.output)
+	}
+	if f.fieldList != "" {
+		if f.output != outputJSON && f.output != outputCSV {
+			return fmt.Errorf("-fields is supported only by json and csv output")
+		}
+		if mode.name != modeList {
+			return fmt.Errorf("-fields supports only listing of layers")
+		}
+	}
+	if f.largest && f.layerIndex >= 0 {
+		return fmt.Errorf("-largest-layer can't be used with -layer")
+	}
+	switch f.truncation {
+	case truncateEnd, truncateMiddle, truncateNone:
+	default:
+		return fmt.Errorf("unknown truncation mode: %s", f.truncation)
+	}
+	if f.snapshot == "" && (f.maxGrowth != "" || f.updateSnapshot) {
+		return fmt.Errorf("-max-growth and -update-snapshot require -snapshot")
+	}
+	if f.snapshot != "" && (len(paths) > 1 || f.output == outputNDJSON || f.output == outputSummary) {
+		return fmt.Errorf("-snapshot requires a single archive and output other than ndjson or summary")
+	}
+	if !f.stream {
+		return nil
+	}
+	if f.sortKey != dolay.SortBySize || f.reverse {
+		return fmt.Errorf("-stream requires sorting of files by size")
+	}
+	if mode.allFiles {
+		return fmt.Errorf("-stream can't be used with %s, which requires all files", mode.name)
+	}
+	for _, m := range []struct {
+		name string
+		set  bool
+	}{
+		{"-tree", f.tree}, {"-by-ext", f.byExt}, {"-top-dirs", f.topDirs},
+		{"-dedupe-hardlinks", f.dedupeHardlinks}, {"-layer", f.layerIndex >= 0},
+		{"-after and -before", f.after != "" || f.before != ""},
+		{"-only-added", f.onlyAdded}, {"-effective", f.effective},
+	} {
+		if m.set {
+			return fmt.Errorf("-stream can't be used with %s, which requires all files", m.name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/saromanov/dolay"
+)
+
+// testCLIFlags returns flags with defaults of the command line
+func testCLIFlags() *cliFlags {
+	return &cliFlags{
+		tarPath:    "image.tar",
+		output:     outputText,
+		sinceLayer: -1,
+		layerIndex: -1,
+		sortKey:    dolay.SortBySize,
+		truncation: truncateEnd,
+		minSize:    "0",
+		lineWidth:  "100",
+		maxFiles:   10,
+	}
+}
+
+func TestSelectMode(t *testing.T) {
+	tests := []struct {
+		name string
+		set  func(f *cliFlags)
+		want string
+		err  string
+	}{
+		{"listing", func(f *cliFlags) {}, modeList, ""},
+		{"listing of csv", func(f *cliFlags) { f.output = outputCSV }, modeList, ""},
+		{"duplicates", func(f *cliFlags) { f.duplicates = true }, modeDuplicates, ""},
+		{"since layer 0", func(f *cliFlags) { f.sinceLayer = 0; f.output = outputJSON }, modeSince, ""},
+		{"tui of ndjson", func(f *cliFlags) { f.interactive = true; f.output = outputNDJSON }, modeTUI, ""},
+		{"two modes", func(f *cliFlags) { f.diff = "other.tar"; f.lint = true }, "", "-diff, -lint can't be used together"},
+		{"verify and validate", func(f *cliFlags) { f.verify, f.validate = true, true }, "", "-verify-digests, -validate can't be used together"},
+		{"chart of json", func(f *cliFlags) { f.chart = true; f.output = outputJSON }, "", "json output doesn't support -chart"},
+		{"diff of csv", func(f *cliFlags) { f.diff = "other.tar"; f.output = outputCSV }, "", "csv output doesn't support -diff"},
+		{"unknown output", func(f *cliFlags) { f.output = "yaml" }, "", "unknown output format: yaml"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := testCLIFlags()
+			tt.set(f)
+			mode, err := f.selectMode()
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("selectMode() error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("selectMode() error = %v", err)
+			}
+			if mode.name != tt.want {
+				t.Errorf("selectMode() = %s, want %s", mode.name, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckFlags(t *testing.T) {
+	tests := []struct {
+		name  string
+		set   func(f *cliFlags)
+		paths int
+		err   string
+	}{
+		{"listing", func(f *cliFlags) {}, 1, ""},
+		{"batch", func(f *cliFlags) { f.topCommands = true }, 3, ""},
+		{"tui of batch", func(f *cliFlags) { f.interactive = true }, 2, "-watch and -tui require a single archive"},
+		{"watch of daemon", func(f *cliFlags) { f.watch = true; f.image = "alpine" }, 1, "-watch requires path of the archive file"},
+		{"extract top", func(f *cliFlags) { f.extractTop = 3 }, 1, "-extract-top requires -output-dir"},
+		{"extract top of stdin", func(f *cliFlags) { f.extractTop, f.outputDir, f.tarPath = 3, "out", "-" }, 1, "requires the image archive"},
+		{"fields of text", func(f *cliFlags) { f.fieldList = "name" }, 1, "-fields is supported only by json and csv output"},
+		{"fields of report", func(f *cliFlags) { f.fieldList, f.output, f.lint = "name", outputJSON, true }, 1, "-fields supports only listing of layers"},
+		{"stream of mode", func(f *cliFlags) { f.stream, f.duplicates = true, true }, 1, "-stream can't be used with -duplicates"},
+		{"stream of tree", func(f *cliFlags) { f.stream, f.tree = true, true }, 1, "-stream can't be used with -tree"},
+		{"stream of chart", func(f *cliFlags) { f.stream, f.chart = true, true }, 1, ""},
+		{"stream by name", func(f *cliFlags) { f.stream, f.sortKey = true, dolay.SortByName }, 1, "-stream requires sorting of files by size"},
+		{"snapshot of batch", func(f *cliFlags) { f.snapshot = "dolay.lock" }, 2, "-snapshot requires a single archive"},
+		{"growth", func(f *cliFlags) { f.maxGrowth = "5%" }, 1, "-max-growth and -update-snapshot require -snapshot"},
+		{"truncation", func(f *cliFlags) { f.truncation = "start" }, 1, "unknown truncation mode: start"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := testCLIFlags()
+			tt.set(f)
+			mode, err := f.selectMode()
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = f.check(make([]string, tt.paths), mode)
+			if tt.err == "" {
+				if err != nil {
+					t.Errorf("check() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("check() error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
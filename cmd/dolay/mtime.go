@@ -0,0 +1,76 @@
+package main
+
+import (
+	"archive/tar"
+	"fmt"
+	"time"
+
+	"github.com/saromanov/dolay"
+)
+
+// timeWindow defines interval of modification times of listed files.
+// Zero bounds are open
+type timeWindow struct {
+	After  time.Time
+	Before time.Time
+	// zeroed is number of dropped files with zero modification time
+	zeroed int
+}
+
+// parseTime returns time by RFC3339 value or by duration before now,
+// like "24h". Empty value returns zero time
+func parseTime(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %s, expected RFC3339 or duration like 24h", value)
+	}
+	return t, nil
+}
+
+// enabled returns true if any bound is set
+func (w *timeWindow) enabled() bool {
+	return !w.After.IsZero() || !w.Before.IsZero()
+}
+
+// match returns true if the file was modified in the window. Build tools
+// zero modification times for reproducibility (SOURCE_DATE_EPOCH=0), and
+// such times are unknown, so these files are out of any window
+func (w *timeWindow) match(f *tar.Header) bool {
+	if f.ModTime.IsZero() || f.ModTime.Unix() == 0 {
+		w.zeroed++
+		return false
+	}
+	return (w.After.IsZero() || f.ModTime.After(w.After)) &&
+		(w.Before.IsZero() || f.ModTime.Before(w.Before))
+}
+
+// keep returns options which drop files out of the window from layers,
+// so sizes and counts of layers are of the files in the window
+func (w *timeWindow) keep(opts dolay.Options) dolay.Options {
+	keep := opts.Keep
+	opts.Keep = func(l *dolay.Layer) *dolay.Layer {
+		l.Files = l.FilterFiles(w.match)
+		l.Count = len(l.Files)
+		l.Size = l.TotalSize()
+		if keep != nil {
+			return keep(l)
+		}
+		return l
+	}
+	return opts
+}
+
+// warn provides the warning about files without modification time
+func (w *timeWindow) warn() {
+	if w.zeroed > 0 {
+		logf(levelWarn, map[string]interface{}{"files": w.zeroed},
+			"%d files with zero modification time are excluded by -after and -before", w.zeroed)
+		w.zeroed = 0
+	}
+}
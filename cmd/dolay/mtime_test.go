@@ -0,0 +1,89 @@
+package main
+
+import (
+	"archive/tar"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/saromanov/dolay"
+)
+
+func TestParseTime(t *testing.T) {
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Time
+		err   string
+	}{
+		{"", time.Time{}, ""},
+		{"24h", time.Date(2024, 3, 9, 12, 0, 0, 0, time.UTC), ""},
+		{"90m", time.Date(2024, 3, 10, 10, 30, 0, 0, time.UTC), ""},
+		{"2024-01-01T00:00:00Z", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), ""},
+		{"2024-01-01", time.Time{}, "invalid time 2024-01-01"},
+		{"yesterday", time.Time{}, "invalid time yesterday"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseTime(tt.value, now)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("parseTime() error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil || !got.Equal(tt.want) {
+				t.Errorf("parseTime() = %v, %v, want %v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestTimeWindow(t *testing.T) {
+	at := func(name string, mod time.Time) *tar.Header {
+		h := testFile(name, 100)
+		h.ModTime = mod
+		return h
+	}
+	files := []*tar.Header{
+		at("old", time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)),
+		at("bound", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)),
+		at("new", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)),
+		at("latest", time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)),
+		at("epoch", time.Unix(0, 0)),
+		at("zero", time.Time{}),
+	}
+	jan, mar := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		window timeWindow
+		want   []string
+	}{
+		{"after", timeWindow{After: jan}, []string{"new", "latest"}},
+		{"before", timeWindow{Before: mar}, []string{"old", "bound", "new"}},
+		{"both", timeWindow{After: jan, Before: mar}, []string{"new"}},
+		{"after the latest", timeWindow{After: mar}, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			layer := testLayer(0, "/bin/sh -c make", files...)
+			w := tt.window
+			layer = w.keep(dolay.Options{}).Keep(layer)
+			got := []string{}
+			for _, f := range layer.Files {
+				got = append(got, f.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("files in the window = %v, want %v", got, tt.want)
+			}
+			if layer.Count != len(tt.want) || layer.Size != uint64(100*len(tt.want)) {
+				t.Errorf("layer = %d files of %d bytes, want %d files", layer.Count, layer.Size, len(tt.want))
+			}
+			// files with zero times are out of any window
+			if w.zeroed != 2 {
+				t.Errorf("zeroed = %d, want 2", w.zeroed)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/saromanov/dolay"
+)
+
+// progressInterval defines how often the progress line is updated
+const progressInterval = 200 * time.Millisecond
+
+// progress provides counting of bytes read from the archive
+// and of read layers, with periodic output of them to stderr
+type progress struct {
+	r      io.ReadCloser
+	read   int64
+	layers int64
+	done   chan struct{}
+	wg     sync.WaitGroup
+	once   sync.Once
+}
+
+// newProgress returns reader which counts bytes of r
+// and starts output of the progress line
+func newProgress(r io.ReadCloser) *progress {
+	p := &progress{r: r, done: make(chan struct{})}
+	p.wg.Add(1)
+	go p.loop()
+	return p
+}
+
+// Read provides reading from the underlying reader
+func (p *progress) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	atomic.AddInt64(&p.read, int64(n))
+	return n, err
+}
+
+// Close provides closing of the underlying reader
+func (p *progress) Close() error {
+	return p.r.Close()
+}
+
+// track returns options which count layers after they are read
+func (p *progress) track(opts dolay.Options) dolay.Options {
+	keep := opts.Keep
+	opts.Keep = func(l *dolay.Layer) *dolay.Layer {
+		atomic.AddInt64(&p.layers, 1)
+		if keep != nil {
+			return keep(l)
+		}
+		return l
+	}
+	return opts
+}
+
+func (p *progress) loop() {
+	defer p.wg.Done()
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			fmt.Fprintf(os.Stderr, "\r\x1b[Kread %s, %d layers",
+				humanize.Bytes(uint64(atomic.LoadInt64(&p.read))), atomic.LoadInt64(&p.layers))
+		case <-p.done:
+			fmt.Fprint(os.Stderr, "\r\x1b[K")
+			return
+		}
+	}
+}
+
+// stop provides stopping of the output and clearing of the
+// progress line. It can be called several times
+func (p *progress) stop() {
+	p.once.Do(func() {
+		close(p.done)
+		p.wg.Wait()
+	})
+}
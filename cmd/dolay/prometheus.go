@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/saromanov/dolay"
+)
+
+// labelReplacer escapes label values of the text exposition format
+var labelReplacer = strings.NewReplacer("\\", "\\\\", "\"", "\\\"", "\n", "\\n")
+
+// labels returns labels of the metric, pairs are name and value.
+// Pairs with empty value are skipped
+func labels(pairs ...string) string {
+	var parts []string
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if pairs[i+1] == "" {
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%s=\"%s\"", pairs[i], labelReplacer.Replace(pairs[i+1])))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+// writePrometheus provides output of sizes of the image and of its
+// layers in the Prometheus text exposition format, so it can be
+// collected by the textfile collector of node_exporter
+func writePrometheus(w io.Writer, layers []*dolay.Layer, tag string) error {
+	var b strings.Builder
+	metric := func(name, help string) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+	}
+	summary := summarize(layers)
+	image := labels("image", tag)
+
+	metric("dolay_image_total_bytes", "Total size of files of the image layers.")
+	fmt.Fprintf(&b, "dolay_image_total_bytes%s %d\n", image, summary.Size)
+	metric("dolay_image_layers", "Number of layers of the image.")
+	fmt.Fprintf(&b, "dolay_image_layers%s %d\n", image, summary.Layers)
+	metric("dolay_image_files", "Number of files of the image layers.")
+	fmt.Fprintf(&b, "dolay_image_files%s %d\n", image, summary.Files)
+
+	metric("dolay_layer_bytes", "Total size of files of the layer.")
+	for _, l := range layers {
+		fmt.Fprintf(&b, "dolay_layer_bytes%s %d\n", labels("image", tag, "index", fmt.Sprint(l.Index)), l.Size)
+	}
+	metric("dolay_layer_files", "Number of files of the layer.")
+	for _, l := range layers {
+		fmt.Fprintf(&b, "dolay_layer_files%s %d\n", labels("image", tag, "index", fmt.Sprint(l.Index)), fileCount(l))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
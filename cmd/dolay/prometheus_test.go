@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/saromanov/dolay"
+)
+
+var (
+	// sampleLine matches a sample of the text exposition format
+	sampleLine = regexp.MustCompile(`^([a-zA-Z_:][a-zA-Z0-9_:]*)(\{(.*)\})? (\S+)$`)
+	// labelPair matches a label with the escaped value
+	labelPair = regexp.MustCompile(`^([a-zA-Z_][a-zA-Z0-9_]*)="((?:[^"\\]|\\.)*)"(,|$)`)
+)
+
+// parseExposition returns samples of the text exposition format by their
+// names with labels, and fails if a line isn't of the format or a sample
+// has no # HELP and # TYPE lines before it
+func parseExposition(t *testing.T, text string) map[string]float64 {
+	t.Helper()
+	samples := map[string]float64{}
+	help, types := map[string]bool{}, map[string]bool{}
+	sc := bufio.NewScanner(strings.NewReader(text))
+	for n := 1; sc.Scan(); n++ {
+		line := sc.Text()
+		if strings.HasPrefix(line, "# ") {
+			fields := strings.SplitN(line, " ", 4)
+			if len(fields) != 4 {
+				t.Fatalf("line %d: invalid comment %q", n, line)
+			}
+			switch fields[1] {
+			case "HELP":
+				help[fields[2]] = true
+			case "TYPE":
+				if fields[3] != "gauge" {
+					t.Errorf("line %d: type of %s = %s, want gauge", n, fields[2], fields[3])
+				}
+				types[fields[2]] = true
+			default:
+				t.Fatalf("line %d: unknown comment %q", n, line)
+			}
+			continue
+		}
+		m := sampleLine.FindStringSubmatch(line)
+		if m == nil {
+			t.Fatalf("line %d: invalid sample %q", n, line)
+		}
+		if !help[m[1]] || !types[m[1]] {
+			t.Errorf("line %d: sample of %s without # HELP and # TYPE", n, m[1])
+		}
+		for rest := m[3]; rest != ""; {
+			pair := labelPair.FindStringSubmatch(rest)
+			if pair == nil {
+				t.Fatalf("line %d: invalid labels %q", n, m[3])
+			}
+			rest = rest[len(pair[0]):]
+		}
+		value, err := strconv.ParseFloat(m[4], 64)
+		if err != nil {
+			t.Fatalf("line %d: invalid value: %v", n, err)
+		}
+		samples[m[1]+m[2]] = value
+	}
+	return samples
+}
+
+func TestWritePrometheus(t *testing.T) {
+	layers := []*dolay.Layer{
+		testLayer(0, "/bin/sh -c #(nop) ADD file:abc in /", testFile("bin/sh", 100), testFile("bin/busybox", 900)),
+		testLayer(3, "/bin/sh -c apk add curl", testFile("usr/bin/curl", 300)),
+	}
+	tests := []struct {
+		name string
+		tag  string
+		want map[string]float64
+	}{
+		{
+			name: "tagged",
+			tag:  "app:latest",
+			want: map[string]float64{
+				`dolay_image_total_bytes{image="app:latest"}`:     1300,
+				`dolay_image_layers{image="app:latest"}`:          2,
+				`dolay_image_files{image="app:latest"}`:           3,
+				`dolay_layer_bytes{image="app:latest",index="0"}`: 1000,
+				`dolay_layer_bytes{image="app:latest",index="3"}`: 300,
+				`dolay_layer_files{image="app:latest",index="0"}`: 2,
+				`dolay_layer_files{image="app:latest",index="3"}`: 1,
+			},
+		},
+		{
+			name: "without tag",
+			want: map[string]float64{
+				"dolay_image_total_bytes":      1300,
+				"dolay_image_layers":           2,
+				"dolay_image_files":            3,
+				`dolay_layer_bytes{index="0"}`: 1000,
+				`dolay_layer_bytes{index="3"}`: 300,
+				`dolay_layer_files{index="0"}`: 2,
+				`dolay_layer_files{index="3"}`: 1,
+			},
+		},
+		{
+			name: "escaped tag",
+			tag:  "a\"b\\c\nd",
+			want: map[string]float64{
+				`dolay_image_total_bytes{image="a\"b\\c\nd"}`:     1300,
+				`dolay_image_layers{image="a\"b\\c\nd"}`:          2,
+				`dolay_image_files{image="a\"b\\c\nd"}`:           3,
+				`dolay_layer_bytes{image="a\"b\\c\nd",index="0"}`: 1000,
+				`dolay_layer_bytes{image="a\"b\\c\nd",index="3"}`: 300,
+				`dolay_layer_files{image="a\"b\\c\nd",index="0"}`: 2,
+				`dolay_layer_files{image="a\"b\\c\nd",index="3"}`: 1,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := writePrometheus(&buf, layers, tt.tag); err != nil {
+				t.Fatalf("writePrometheus() error = %v", err)
+			}
+			got := parseExposition(t, buf.String())
+			if len(got) != len(tt.want) {
+				t.Errorf("writePrometheus() samples = %v, want %v", got, tt.want)
+			}
+			for name, value := range tt.want {
+				if v, ok := got[name]; !ok || v != value {
+					t.Errorf("sample %s = %v, want %v", name, v, value)
+				}
+			}
+		})
+	}
+}
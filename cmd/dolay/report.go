@@ -0,0 +1,540 @@
+package main
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/saromanov/dolay"
+)
+
+// FileEntry defines file record at the layer report
+type FileEntry struct {
+	Name    string `json:"name"`
+	Size    int64  `json:"size"`
+	Mode    string `json:"mode"`
+	Special bool   `json:"special,omitempty"`
+	UID     int    `json:"uid"`
+	GID     int    `json:"gid"`
+	Uname   string `json:"uname,omitempty"`
+	Gname   string `json:"gname,omitempty"`
+	// Link is target of the symlink or the hardlink
+	Link     string `json:"link,omitempty"`
+	Hardlink bool   `json:"hardlink,omitempty"`
+	// Xattrs contains extended attributes with decoded capabilities
+	Xattrs map[string]string `json:"xattrs,omitempty"`
+}
+
+// newFileEntry returns record of the file from tar header
+func newFileEntry(f *tar.Header) FileEntry {
+	e := FileEntry{
+		Name:    f.Name,
+		Size:    f.Size,
+		Mode:    dolay.Permissions(f),
+		Special: dolay.HasSpecialBits(f),
+		UID:     f.Uid,
+		GID:     f.Gid,
+		Uname:   f.Uname,
+		Gname:   f.Gname,
+	}
+	switch f.Typeflag {
+	case tar.TypeSymlink:
+		e.Link = f.Linkname
+	case tar.TypeLink:
+		e.Link = f.Linkname
+		e.Hardlink = true
+	}
+	return e
+}
+
+// LayerReport defines result of analysis for the single layer.
+// It's a stable schema for the machine-readable output
+type LayerReport struct {
+	Index   int    `json:"index"`
+	Digest  string `json:"digest,omitempty"`
+	Command string `json:"command"`
+	// Created is time of the layer creation if it's known
+	Created *time.Time `json:"created,omitempty"`
+	Size    uint64     `json:"size"`
+	// BlobSize is size of the layer in the archive
+	BlobSize    uint64 `json:"blob_size,omitempty"`
+	Compression string `json:"compression,omitempty"`
+	// CumulativeSize is size of the image up to and including the layer
+	CumulativeSize uint64      `json:"cumulative_size"`
+	FileCount      int         `json:"file_count"`
+	DirCount       int         `json:"dir_count"`
+	Files          []FileEntry `json:"files"`
+	Deleted        []string    `json:"deleted,omitempty"`
+	Tree           *dolay.Node `json:"tree,omitempty"`
+	// Extensions contains top extensions of files by total size
+	Extensions []dolay.ExtensionStat `json:"extensions,omitempty"`
+	// TopDirs contains top directories of files by total size
+	TopDirs []dolay.DirStat `json:"top_dirs,omitempty"`
+	// Hardlinks contains hardlinks of the layer with size of their targets
+	Hardlinks *dolay.HardlinkStat `json:"hardlinks,omitempty"`
+	// Empty marks history record which didn't change the filesystem
+	// (like ENV or LABEL). Its index is index of the layer above it
+	Empty bool `json:"empty,omitempty"`
+	// Archives contains tar archives among files of the layer
+	Archives []ArchiveReport `json:"archives,omitempty"`
+}
+
+// ArchiveReport defines report of the tar archive inside of the layer
+type ArchiveReport struct {
+	Name      string          `json:"name"`
+	Size      uint64          `json:"size"`
+	FileCount int             `json:"file_count"`
+	Files     []FileEntry     `json:"files"`
+	Archives  []ArchiveReport `json:"archives,omitempty"`
+}
+
+// archiveReports returns reports with top files of archives
+func archiveReports(archives []*dolay.Archive, opts ReportOptions) []ArchiveReport {
+	var reports []ArchiveReport
+	for _, a := range archives {
+		listed := append(dolay.Files(nil), a.Files...)
+		listed.SortBy(opts.Order, opts.Reverse)
+		listed = listed[:limit(opts.MaxFiles, len(listed))]
+		files := make([]FileEntry, 0, len(listed))
+		for _, f := range listed {
+			files = append(files, newFileEntry(f))
+		}
+		reports = append(reports, ArchiveReport{
+			Name:      a.Name,
+			Size:      a.Size,
+			FileCount: len(a.Files),
+			Files:     files,
+			Archives:  archiveReports(a.Archives, opts),
+		})
+	}
+	return reports
+}
+
+// ImageReport defines result of analysis for the whole image.
+// It's a stable schema for the image-json output
+type ImageReport struct {
+	RepoTags []string `json:"repo_tags,omitempty"`
+	// Config is digest of the image config
+	Config       string     `json:"config,omitempty"`
+	Created      *time.Time `json:"created,omitempty"`
+	Architecture string     `json:"architecture,omitempty"`
+	OS           string     `json:"os,omitempty"`
+	TotalSize    uint64     `json:"total_size"`
+	// BlobSize is size of compressed layers in the archive
+	BlobSize   uint64        `json:"blob_size,omitempty"`
+	LayerCount int           `json:"layer_count"`
+	FileCount  int           `json:"file_count"`
+	Layers     []LayerReport `json:"layers"`
+}
+
+// newImageReport returns report of the image with reports of layers
+func newImageReport(report *dolay.Report, layers []LayerReport, summary Summary) ImageReport {
+	r := ImageReport{
+		RepoTags:     report.Manifest.RepoTags,
+		Architecture: report.Image.Architecture,
+		OS:           report.Image.OS,
+		TotalSize:    summary.Size,
+		BlobSize:     summary.BlobSize,
+		LayerCount:   summary.Layers,
+		FileCount:    summary.Files,
+		Layers:       layers,
+	}
+	if report.Manifest.Config != "" {
+		r.Config = dolay.Digest(report.Manifest.Config)
+	}
+	if !report.Image.Created.IsZero() {
+		created := report.Image.Created
+		r.Created = &created
+	}
+	return r
+}
+
+// ReportOptions defines options of the reports building
+type ReportOptions struct {
+	MaxFiles      int
+	ShowWhiteouts bool
+	Order         dolay.FileOrder
+	Reverse       bool
+	Filter        *dolay.PathFilter
+	// MinSize hides files smaller than it, and layers
+	// without such files. Zero disables the threshold
+	MinSize uint64
+	// Tree builds tree of the listed files
+	Tree bool
+	// ByExt groups listed files by extension
+	ByExt bool
+	// Digests adds digests of layers
+	Digests bool
+	// TopDirs groups listed files by directory,
+	// including subdirectories if DirsRecursive is set
+	TopDirs       bool
+	DirsRecursive bool
+	// Command shows only layers with the created_by record containing
+	// it, case-insensitively if IgnoreCase is set
+	Command    string
+	IgnoreCase bool
+	// Hardlinks adds hardlinks of layers with size of their targets
+	Hardlinks bool
+	// Exclude drops layers from reports and totals
+	Exclude *layerFilter
+	// Xattrs adds extended attributes of files
+	Xattrs bool
+}
+
+// matchCommand returns true if layer with the created_by record is shown
+func (o ReportOptions) matchCommand(createdBy string) bool {
+	if o.IgnoreCase {
+		return strings.Contains(strings.ToLower(createdBy), strings.ToLower(o.Command))
+	}
+	return strings.Contains(createdBy, o.Command)
+}
+
+// Summary defines totals over all layers of the image
+type Summary struct {
+	Size uint64
+	// BlobSize is size of compressed layers in the archive,
+	// and it's zero if no layers are compressed
+	BlobSize uint64
+	Layers   int
+	Files    int
+	// Largest is size of the largest layer
+	Largest uint64
+	// Linked is size of hardlink targets, which is counted additionally
+	// when logical size of every link is summed
+	Linked uint64
+	// Skipped is number of layers excluded from totals
+	Skipped int
+	// Built is creation time of the image, which is shown as
+	// its age if it's set
+	Built time.Time
+	// Merged contains totals of the filesystem composed from layers
+	// if they are requested
+	Merged *dolay.MergeStat
+}
+
+// add provides counting of the layer in the summary
+func (s *Summary) add(l *dolay.Layer, files int) {
+	s.Size += l.Size
+	if l.Compression != "" {
+		s.BlobSize += l.BlobSize
+	}
+	if l.Size > s.Largest {
+		s.Largest = l.Size
+	}
+	s.Files += files
+	s.Layers++
+}
+
+// buildReports returns reports with top files for each layer
+func buildReports(layers []*dolay.Layer, opts ReportOptions) []LayerReport {
+	reports := make([]LayerReport, 0, len(layers))
+	var cumulative uint64
+	for _, layer := range layers {
+		cumulative += layer.Size
+		if !opts.matchCommand(layer.History.CreatedBy) {
+			continue
+		}
+		report, ok := buildReport(layer, opts)
+		if !ok {
+			continue
+		}
+		report.CumulativeSize = cumulative
+		reports = append(reports, report)
+	}
+	return reports
+}
+
+// withEmpty returns reports with records of empty layers placed in
+// order of history. Layers are matched with non-empty records in order,
+// so empty record goes before the layer of the next non-empty record
+func withEmpty(reports []LayerReport, layers []*dolay.Layer, history []dolay.History, opts ReportOptions) []LayerReport {
+	result := make([]LayerReport, 0, len(reports)+len(history))
+	sizes := make(map[int]uint64, len(layers))
+	for _, l := range layers {
+		sizes[l.Index] = l.Size
+	}
+	var cumulative uint64
+	next := 0
+	for _, action := range history {
+		if !action.EmptyLayer {
+			cumulative += sizes[next]
+			next++
+			continue
+		}
+		for len(reports) > 0 && reports[0].Index < next {
+			result = append(result, reports[0])
+			reports = reports[1:]
+		}
+		if !opts.matchCommand(action.CreatedBy) || (opts.Exclude != nil && next < opts.Exclude.base) {
+			continue
+		}
+		report := LayerReport{
+			Index:          next,
+			Command:        dolay.Command(action),
+			CumulativeSize: cumulative,
+			Files:          []FileEntry{},
+			Empty:          true,
+		}
+		if !action.Created.IsZero() {
+			t := action.Created
+			report.Created = &t
+		}
+		result = append(result, report)
+	}
+	return append(result, reports...)
+}
+
+// selectLayer returns report of the layer by index
+func selectLayer(reports []LayerReport, index int) []LayerReport {
+	for _, r := range reports {
+		if r.Index == index && !r.Empty {
+			return []LayerReport{r}
+		}
+	}
+	return []LayerReport{}
+}
+
+// fileCount returns number of files of the layer, including
+// files which are not held with -stream
+func fileCount(l *dolay.Layer) int {
+	if l.Count > len(l.Files) {
+		return l.Count
+	}
+	return len(l.Files)
+}
+
+// largestLayer returns index of the largest layer, the lowest one
+// if several layers have the same size, or -1 without layers
+func largestLayer(layers []*dolay.Layer) int {
+	index := -1
+	var size uint64
+	for _, l := range layers {
+		if index < 0 || l.Size > size {
+			index, size = l.Index, l.Size
+		}
+	}
+	return index
+}
+
+// limit returns number of the top n entries out of length
+// entries. Negative n limits entries to none
+func limit(n, length int) int {
+	if n < 0 {
+		return 0
+	}
+	if n > length {
+		return length
+	}
+	return n
+}
+
+// buildReport returns report with top files of the layer.
+// false is returned if layer is hidden by the size threshold
+func buildReport(layer *dolay.Layer, opts ReportOptions) (LayerReport, bool) {
+	listed := layer.FilterFiles(func(f *tar.Header) bool {
+		return opts.Filter.Match(f.Name) && uint64(f.Size) >= opts.MinSize
+	})
+	if opts.MinSize > 0 && len(listed) == 0 {
+		return LayerReport{}, false
+	}
+	listed.SortBy(opts.Order, opts.Reverse)
+	top := listed[:limit(opts.MaxFiles, len(listed))]
+	files := make([]FileEntry, 0, len(top))
+	for _, f := range top {
+		e := newFileEntry(f)
+		if opts.Xattrs {
+			e.Xattrs = dolay.Xattrs(f)
+		}
+		files = append(files, e)
+	}
+	var deleted []string
+	if opts.ShowWhiteouts {
+		for _, w := range layer.Whiteouts {
+			target, opaque := dolay.WhiteoutTarget(w.Name)
+			if opaque {
+				target += "/*"
+			}
+			deleted = append(deleted, target)
+		}
+		sort.Strings(deleted)
+	}
+	var tree *dolay.Node
+	if opts.Tree {
+		tree = dolay.NewTree(listed).Collapse()
+	}
+	var extensions []dolay.ExtensionStat
+	if opts.ByExt {
+		extensions = dolay.ByExtension(listed)
+		extensions = extensions[:limit(opts.MaxFiles, len(extensions))]
+	}
+	var dirs []dolay.DirStat
+	if opts.TopDirs {
+		dirs = dolay.ByDirectory(listed, opts.DirsRecursive)
+		dirs = dirs[:limit(opts.MaxFiles, len(dirs))]
+	}
+	var created *time.Time
+	if !layer.History.Created.IsZero() {
+		created = &layer.History.Created
+	}
+	var digest string
+	if opts.Digests {
+		digest = dolay.Digest(layer.Path)
+	}
+	var links *dolay.HardlinkStat
+	if opts.Hardlinks {
+		stat := dolay.Hardlinks(layer.Files)
+		links = &stat
+	}
+	return LayerReport{
+		Index:       layer.Index,
+		Digest:      digest,
+		Created:     created,
+		Command:     dolay.Command(layer.History),
+		Size:        layer.Size,
+		BlobSize:    layer.BlobSize,
+		Compression: layer.Compression,
+		FileCount:   fileCount(layer),
+		DirCount:    layer.Dirs,
+		Files:       files,
+		Deleted:     deleted,
+		Tree:        tree,
+		Extensions:  extensions,
+		TopDirs:     dirs,
+		Hardlinks:   links,
+		Archives:    archiveReports(layer.Archives, opts),
+	}, true
+}
+
+// streamNDJSON provides output of reports as JSON object per line.
+// Report is built right after the layer is read, so only reports
+// are held in memory instead of all files of layers
+func streamNDJSON(w io.Writer, r io.Reader, analysis dolay.Options, opts ReportOptions) (Summary, error) {
+	type pending struct {
+		report LayerReport
+		ok     bool
+		files  int
+	}
+	reports := make(map[string]pending)
+	keep := analysis.Keep
+	analysis.Keep = func(l *dolay.Layer) *dolay.Layer {
+		if keep != nil {
+			l = keep(l)
+		}
+		report, ok := buildReport(l, opts)
+		reports[l.Path] = pending{report, ok, fileCount(l)}
+		return &dolay.Layer{
+			Path:        l.Path,
+			Size:        l.Size,
+			Dirs:        l.Dirs,
+			DecodeTime:  l.DecodeTime,
+			Compression: l.Compression,
+			BlobSize:    l.BlobSize,
+		}
+	}
+	enc := json.NewEncoder(w)
+	var summary Summary
+	err := dolay.Stream(r, analysis, func(l *dolay.Layer) error {
+		if opts.Exclude.excluded(l) {
+			summary.Skipped++
+			return nil
+		}
+		p := reports[l.Path]
+		summary.add(l, p.files)
+		if !p.ok || !opts.matchCommand(l.History.CreatedBy) {
+			return nil
+		}
+		report := p.report
+		report.Index = l.Index
+		report.BlobSize = l.BlobSize
+		report.Compression = l.Compression
+		report.Command = dolay.Command(l.History)
+		if !l.History.Created.IsZero() {
+			created := l.History.Created
+			report.Created = &created
+		}
+		report.CumulativeSize = summary.Size
+		return enc.Encode(report)
+	})
+	return summary, err
+}
+
+// GlobalEntry defines file record of the image-wide listing
+type GlobalEntry struct {
+	FileEntry
+	// Layer is index of the layer which contains the file
+	Layer int `json:"layer"`
+}
+
+// globalTop returns top files across all layers of the image
+func globalTop(layers []*dolay.Layer, opts ReportOptions) []GlobalEntry {
+	var listed dolay.Files
+	origin := make(map[*tar.Header]int)
+	for _, layer := range layers {
+		for _, f := range layer.Files {
+			if opts.Filter.Match(f.Name) && uint64(f.Size) >= opts.MinSize {
+				listed = append(listed, f)
+				origin[f] = layer.Index
+			}
+		}
+	}
+	listed.SortBy(opts.Order, opts.Reverse)
+	listed = listed[:limit(opts.MaxFiles, len(listed))]
+	entries := make([]GlobalEntry, 0, len(listed))
+	for _, f := range listed {
+		entries = append(entries, GlobalEntry{FileEntry: newFileEntry(f), Layer: origin[f]})
+	}
+	return entries
+}
+
+// mergedFiles returns files of the final filesystem of the image
+// by the filter and the size threshold
+func mergedFiles(layers []*dolay.Layer, opts ReportOptions) []FileEntry {
+	files := []FileEntry{}
+	for _, f := range dolay.Merge(layers) {
+		if opts.Filter.Match(f.Name) && uint64(f.Size) >= opts.MinSize {
+			files = append(files, newFileEntry(f))
+		}
+	}
+	return files
+}
+
+// Budget defines limits of the image, which fail the run
+// when they are exceeded. Zero disables the limit
+type Budget struct {
+	MaxSize   uint64
+	MaxLayers int
+}
+
+// Check returns error if the image exceeds the budget
+func (b Budget) Check(s Summary) error {
+	if b.MaxSize > 0 && s.Size > b.MaxSize {
+		return fmt.Errorf("total size %s exceeds the limit %s", humanize.Bytes(s.Size), humanize.Bytes(b.MaxSize))
+	}
+	if b.MaxLayers > 0 && s.Layers > b.MaxLayers {
+		return fmt.Errorf("image has %d layers, which exceeds the limit %d", s.Layers, b.MaxLayers)
+	}
+	return nil
+}
+
+// summarize returns totals over layers of the image.
+// Layers contain only non-empty history entries, so
+// empty ones are not counted
+func summarize(layers []*dolay.Layer) Summary {
+	var s Summary
+	for _, l := range layers {
+		s.add(l, fileCount(l))
+	}
+	return s
+}
+
+// writeJSON provides output of the value as indented JSON
+func writeJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/saromanov/dolay"
+)
+
+// testTime defines creation time of generated layers
+var testTime = time.Date(2020, 9, 13, 12, 0, 0, 0, time.UTC)
+
+// testFile returns header of the regular file
+func testFile(name string, size int64) *tar.Header {
+	return &tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: size, ModTime: testTime}
+}
+
+// testLayer returns layer of the files created by the command
+func testLayer(index int, command string, files ...*tar.Header) *dolay.Layer {
+	l := &dolay.Layer{
+		Index:   index,
+		Path:    "layer.tar",
+		Files:   files,
+		Count:   len(files),
+		History: dolay.History{Created: testTime, CreatedBy: command},
+	}
+	l.Size = l.TotalSize()
+	return l
+}
+
+// testReportOptions returns options of reports with files sorted by size
+func testReportOptions(t *testing.T) ReportOptions {
+	t.Helper()
+	order, err := dolay.OrderBy(dolay.SortBySize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ReportOptions{MaxFiles: 10, Order: order}
+}
+
+func TestWriteJSON(t *testing.T) {
+	layers := []*dolay.Layer{
+		testLayer(0, "/bin/sh -c #(nop) ADD file:abc in /", testFile("bin/sh", 100), testFile("bin/busybox", 900)),
+		testLayer(1, "/bin/sh -c apk add curl", testFile("usr/bin/curl", 300)),
+	}
+	reports := buildReports(layers, testReportOptions(t))
+	var buf bytes.Buffer
+	if err := writeJSON(&buf, reports); err != nil {
+		t.Fatalf("writeJSON() error = %v", err)
+	}
+	if bytes.Contains(buf.Bytes(), []byte("\x1b[")) {
+		t.Errorf("writeJSON() output contains escape sequences: %s", buf.String())
+	}
+	var decoded []LayerReport
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("output isn't valid JSON: %v\n%s", err, buf.String())
+	}
+	if !reflect.DeepEqual(decoded, reports) {
+		t.Errorf("decoded reports = %+v, want %+v", decoded, reports)
+	}
+	tests := []struct {
+		index   int
+		command string
+		size    uint64
+		files   []string
+	}{
+		{0, "ADD file:abc in /", 1000, []string{"bin/busybox", "bin/sh"}},
+		{1, "apk add curl", 300, []string{"usr/bin/curl"}},
+	}
+	for i, tt := range tests {
+		r := decoded[i]
+		var files []string
+		for _, f := range r.Files {
+			files = append(files, f.Name)
+		}
+		if r.Index != tt.index || r.Command != tt.command || r.Size != tt.size || !reflect.DeepEqual(files, tt.files) {
+			t.Errorf("report %d = index %d, command %q, size %d, files %v, want %d, %q, %d, %v",
+				i, r.Index, r.Command, r.Size, files, tt.index, tt.command, tt.size, tt.files)
+		}
+	}
+}
+
+func TestBuildReportWhiteouts(t *testing.T) {
+	layer := testLayer(1, "/bin/sh -c rm -rf /var/cache/apk /etc/motd", testFile("etc/issue", 10))
+	layer.Whiteouts = dolay.Files{testFile("var/cache/apk/.wh..wh..opq", 0), testFile("etc/.wh.motd", 0)}
+	tests := []struct {
+		name string
+		show bool
+		want []string
+	}{
+		{"hidden", false, nil},
+		{"shown", true, []string{"etc/motd", "var/cache/apk/*"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := testReportOptions(t)
+			opts.ShowWhiteouts = tt.show
+			report, ok := buildReport(layer, opts)
+			if !ok {
+				t.Fatal("buildReport() hid the layer")
+			}
+			if !reflect.DeepEqual(report.Deleted, tt.want) {
+				t.Errorf("buildReport() deleted = %v, want %v", report.Deleted, tt.want)
+			}
+			if len(report.Files) != 1 || report.Files[0].Name != "etc/issue" {
+				t.Errorf("buildReport() files = %+v, want etc/issue", report.Files)
+			}
+		})
+	}
+}
+
+func TestMinSize(t *testing.T) {
+	tests := []struct {
+		value string
+		want  uint64
+		err   string
+	}{
+		{"0", 0, ""},
+		{"10MB", 10000000, ""},
+		{"1 KiB", 1024, ""},
+		{"10XB", 0, "invalid min size"},
+		{"ten", 0, "invalid min size"},
+		{"-1MB", 0, "invalid min size"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			f := testCLIFlags()
+			f.minSize = tt.value
+			a, err := newAnalyzer(f, listMode, []string{f.tarPath}, io.Discard)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("newAnalyzer() error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("newAnalyzer() error = %v", err)
+			}
+			if a.opts.MinSize != tt.want {
+				t.Errorf("min size = %d, want %d", a.opts.MinSize, tt.want)
+			}
+		})
+	}
+
+	layers := []*dolay.Layer{
+		testLayer(0, "/bin/sh -c #(nop) ADD file:abc in /", testFile("bin/busybox", 900), testFile("bin/sh", 10)),
+		testLayer(1, "/bin/sh -c echo > /etc/motd", testFile("etc/motd", 20)),
+	}
+	opts := testReportOptions(t)
+	opts.MinSize = 100
+	reports := buildReports(layers, opts)
+	if len(reports) != 1 || reports[0].Index != 0 || len(reports[0].Files) != 1 || reports[0].Files[0].Name != "bin/busybox" {
+		t.Errorf("buildReports() with min size = %+v, want only bin/busybox of layer 0", reports)
+	}
+	// sizes of layers are of all files
+	if reports[0].Size != 910 {
+		t.Errorf("layer size = %d, want 910", reports[0].Size)
+	}
+}
+
+func TestBuildReportsGrep(t *testing.T) {
+	layers := []*dolay.Layer{
+		testLayer(0, "/bin/sh -c #(nop) ADD file:abc in /", testFile("bin/sh", 100)),
+		testLayer(1, "/bin/sh -c apt-get install -y python3", testFile("usr/bin/python3", 200)),
+		testLayer(2, "/bin/sh -c pip install -r requirements.txt", testFile("usr/lib/python3/site-packages/flask.py", 300)),
+		testLayer(3, "/bin/sh -c #(nop) COPY dir:def in /app", testFile("app/main.py", 10)),
+	}
+	tests := []struct {
+		name       string
+		command    string
+		ignoreCase bool
+		want       []int
+	}{
+		{"pip", "pip", false, []int{2}},
+		{"substring", "install", false, []int{1, 2}},
+		{"case", "PIP INSTALL", false, nil},
+		{"ignore case", "PIP INSTALL", true, []int{2}},
+		{"all", "", false, []int{0, 1, 2, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := testReportOptions(t)
+			opts.Command, opts.IgnoreCase = tt.command, tt.ignoreCase
+			var got []int
+			for _, r := range buildReports(layers, opts) {
+				got = append(got, r.Index)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("buildReports() with -grep %q = layers %v, want %v", tt.command, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNegativeMaxFiles(t *testing.T) {
+	f := testCLIFlags()
+	f.maxFiles = -1
+	if _, err := newAnalyzer(f, listMode, []string{f.tarPath}, io.Discard); err == nil || !strings.Contains(err.Error(), "invalid max files") {
+		t.Errorf("newAnalyzer() error = %v, want invalid max files", err)
+	}
+
+	// reports of the library options list nothing instead of panics
+	layers := []*dolay.Layer{testLayer(0, "/bin/sh -c #(nop) ADD file:abc in /", testFile("bin/busybox", 900), testFile("etc/motd", 20))}
+	layers[0].Archives = []*dolay.Archive{{Name: "app.tar", Size: 10, Files: dolay.Files{testFile("app/main", 10)}}}
+	opts := testReportOptions(t)
+	opts.MaxFiles = -1
+	opts.ByExt = true
+	reports := buildReports(layers, opts)
+	if len(reports) != 1 || len(reports[0].Files) != 0 || len(reports[0].Extensions) != 0 || len(reports[0].Archives[0].Files) != 0 {
+		t.Errorf("buildReports() = %+v, want no files", reports)
+	}
+	opts.ByExt, opts.TopDirs = false, true
+	if reports := buildReports(layers, opts); len(reports[0].TopDirs) != 0 {
+		t.Errorf("buildReports() dirs = %+v, want none", reports[0].TopDirs)
+	}
+	if entries := globalTop(layers, opts); len(entries) != 0 {
+		t.Errorf("globalTop() = %+v, want no files", entries)
+	}
+}
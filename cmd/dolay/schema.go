@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// schemaURI defines version of the JSON Schema
+const schemaURI = "https://json-schema.org/draft/2020-12/schema"
+
+// timeType is type of timestamps, which are strings in JSON
+var timeType = reflect.TypeOf(time.Time{})
+
+// printJSONSchema provides output of JSON Schema of the output format.
+// Schema of the json output is printed for the text output
+func printJSONSchema(w io.Writer, output string) error {
+	var t reflect.Type
+	switch output {
+	case outputText, outputJSON:
+		t = reflect.TypeOf([]LayerReport{})
+	case outputNDJSON:
+		t = reflect.TypeOf(LayerReport{})
+	case outputImageJSON:
+		t = reflect.TypeOf(ImageReport{})
+	default:
+		return fmt.Errorf("%s output has no JSON Schema", output)
+	}
+	return writeJSON(w, jsonSchema(t))
+}
+
+// jsonSchema returns JSON Schema document of the value of type t.
+// Structs are placed into definitions by the snake_case name,
+// and fields without omitempty are required
+func jsonSchema(t reflect.Type) map[string]interface{} {
+	defs := make(map[string]interface{})
+	schema := schemaOf(t, defs)
+	schema["$schema"] = schemaURI
+	schema["$defs"] = defs
+	return schema
+}
+
+func schemaOf(t reflect.Type, defs map[string]interface{}) map[string]interface{} {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": schemaOf(t.Elem(), defs)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemaOf(t.Elem(), defs)}
+	case reflect.Struct:
+		name := snakeCase(t.Name())
+		ref := map[string]interface{}{"$ref": "#/$defs/" + name}
+		if _, ok := defs[name]; ok {
+			return ref
+		}
+		// placeholder is set before fields, since types can be recursive
+		defs[name] = nil
+		properties := make(map[string]interface{})
+		required := []string{}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" {
+				continue
+			}
+			tag := strings.Split(f.Tag.Get("json"), ",")
+			if tag[0] == "-" {
+				continue
+			}
+			key := tag[0]
+			if key == "" {
+				key = f.Name
+			}
+			properties[key] = schemaOf(f.Type, defs)
+			if !hasOption(tag[1:], "omitempty") {
+				required = append(required, key)
+			}
+		}
+		defs[name] = map[string]interface{}{
+			"type":                 "object",
+			"properties":           properties,
+			"required":             required,
+			"additionalProperties": false,
+		}
+		return ref
+	}
+	return map[string]interface{}{}
+}
+
+func hasOption(options []string, option string) bool {
+	for _, o := range options {
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
+
+// snakeCase returns snake_case form of the Go name (like "file_entry")
+func snakeCase(name string) string {
+	var b strings.Builder
+	runes := []rune(name)
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 && (unicode.IsLower(runes[i-1]) || i+1 < len(runes) && unicode.IsLower(runes[i+1])) {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/saromanov/dolay"
+)
+
+// validate returns error if the decoded JSON value doesn't match the
+// schema. Only keywords written by jsonSchema are checked
+func validate(v interface{}, schema map[string]interface{}, root map[string]interface{}, path string) error {
+	if ref, ok := schema["$ref"].(string); ok {
+		def, ok := root["$defs"].(map[string]interface{})[strings.TrimPrefix(ref, "#/$defs/")].(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s: unknown reference %s", path, ref)
+		}
+		return validate(v, def, root, path)
+	}
+	switch schema["type"] {
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return fmt.Errorf("%s: %v isn't boolean", path, v)
+		}
+	case "string":
+		if _, ok := v.(string); !ok {
+			return fmt.Errorf("%s: %v isn't string", path, v)
+		}
+	case "integer", "number":
+		n, ok := v.(float64)
+		if !ok || schema["type"] == "integer" && n != float64(int64(n)) {
+			return fmt.Errorf("%s: %v isn't %s", path, v, schema["type"])
+		}
+		if min, ok := schema["minimum"].(float64); ok && n < min {
+			return fmt.Errorf("%s: %v is less than %v", path, n, min)
+		}
+	case "array":
+		items, ok := v.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s: %v isn't array", path, v)
+		}
+		for i, item := range items {
+			if err := validate(item, schema["items"].(map[string]interface{}), root, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case "object":
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s: %v isn't object", path, v)
+		}
+		required, _ := schema["required"].([]interface{})
+		for _, key := range required {
+			if _, ok := obj[key.(string)]; !ok {
+				return fmt.Errorf("%s: required %s is missing", path, key)
+			}
+		}
+		properties, _ := schema["properties"].(map[string]interface{})
+		keys := make([]string, 0, len(obj))
+		for key := range obj {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			s, ok := properties[key].(map[string]interface{})
+			if !ok {
+				s, ok = schema["additionalProperties"].(map[string]interface{})
+			}
+			if !ok {
+				return fmt.Errorf("%s: property %s isn't allowed", path, key)
+			}
+			if err := validate(obj[key], s, root, path+"."+key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// decodeJSON returns the decoded JSON value
+func decodeJSON(t *testing.T, data []byte) interface{} {
+	t.Helper()
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, data)
+	}
+	return v
+}
+
+func TestJSONSchema(t *testing.T) {
+	link := &tar.Header{Name: "usr/bin/sh", Typeflag: tar.TypeLink, Linkname: "bin/sh", Mode: 0755, ModTime: testTime}
+	symlink := &tar.Header{Name: "bin/ash", Typeflag: tar.TypeSymlink, Linkname: "/bin/sh", Mode: 0777, ModTime: testTime}
+	layers := []*dolay.Layer{
+		testLayer(0, "/bin/sh -c #(nop) ADD file:abc in /", testFile("bin/sh", 100), link, symlink, testFile("lib/libc.so", 900)),
+		testLayer(1, "/bin/sh -c rm /etc/motd", testFile("etc/issue", 10)),
+	}
+	layers[0].Compression, layers[0].BlobSize = dolay.CompressionGzip, 500
+	layers[1].Whiteouts = dolay.Files{testFile("etc/.wh.motd", 0)}
+	opts := testReportOptions(t)
+	opts.ShowWhiteouts, opts.Tree, opts.ByExt, opts.TopDirs, opts.Hardlinks = true, true, true, true, true
+	reports := buildReports(layers, opts)
+	analysis := &dolay.Report{Manifest: dolay.ManifestItem{Config: "abc.json", RepoTags: []string{"app:latest"}}}
+	analysis.Image.Created, analysis.Image.OS, analysis.Image.Architecture = testTime, "linux", "amd64"
+
+	tests := []struct {
+		name   string
+		output string
+		value  interface{}
+	}{
+		{"json", outputJSON, reports},
+		{"empty json", outputJSON, []LayerReport{}},
+		{"ndjson", outputNDJSON, reports[1]},
+		{"image-json", outputImageJSON, newImageReport(analysis, reports, summarize(layers))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var schema, output bytes.Buffer
+			if err := printJSONSchema(&schema, tt.output); err != nil {
+				t.Fatalf("printJSONSchema() error = %v", err)
+			}
+			if err := writeJSON(&output, tt.value); err != nil {
+				t.Fatal(err)
+			}
+			root := decodeJSON(t, schema.Bytes()).(map[string]interface{})
+			if root["$schema"] != schemaURI {
+				t.Errorf("$schema = %v, want %s", root["$schema"], schemaURI)
+			}
+			if err := validate(decodeJSON(t, output.Bytes()), root, root, "$"); err != nil {
+				t.Errorf("output doesn't match the schema: %v\n%s", err, output.String())
+			}
+		})
+	}
+
+	var schema bytes.Buffer
+	if err := printJSONSchema(&schema, outputJSON); err != nil {
+		t.Fatal(err)
+	}
+	root := decodeJSON(t, schema.Bytes()).(map[string]interface{})
+	invalid := []struct {
+		name  string
+		value string
+	}{
+		{"unknown key", `[{"index":0,"command":"","size":1,"cumulative_size":1,"file_count":0,"dir_count":0,"files":[],"owner":"root"}]`},
+		{"missing files", `[{"index":0,"command":"","size":1,"cumulative_size":1,"file_count":0,"dir_count":0}]`},
+		{"negative size", `[{"index":0,"command":"","size":-1,"cumulative_size":1,"file_count":0,"dir_count":0,"files":[]}]`},
+		{"null files", `[{"index":0,"command":"","size":1,"cumulative_size":1,"file_count":0,"dir_count":0,"files":null}]`},
+	}
+	for _, tt := range invalid {
+		if err := validate(decodeJSON(t, []byte(tt.value)), root, root, "$"); err == nil {
+			t.Errorf("output with %s matches the schema", tt.name)
+		}
+	}
+	if err := printJSONSchema(&schema, outputCSV); err == nil {
+		t.Errorf("printJSONSchema(%s) error = nil", outputCSV)
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/dustin/go-humanize"
+	"github.com/saromanov/dolay"
+)
+
+// snapshotVersion defines version of the lockfile format
+const snapshotVersion = 1
+
+// Snapshot defines sizes of the image stored in the lockfile. It has no
+// times, and fields are in fixed order, so it's stable between runs
+// and changes of the committed lockfile are readable in diffs
+type Snapshot struct {
+	Version int             `json:"version"`
+	Size    uint64          `json:"size"`
+	Layers  []SnapshotLayer `json:"layers"`
+}
+
+// SnapshotLayer defines size of the layer in the lockfile
+type SnapshotLayer struct {
+	Index   int    `json:"index"`
+	Size    uint64 `json:"size"`
+	Command string `json:"command"`
+}
+
+// newSnapshot returns snapshot of sizes of layers
+func newSnapshot(layers []*dolay.Layer) Snapshot {
+	s := Snapshot{Version: snapshotVersion, Layers: make([]SnapshotLayer, 0, len(layers))}
+	for _, l := range layers {
+		s.Size += l.Size
+		s.Layers = append(s.Layers, SnapshotLayer{Index: l.Index, Size: l.Size, Command: dolay.Command(l.History)})
+	}
+	return s
+}
+
+// readSnapshot returns snapshot from the lockfile,
+// or nil if the lockfile doesn't exist
+func readSnapshot(path string) (*Snapshot, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read snapshot: %v", err)
+	}
+	var s Snapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("unable to decode snapshot %s: %v", path, err)
+	}
+	if s.Version != snapshotVersion {
+		return nil, fmt.Errorf("unsupported version %d of snapshot %s", s.Version, path)
+	}
+	return &s, nil
+}
+
+// writeSnapshot provides writing of the snapshot to the lockfile
+func writeSnapshot(path string, s Snapshot) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("unable to create snapshot: %v", err)
+	}
+	if err := writeJSON(f, s); err != nil {
+		f.Close()
+		return fmt.Errorf("unable to write snapshot: %v", err)
+	}
+	return f.Close()
+}
+
+// growthLimit defines allowed growth of the total size from
+// the snapshot in bytes or in percents. Zero disables the limit
+type growthLimit struct {
+	bytes   uint64
+	percent float64
+}
+
+// parseGrowth returns limit of the growth by the size (like 10MB)
+// or by percents of the snapshot size (like 5%)
+func parseGrowth(value string) (growthLimit, error) {
+	if value == "" {
+		return growthLimit{}, nil
+	}
+	if strings.HasSuffix(value, "%") {
+		p, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+		if err != nil || p < 0 {
+			return growthLimit{}, fmt.Errorf("invalid max growth: %s", value)
+		}
+		return growthLimit{percent: p}, nil
+	}
+	b, err := humanize.ParseBytes(value)
+	if err != nil {
+		return growthLimit{}, fmt.Errorf("invalid max growth: %v", err)
+	}
+	return growthLimit{bytes: b}, nil
+}
+
+// check returns error if the size grew from the snapshot over the limit
+func (g growthLimit) check(old, size uint64) error {
+	if size <= old {
+		return nil
+	}
+	growth := size - old
+	switch {
+	case g.bytes > 0 && growth > g.bytes:
+		return fmt.Errorf("total size grew by %s from the snapshot, which exceeds the limit %s",
+			humanize.Bytes(growth), humanize.Bytes(g.bytes))
+	case g.percent > 0 && float64(growth)*100 > g.percent*float64(old):
+		return fmt.Errorf("total size grew by %s (%.1f%%) from the snapshot, which exceeds the limit %g%%",
+			humanize.Bytes(growth), float64(growth)*100/float64(old), g.percent)
+	}
+	return nil
+}
+
+// compareSnapshot provides comparison of layers with the lockfile. The
+// lockfile is written if it doesn't exist or update is set, and deltas
+// of sizes are printed otherwise
+func compareSnapshot(w io.Writer, path string, layers []*dolay.Layer, limit growthLimit, update bool, opts TextOptions) error {
+	current := newSnapshot(layers)
+	old, err := readSnapshot(path)
+	if err != nil {
+		return err
+	}
+	if old == nil || update {
+		if err := writeSnapshot(path, current); err != nil {
+			return err
+		}
+		fmt.Fprintln(w, theme.Header.Sprintf("snapshot %s is written: %s, %d layers", path, formatBytes(current.Size), len(current.Layers)))
+		return nil
+	}
+	printSnapshotDiff(w, path, *old, current, opts)
+	return limit.check(old.Size, current.Size)
+}
+
+// printSnapshotDiff provides human-readable output
+// of changes of sizes of layers from the snapshot
+func printSnapshotDiff(w io.Writer, path string, old, current Snapshot, opts TextOptions) {
+	cmdWidth := opts.commandWidth(humanizedWidth + 12)
+	opts.separator(w)
+	fmt.Fprintln(w, theme.Header.Sprintf("%schanges from snapshot %s", opts.prefix(), path))
+	opts.separator(w)
+	n := len(old.Layers)
+	if len(current.Layers) > n {
+		n = len(current.Layers)
+	}
+	for i := 0; i < n; i++ {
+		var before, after uint64
+		var cmd string
+		if i < len(old.Layers) {
+			before, cmd = old.Layers[i].Size, old.Layers[i].Command
+		}
+		if i < len(current.Layers) {
+			after, cmd = current.Layers[i].Size, current.Layers[i].Command
+		}
+		if before == after && i < len(old.Layers) && i < len(current.Layers) {
+			continue
+		}
+		delta := int64(after) - int64(before)
+		line := fmt.Sprintf("%s\t %5d $ %s", humanizeDelta(delta), i, truncate(singleLine(cmd), cmdWidth, opts.Truncate))
+		if delta > 0 {
+			fmt.Fprintln(w, theme.Removed.Sprint(line))
+		} else {
+			fmt.Fprintln(w, theme.Added.Sprint(line))
+		}
+	}
+	opts.separator(w)
+	fmt.Fprintln(w, theme.Header.Sprintf("%s%s\t total: %s, was %s", opts.prefix(), humanizeDelta(int64(current.Size)-int64(old.Size)),
+		formatBytes(current.Size), formatBytes(old.Size)))
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/saromanov/dolay"
+)
+
+func TestParseGrowth(t *testing.T) {
+	tests := []struct {
+		value string
+		want  growthLimit
+		err   bool
+	}{
+		{"", growthLimit{}, false},
+		{"10MB", growthLimit{bytes: 10000000}, false},
+		{"5%", growthLimit{percent: 5}, false},
+		{"0.5%", growthLimit{percent: 0.5}, false},
+		{"-5%", growthLimit{}, true},
+		{"many%", growthLimit{}, true},
+		{"10XB", growthLimit{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseGrowth(tt.value)
+			if (err != nil) != tt.err || got != tt.want {
+				t.Errorf("parseGrowth() = %+v, %v, want %+v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompareSnapshot(t *testing.T) {
+	withoutColor(t)
+	path := filepath.Join(t.TempDir(), ".dolay.lock")
+	base := []*dolay.Layer{
+		testLayer(0, "/bin/sh -c #(nop) ADD file:abc in /", testFile("bin/busybox", 1000)),
+		testLayer(1, "/bin/sh -c apk add curl", testFile("usr/bin/curl", 300)),
+	}
+	var buf bytes.Buffer
+	if err := compareSnapshot(&buf, path, base, growthLimit{}, false, testTextOptions()); err != nil {
+		t.Fatalf("compareSnapshot() of the first run error = %v", err)
+	}
+	if !strings.Contains(buf.String(), "snapshot "+path+" is written: 1.3 kB, 2 layers") {
+		t.Errorf("compareSnapshot() of the first run = %q", buf.String())
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{
+  "version": 1,
+  "size": 1300,
+  "layers": [
+    {
+      "index": 0,
+      "size": 1000,
+      "command": "ADD file:abc in /"
+    },
+    {
+      "index": 1,
+      "size": 300,
+      "command": "apk add curl"
+    }
+  ]
+}
+`
+	if string(data) != want {
+		t.Errorf("lockfile = %s, want %s", data, want)
+	}
+
+	grown := []*dolay.Layer{base[0], testLayer(1, "/bin/sh -c apk add curl git", testFile("usr/bin/curl", 300), testFile("usr/bin/git", 200))}
+	tests := []struct {
+		name   string
+		layers []*dolay.Layer
+		limit  string
+		err    string
+	}{
+		{"unchanged", base, "1%", ""},
+		{"growth without limit", grown, "", ""},
+		{"growth within bytes", grown, "200B", ""},
+		{"growth over bytes", grown, "100B", "total size grew by 200 B from the snapshot, which exceeds the limit 100 B"},
+		{"growth within percents", grown, "20%", ""},
+		{"growth over percents", grown, "10%", "total size grew by 200 B (15.4%) from the snapshot, which exceeds the limit 10%"},
+		{"shrink", base[:1], "1B", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limit, err := parseGrowth(tt.limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var buf bytes.Buffer
+			err = compareSnapshot(&buf, path, tt.layers, limit, false, testTextOptions())
+			if tt.err == "" && err != nil || tt.err != "" && (err == nil || err.Error() != tt.err) {
+				t.Errorf("compareSnapshot() error = %v, want %q", err, tt.err)
+			}
+			if !strings.Contains(buf.String(), "changes from snapshot "+path) {
+				t.Errorf("compareSnapshot() = %q, want changes from the snapshot", buf.String())
+			}
+		})
+	}
+	// the lockfile isn't changed by comparison, and it's rewritten by update
+	if s, err := readSnapshot(path); err != nil || s.Size != 1300 {
+		t.Fatalf("readSnapshot() = %+v, %v, want the snapshot of the first run", s, err)
+	}
+	if err := compareSnapshot(&buf, path, grown, growthLimit{bytes: 1}, true, testTextOptions()); err != nil {
+		t.Fatalf("compareSnapshot() with update error = %v", err)
+	}
+	s, err := readSnapshot(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*s, newSnapshot(grown)) {
+		t.Errorf("updated snapshot = %+v, want %+v", *s, newSnapshot(grown))
+	}
+}
+
+func TestReadSnapshotErrors(t *testing.T) {
+	dir := t.TempDir()
+	if s, err := readSnapshot(filepath.Join(dir, "missing.lock")); s != nil || err != nil {
+		t.Errorf("readSnapshot() of the missing lockfile = %+v, %v, want nil", s, err)
+	}
+	tests := []struct {
+		name    string
+		content string
+		err     string
+	}{
+		{"invalid", "{", "unable to decode snapshot"},
+		{"version", `{"version": 2, "size": 1, "layers": []}`, "unsupported version 2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.name+".lock")
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := readSnapshot(path); err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("readSnapshot() error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/saromanov/dolay"
+)
+
+// streamSummary returns summary of the archive. Files are dropped
+// right after the layer is read, so they are not held in memory.
+// Layers matching exclude are not counted
+func streamSummary(r io.Reader, analysis dolay.Options, exclude *layerFilter) (Summary, error) {
+	files := make(map[string]int)
+	keep := analysis.Keep
+	analysis.Keep = func(l *dolay.Layer) *dolay.Layer {
+		if keep != nil {
+			l = keep(l)
+		}
+		files[l.Path] = fileCount(l)
+		return &dolay.Layer{
+			Path:        l.Path,
+			Size:        l.Size,
+			DecodeTime:  l.DecodeTime,
+			Compression: l.Compression,
+			BlobSize:    l.BlobSize,
+		}
+	}
+	var summary Summary
+	err := dolay.Stream(r, analysis, func(l *dolay.Layer) error {
+		if exclude.excluded(l) {
+			summary.Skipped++
+			return nil
+		}
+		summary.add(l, files[l.Path])
+		return nil
+	})
+	return summary, err
+}
+
+// writeSummary provides output of the summary as key=value lines,
+// so it can be evaluated by the shell
+func writeSummary(w io.Writer, s Summary) error {
+	_, err := fmt.Fprintf(w, "total_size=%d\nlayer_count=%d\nfile_count=%d\nlargest_layer_size=%d\n",
+		s.Size, s.Layers, s.Files, s.Largest)
+	if err == nil && s.Merged != nil {
+		_, err = fmt.Fprintf(w, "effective_file_count=%d\noverwritten_file_count=%d\ndeleted_file_count=%d\n",
+			s.Merged.Files, s.Merged.Overwritten, s.Merged.Deleted)
+	}
+	return err
+}
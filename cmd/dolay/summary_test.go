@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/saromanov/dolay"
+)
+
+func TestWriteSummary(t *testing.T) {
+	tests := []struct {
+		name    string
+		summary Summary
+		want    []string
+	}{
+		{
+			"totals",
+			Summary{Size: 1300, Layers: 2, Files: 3, Largest: 1000},
+			[]string{"total_size=1300", "layer_count=2", "file_count=3", "largest_layer_size=1000"},
+		},
+		{
+			"effective",
+			Summary{Size: 10, Layers: 1, Files: 4, Largest: 10, Merged: &dolay.MergeStat{Files: 2, Overwritten: 1, Deleted: 1}},
+			[]string{"total_size=10", "layer_count=1", "file_count=4", "largest_layer_size=10",
+				"effective_file_count=2", "overwritten_file_count=1", "deleted_file_count=1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := writeSummary(&buf, tt.summary); err != nil {
+				t.Fatalf("writeSummary() error = %v", err)
+			}
+			if got := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n"); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("writeSummary() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStreamSummary(t *testing.T) {
+	archive := testArchive(t,
+		testTar(t, testFile("bin/busybox", 1000), testFile("etc/passwd", 100)),
+		testTar(t, testFile("app/main", 300)),
+		testTar(t, testFile("app/config.json", 20)),
+	)
+	tests := []struct {
+		name    string
+		exclude *layerFilter
+		want    Summary
+	}{
+		{"all layers", nil, Summary{Size: 1420, Layers: 3, Files: 4, Largest: 1100}},
+		{"skipped base", newLayerFilter(1, nil), Summary{Size: 320, Layers: 2, Files: 2, Largest: 300, Skipped: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := streamSummary(bytes.NewReader(archive), dolay.Options{}, tt.exclude)
+			if err != nil {
+				t.Fatalf("streamSummary() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("streamSummary() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunEffective(t *testing.T) {
+	archive := testArchive(t,
+		testTar(t, testFile("bin/busybox", 900), testFile("etc/passwd", 100), testFile("etc/motd", 10)),
+		testTar(t, testFile("etc/passwd", 120), testFile("etc/.wh.motd", 0)),
+		testTar(t, testFile("etc/passwd", 130), testFile("app/main", 300)),
+	)
+	dir := t.TempDir()
+	tests := []struct {
+		name   string
+		output string
+		want   string
+	}{
+		{"summary", outputSummary, "file_count=6\nlargest_layer_size=1010\neffective_file_count=3\noverwritten_file_count=2\ndeleted_file_count=1\n"},
+		{"text", outputText, "total: 3 layers, 6 files (3 in the filesystem, 2 overwritten, 1 deleted)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := testCLIFlags()
+			f.output, f.effective = tt.output, true
+			f.tarPath = writeArchive(t, dir, "image.tar", archive)
+			out, err := runAnalyzer(t, f, f.tarPath)
+			if err != nil {
+				t.Fatalf("run() error = %v", err)
+			}
+			if !strings.Contains(out, tt.want) {
+				t.Errorf("run() output = %q, want %q", out, tt.want)
+			}
+		})
+	}
+}
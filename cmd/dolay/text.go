@@ -0,0 +1,629 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/fatih/color"
+	"github.com/saromanov/dolay"
+)
+
+// humanizedWidth defines width of the humanized size column
+var humanizedWidth = 7
+
+// formatBytes returns humanized size. SI units are used by default
+var formatBytes = humanize.Bytes
+
+// Formats of sizes
+const (
+	sizeHuman   = "human"
+	sizeBytes   = "bytes"
+	sizeSIFixed = "si-fixed"
+)
+
+// setSizeFormat switches formatting of sizes. IEC units (KiB, MiB)
+// are used by docker, and such sizes are longer, so columns are
+// widened for them. Byte counts are grouped by thousands with comma
+func setSizeFormat(format string, iec, comma bool) error {
+	if comma && format != sizeBytes {
+		return fmt.Errorf("-comma requires -size-format %s", sizeBytes)
+	}
+	switch format {
+	case sizeHuman:
+		if iec {
+			formatBytes = humanize.IBytes
+			humanizedWidth = 8
+		}
+	case sizeBytes:
+		formatBytes = func(sz uint64) string {
+			return strconv.FormatUint(sz, 10)
+		}
+		humanizedWidth = 12
+		if comma {
+			formatBytes = func(sz uint64) string {
+				return humanize.Comma(int64(sz))
+			}
+			humanizedWidth = 16
+		}
+	case sizeSIFixed:
+		formatBytes = fixedBytes(1000, []string{"B", "kB", "MB", "GB", "TB", "PB", "EB"})
+		humanizedWidth = 9
+		if iec {
+			formatBytes = fixedBytes(1024, []string{"B", "KiB", "MiB", "GiB", "TiB", "PiB", "EiB"})
+			humanizedWidth = 11
+		}
+	default:
+		return fmt.Errorf("unknown size format: %s", format)
+	}
+	return nil
+}
+
+// fixedBytes returns formatting of sizes with two decimals
+func fixedBytes(base float64, units []string) func(uint64) string {
+	return func(sz uint64) string {
+		v := float64(sz)
+		i := 0
+		for v >= base && i < len(units)-1 {
+			v /= base
+			i++
+		}
+		if i == 0 {
+			return fmt.Sprintf("%d %s", sz, units[0])
+		}
+		return fmt.Sprintf("%.2f %s", v, units[i])
+	}
+}
+
+// TextOptions defines options of the human-readable output
+type TextOptions struct {
+	LineWidth int
+	// MaxEntries limits entries of each directory in the tree
+	MaxEntries int
+	Long       bool
+	// Compact drops separator lines and blank lines
+	Compact bool
+	// Created shows creation time of layers
+	Created bool
+	// Truncate is the mode of cutting of commands longer than the line
+	Truncate string
+}
+
+// Truncation modes of commands
+const (
+	truncateEnd    = "end"
+	truncateMiddle = "middle"
+	truncateNone   = "none"
+)
+
+// ellipsis replaces the cut part of the command
+const ellipsis = "..."
+
+// truncate returns s cut to width characters in the truncation mode.
+// The middle mode keeps both ends of s with ellipsis between them
+func truncate(s string, width int, mode string) string {
+	runes := []rune(s)
+	if mode == truncateNone || len(runes) <= width {
+		return s
+	}
+	if width <= 0 {
+		return ""
+	}
+	if mode != truncateMiddle || width <= len(ellipsis) {
+		return string(runes[:width])
+	}
+	head := (width - len(ellipsis) + 1) / 2
+	tail := width - len(ellipsis) - head
+	return string(runes[:head]) + ellipsis + string(runes[len(runes)-tail:])
+}
+
+// truncate returns command cut to the width by options
+func (o TextOptions) truncate(cmd string, width int) string {
+	return truncate(cmd, width, o.Truncate)
+}
+
+// noCommand is shown for layers without history records
+const noCommand = "(no command available)"
+
+// minCommandWidth defines the least width of commands,
+// so they are still shown on narrow terminals
+const minCommandWidth = 10
+
+// commandWidth returns width of the command in the line
+// after the used columns
+func (o TextOptions) commandWidth(used int) int {
+	if width := o.LineWidth - used - len(o.prefix()); width > minCommandWidth {
+		return width
+	}
+	return minCommandWidth
+}
+
+// separator provides output of the line between header and entries
+func (o TextOptions) separator(w io.Writer) {
+	if !o.Compact {
+		fmt.Fprintln(w, strings.Repeat("=", o.LineWidth))
+	}
+}
+
+// blank provides output of the empty line between sections
+func (o TextOptions) blank(w io.Writer) {
+	if !o.Compact {
+		fmt.Fprintln(w)
+	}
+}
+
+// prefix returns prefix of the header line, which tells it apart
+// from entries when there are no separators
+func (o TextOptions) prefix() string {
+	if o.Compact {
+		return "# "
+	}
+	return ""
+}
+
+// lineReplacer replaces characters which break single-line layout
+var lineReplacer = strings.NewReplacer("\t", " ", "\r\n", " ", "\n", " ", "\r", " ")
+
+// singleLine returns command prepared for output at the single line
+func singleLine(cmd string) string {
+	return lineReplacer.Replace(cmd)
+}
+
+// printText provides human-readable output of reports
+func printText(w io.Writer, reports []LayerReport, summary Summary, opts TextOptions) {
+	for _, r := range reports {
+		parts := []string{fmt.Sprintf("%d files", r.FileCount), fmt.Sprintf("%d dirs", r.DirCount)}
+		if r.Compression != "" {
+			parts = append(parts, compressed(r.Compression, r.BlobSize, r.Size))
+		}
+		if r.Hardlinks != nil && r.Hardlinks.Links > 0 {
+			parts = append(parts, fmt.Sprintf("%d hardlinks to %s", r.Hardlinks.Links, formatBytes(r.Hardlinks.Size)))
+		}
+		counts := "[" + strings.Join(parts, ", ") + "]"
+		if r.Empty {
+			counts = fmt.Sprintf("(%s, no filesystem change)", formatBytes(0))
+		}
+		cmdWidth := opts.commandWidth(2*humanizedWidth + percentWidth + len(counts) + 8)
+		command := r.Command
+		if command == "" {
+			command = noCommand
+		}
+		cmd := opts.truncate(singleLine(command), cmdWidth)
+
+		opts.blank(w)
+		opts.separator(w)
+		fmt.Fprintln(w, theme.layer(r.Size).Sprintf("%s%s %s\t %s\t %s $ %s", opts.prefix(), humanizeBytes(r.Size),
+			percent(r.Size, summary.Size), humanizeBytes(r.CumulativeSize), counts, cmd))
+		if opts.Created && r.Created != nil {
+			fmt.Fprintln(w, theme.Header.Sprintf("%s\t %s\t created %s", pad("", humanizedWidth), pad("", humanizedWidth), created(*r.Created)))
+		}
+		if r.Digest != "" {
+			fmt.Fprintln(w, theme.Header.Sprintf("%s\t %s\t %s", pad("", humanizedWidth), pad("", humanizedWidth), r.Digest))
+		}
+		if r.Empty {
+			continue
+		}
+		opts.separator(w)
+		switch {
+		case r.Extensions != nil:
+			for _, e := range r.Extensions {
+				fmt.Fprintf(w, "%s\t %s (%d files)\n", humanizeBytes(e.Size), e.Ext, e.Count)
+			}
+		case r.TopDirs != nil:
+			for _, d := range r.TopDirs {
+				fmt.Fprintf(w, "%s\t %s/ (%d files)\n", humanizeBytes(d.Size), strings.TrimSuffix(d.Dir, "/"), d.Count)
+			}
+		case r.Tree != nil:
+			printTree(w, r.Tree, 0, opts.MaxEntries)
+		default:
+			printFileList(w, r.Files, r.Size, opts.Long)
+		}
+		for _, d := range r.Deleted {
+			fmt.Fprintln(w, theme.Removed.Sprintf("%s\t - %s", pad("", humanizedWidth), d))
+		}
+		printArchives(w, r.Archives, 0)
+	}
+
+	opts.blank(w)
+	opts.separator(w)
+	total := fmt.Sprintf("total: %d layers, %d files", summary.Layers, summary.Files)
+	if m := summary.Merged; m != nil {
+		total += fmt.Sprintf(" (%d in the filesystem, %d overwritten, %d deleted)", m.Files, m.Overwritten, m.Deleted)
+	}
+	if summary.BlobSize > 0 {
+		total += fmt.Sprintf(", %s compressed", formatBytes(summary.BlobSize))
+	}
+	if summary.Linked > 0 {
+		total += fmt.Sprintf(", %s counting hardlinks", formatBytes(summary.Size+summary.Linked))
+	}
+	if summary.Skipped > 0 {
+		total += fmt.Sprintf(", %d layers skipped", summary.Skipped)
+	}
+	if !summary.Built.IsZero() {
+		total += ", " + age(summary.Built)
+	}
+	fmt.Fprintln(w, theme.Header.Sprintf("%s%s\t %s", opts.prefix(), humanizeBytes(summary.Size), total))
+}
+
+// relativeTime defines showing of times relative to now
+// in addition to RFC3339, and it's disabled by -no-humanize
+var relativeTime = true
+
+// created returns creation time in RFC3339 with relative time
+func created(t time.Time) string {
+	if !relativeTime {
+		return t.Format(time.RFC3339)
+	}
+	return fmt.Sprintf("%s (%s)", t.Format(time.RFC3339), humanize.Time(t))
+}
+
+// age returns age of the image by its creation time, like
+// "built 3 days ago", or RFC3339 time if relative time is disabled
+func age(t time.Time) string {
+	if !relativeTime {
+		return "built " + t.Format(time.RFC3339)
+	}
+	return "built " + humanize.Time(t)
+}
+
+// compressed returns compressed size of the layer
+// with the ratio to the size of its files
+func compressed(compression string, blob, size uint64) string {
+	if size == 0 {
+		return fmt.Sprintf("%s %s", compression, formatBytes(blob))
+	}
+	return fmt.Sprintf("%s %s, %.1f%%", compression, formatBytes(blob), float64(blob)*100/float64(size))
+}
+
+// printDuplicates provides human-readable output of duplicates
+func printDuplicates(w io.Writer, duplicates []dolay.Duplicate, opts TextOptions) {
+	var wasted uint64
+	opts.separator(w)
+	fmt.Fprintln(w, theme.Header.Sprintf("%s%s\t %s\t path", opts.prefix(), pad("wasted", humanizedWidth), pad("total", humanizedWidth)))
+	opts.separator(w)
+	for _, d := range duplicates {
+		layers := make([]string, 0, len(d.Layers))
+		for _, l := range d.Layers {
+			layers = append(layers, fmt.Sprint(l))
+		}
+		line := fmt.Sprintf("%s\t %s\t %s (layers %s)", humanizeBytes(d.Wasted), humanizeBytes(d.Size), d.Name, strings.Join(layers, ", "))
+		if d.Deleted {
+			line += " (deleted)"
+		}
+		fmt.Fprintln(w, line)
+		wasted += d.Wasted
+	}
+	opts.separator(w)
+	fmt.Fprintln(w, theme.Header.Sprintf("%s%s\t total: %d files", opts.prefix(), humanizeBytes(wasted), len(duplicates)))
+}
+
+// printGlobalTop provides human-readable output of the top files
+// across all layers
+func printGlobalTop(w io.Writer, entries []GlobalEntry, opts TextOptions) {
+	opts.separator(w)
+	fmt.Fprintln(w, theme.Header.Sprintf("%s%s\t layer\t path", opts.prefix(), pad("size", humanizedWidth)))
+	opts.separator(w)
+	for _, e := range entries {
+		name := displayName(e.FileEntry)
+		if opts.Long {
+			name = longEntry(e.FileEntry)
+		}
+		fmt.Fprintf(w, "%s\t %5d\t %s\n", humanizeBytes(uint64(e.Size)), e.Layer, name)
+	}
+}
+
+// printFiles provides human-readable output of the file list
+func printFiles(w io.Writer, files []FileEntry, opts TextOptions) {
+	var total uint64
+	for _, f := range files {
+		name := displayName(f)
+		if opts.Long {
+			name = longEntry(f)
+		}
+		fmt.Fprintf(w, "%s\t %s\n", humanizeBytes(uint64(f.Size)), name)
+		total += uint64(f.Size)
+	}
+	opts.separator(w)
+	fmt.Fprintln(w, theme.Header.Sprintf("%s%s\t total: %d files", opts.prefix(), humanizeBytes(total), len(files)))
+}
+
+// printLint provides human-readable output of warnings
+func printLint(w io.Writer, warnings []dolay.Warning, opts TextOptions) {
+	var wasted uint64
+	for _, warn := range warnings {
+		fmt.Fprintln(w, theme.Removed.Sprintf("%s\t layer %d: %s cache in %d files", humanizeBytes(warn.Size), warn.Layer, warn.Rule, warn.Files)+
+			" ($ "+singleLine(warn.Command)+")")
+		fmt.Fprintf(w, "%s\t %s\n", pad("", humanizedWidth), warn.Fix)
+		wasted += warn.Size
+	}
+	opts.separator(w)
+	fmt.Fprintln(w, theme.Header.Sprintf("%s%s\t total: %d warnings", opts.prefix(), humanizeBytes(wasted), len(warnings)))
+}
+
+// printInstructions provides human-readable output
+// of sizes of layers grouped by the instruction
+func printInstructions(w io.Writer, stats []dolay.InstructionStat, opts TextOptions) {
+	var total uint64
+	opts.separator(w)
+	fmt.Fprintln(w, theme.Header.Sprintf("%s%s\t layers\t instruction", opts.prefix(), pad("size", humanizedWidth)))
+	opts.separator(w)
+	for _, s := range stats {
+		fmt.Fprintf(w, "%s\t %d\t %s\n", humanizeBytes(s.Size), s.Layers, s.Instruction)
+		total += s.Size
+	}
+	opts.separator(w)
+	fmt.Fprintln(w, theme.Header.Sprintf("%s%s\t total: %d instructions", opts.prefix(), humanizeBytes(total), len(stats)))
+}
+
+// printOwners provides human-readable output
+// of total sizes of files by the owner
+func printOwners(w io.Writer, stats []dolay.OwnerStat, opts TextOptions) {
+	var total uint64
+	var files int
+	opts.separator(w)
+	fmt.Fprintln(w, theme.Header.Sprintf("%s%s\t files\t owner", opts.prefix(), pad("size", humanizedWidth)))
+	opts.separator(w)
+	for _, s := range stats {
+		name := fmt.Sprintf("%d:%d", s.UID, s.GID)
+		if s.Uname != "" || s.Gname != "" {
+			name += fmt.Sprintf(" (%s:%s)", s.Uname, s.Gname)
+		}
+		fmt.Fprintf(w, "%s\t %5d\t %s\n", humanizeBytes(s.Size), s.Count, name)
+		total += s.Size
+		files += s.Count
+	}
+	opts.separator(w)
+	fmt.Fprintln(w, theme.Header.Sprintf("%s%s\t total: %d owners, %d files", opts.prefix(), humanizeBytes(total), len(stats), files))
+}
+
+// printCommands provides human-readable output
+// of total sizes of layers by the command
+func printCommands(w io.Writer, stats []dolay.CommandStat, opts TextOptions) {
+	var total uint64
+	opts.separator(w)
+	fmt.Fprintln(w, theme.Header.Sprintf("%s%s\t layers\t command", opts.prefix(), pad("size", humanizedWidth)))
+	opts.separator(w)
+	cmdWidth := opts.commandWidth(humanizedWidth + 10)
+	for _, s := range stats {
+		fmt.Fprintf(w, "%s\t %d\t %s\n", humanizeBytes(s.Size), s.Layers, truncate(singleLine(s.Command), cmdWidth, opts.Truncate))
+		total += s.Size
+	}
+	opts.separator(w)
+	fmt.Fprintln(w, theme.Header.Sprintf("%s%s\t total: %d commands", opts.prefix(), humanizeBytes(total), len(stats)))
+}
+
+// humanizeDelta returns padded humanized size change with the sign
+func humanizeDelta(delta int64) string {
+	sign := "+"
+	if delta < 0 {
+		sign = "-"
+		delta = -delta
+	}
+	return pad(sign+formatBytes(uint64(delta)), humanizedWidth+1)
+}
+
+// printDiff provides human-readable output of the images diff
+func printDiff(w io.Writer, diffs []dolay.LayerDiff, opts TextOptions) {
+	cmdWidth := opts.commandWidth(humanizedWidth + 8)
+	var total int64
+	for _, d := range diffs {
+		cmd := opts.truncate(singleLine(d.Command), cmdWidth)
+		opts.blank(w)
+		opts.separator(w)
+		switch d.Change {
+		case dolay.ChangeAdded:
+			fmt.Fprintln(w, theme.Added.Sprintf("%s%s %s\t $ %s", opts.prefix(), d.Change, humanizeDelta(d.Delta), cmd))
+		case dolay.ChangeRemoved:
+			fmt.Fprintln(w, theme.Removed.Sprintf("%s%s %s\t $ %s", opts.prefix(), d.Change, humanizeDelta(d.Delta), cmd))
+		default:
+			fmt.Fprintln(w, theme.Header.Sprintf("%s%s %s\t $ %s", opts.prefix(), d.Change, humanizeDelta(d.Delta), cmd))
+		}
+		opts.separator(w)
+		for _, f := range d.Files {
+			fmt.Fprintln(w, f.Change, humanizeDelta(f.Delta), "\t", f.Name)
+		}
+		total += d.Delta
+	}
+
+	opts.blank(w)
+	opts.separator(w)
+	fmt.Fprintln(w, theme.Header.Sprintf("%s  %s\t total: %d layers changed", opts.prefix(), humanizeDelta(total), len(diffs)))
+}
+
+// printViolations provides human-readable output of violations of rules
+func printViolations(w io.Writer, violations []dolay.Violation, opts TextOptions) {
+	var total uint64
+	opts.separator(w)
+	for _, v := range violations {
+		fmt.Fprintln(w, theme.Removed.Sprintf("%s\t %s: %s", humanizeBytes(uint64(v.Size)), v.Rule, v.Name)+
+			theme.Header.Sprintf(" (%s)", v.Message))
+		total += uint64(v.Size)
+	}
+	opts.separator(w)
+	fmt.Fprintln(w, theme.Header.Sprintf("%s%s\t total: %d violations", opts.prefix(), humanizeBytes(total), len(violations)))
+}
+
+// printBrokenLinks provides human-readable output of broken symlinks
+func printBrokenLinks(w io.Writer, links []dolay.BrokenLink, opts TextOptions) {
+	opts.separator(w)
+	for _, l := range links {
+		fmt.Fprintln(w, theme.Removed.Sprintf("%s -> %s", l.Name, l.Target))
+	}
+	opts.separator(w)
+	fmt.Fprintln(w, theme.Header.Sprintf("%stotal: %d broken links", opts.prefix(), len(links)))
+}
+
+// printChanges provides human-readable output of changes of files
+func printChanges(w io.Writer, changes []dolay.FileDiff, opts TextOptions) {
+	var total int64
+	counts := make(map[string]int)
+	opts.separator(w)
+	for _, f := range changes {
+		line := fmt.Sprintf("%s %s\t %s", f.Change, humanizeDelta(f.Delta), f.Name)
+		switch f.Change {
+		case dolay.ChangeAdded:
+			line = theme.Added.Sprint(line)
+		case dolay.ChangeRemoved:
+			line = theme.Removed.Sprint(line)
+		}
+		fmt.Fprintln(w, line)
+		counts[f.Change]++
+		total += f.Delta
+	}
+	opts.separator(w)
+	fmt.Fprintln(w, theme.Header.Sprintf("%s  %s\t total: %d added, %d modified, %d deleted", opts.prefix(), humanizeDelta(total),
+		counts[dolay.ChangeAdded], counts[dolay.ChangeModified], counts[dolay.ChangeRemoved]))
+}
+
+// ownerWidth defines width of the owner column in the long listing
+const ownerWidth = 17
+
+// specialColor highlights setuid, setgid and sticky bits
+var specialColor = color.New(color.FgRed, color.Bold)
+
+// longEntry returns file record with permissions and ownership
+// like ls -l. Setuid, setgid and sticky bits are highlighted
+func longEntry(f FileEntry) string {
+	mode := f.Mode
+	if f.Special {
+		mode = specialColor.Sprint(mode)
+	}
+	return fmt.Sprintf("%s %-*s %s", mode, ownerWidth, owner(f), displayName(f))
+}
+
+// owner returns names of the file owner, or ids if names are not set
+func owner(f FileEntry) string {
+	if f.Uname != "" || f.Gname != "" {
+		return fmt.Sprintf("%s:%s", f.Uname, f.Gname)
+	}
+	return fmt.Sprintf("%d:%d", f.UID, f.GID)
+}
+
+// xattrList returns extended attributes of the file like
+// " [security.capability=cap_net_raw=ep]", sorted by name
+func xattrList(attrs map[string]string) string {
+	if len(attrs) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(attrs))
+	for name := range attrs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for i, name := range names {
+		names[i] = name + "=" + attrs[name]
+	}
+	return " [" + strings.Join(names, ", ") + "]"
+}
+
+// printFileList provides output of files of the layer as columns of size,
+// share of the layer, permissions and owner if long is set, and name.
+// Columns are aligned by the widest cell, so long sizes and owners
+// don't shift names
+func printFileList(w io.Writer, files []FileEntry, layerSize uint64, long bool) {
+	width := humanizedWidth
+	for _, f := range files {
+		if n := len(formatBytes(uint64(f.Size))); n > width {
+			width = n
+		}
+	}
+	var buf bytes.Buffer
+	tw := tabwriter.NewWriter(&buf, 0, 0, 1, ' ', 0)
+	for _, f := range files {
+		fmt.Fprintf(tw, "%s\t%s\t ", pad(formatBytes(uint64(f.Size)), width), percent(uint64(f.Size), layerSize))
+		if long {
+			fmt.Fprintf(tw, "%s\t%s\t", f.Mode, owner(f))
+		}
+		fmt.Fprintln(tw, displayName(f)+xattrList(f.Xattrs))
+	}
+	tw.Flush()
+
+	// colors are applied after the alignment, because
+	// escape sequences are counted in widths of cells
+	lines := strings.SplitAfter(buf.String(), "\n")
+	if !long || color.NoColor || len(lines) != len(files)+1 {
+		w.Write(buf.Bytes())
+		return
+	}
+	for i, f := range files {
+		line := lines[i]
+		if f.Special {
+			line = strings.Replace(line, f.Mode, specialColor.Sprint(f.Mode), 1)
+		}
+		io.WriteString(w, line)
+	}
+}
+
+// displayName returns name of the file with target of the link
+func displayName(f FileEntry) string {
+	switch {
+	case f.Hardlink:
+		return f.Name + " link to " + f.Link
+	case f.Link != "":
+		return f.Name + " -> " + f.Link
+	}
+	return f.Name
+}
+
+// printArchives provides output of archives of the layer with their
+// top files, which are indented by depth of nesting
+func printArchives(w io.Writer, archives []ArchiveReport, depth int) {
+	indent := strings.Repeat("  ", depth)
+	for _, a := range archives {
+		fmt.Fprintln(w, theme.Header.Sprintf("%s\t %s%s [archive, %d files]", humanizeBytes(a.Size), indent, a.Name, a.FileCount))
+		for _, f := range a.Files {
+			fmt.Fprintf(w, "%s\t %s  %s\n", humanizeBytes(uint64(f.Size)), indent, displayName(f))
+		}
+		printArchives(w, a.Archives, depth+1)
+	}
+}
+
+// printTree provides output of children of the node with
+// indentation by depth. At most maxEntries children of
+// each directory are printed
+func printTree(w io.Writer, n *dolay.Node, depth, maxEntries int) {
+	indent := strings.Repeat("  ", depth)
+	for i, c := range n.Children {
+		if i >= maxEntries {
+			fmt.Fprintf(w, "%s\t %s... %d more\n", pad("", humanizedWidth), indent, len(n.Children)-i)
+			break
+		}
+		name := c.Name
+		if c.IsDir() {
+			name += "/"
+		}
+		fmt.Fprintln(w, humanizeBytes(c.Size), "\t", indent+name)
+		printTree(w, c, depth+1, maxEntries)
+	}
+}
+
+func humanizeBytes(sz uint64) string {
+	return pad(formatBytes(sz), humanizedWidth)
+}
+
+// percentWidth defines width of the percentage like "(100%)"
+const percentWidth = 6
+
+// percent returns padded percentage of the part in the total
+func percent(part, total uint64) string {
+	var p float64
+	if total > 0 {
+		p = float64(part) * 100 / float64(total)
+	}
+	return pad(fmt.Sprintf("(%.0f%%)", p), percentWidth)
+}
+
+// pad returns s aligned to the right by n characters.
+// Strings longer than n are returned as is
+func pad(s string, n int) string {
+	if len(s) >= n {
+		return s
+	}
+	return strings.Repeat(" ", n-len(s)) + s
+}
@@ -0,0 +1,500 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/saromanov/dolay"
+)
+
+// withoutColor provides disabling of colors for the test
+func withoutColor(t *testing.T) {
+	t.Helper()
+	saved := color.NoColor
+	color.NoColor = true
+	t.Cleanup(func() { color.NoColor = saved })
+}
+
+// testTextOptions returns options of the text output of the test
+func testTextOptions() TextOptions {
+	return TextOptions{LineWidth: 120, MaxEntries: 10, Truncate: truncateNone}
+}
+
+func TestSingleLine(t *testing.T) {
+	tests := []struct {
+		name string
+		cmd  string
+		want string
+	}{
+		{"plain", "apk add curl", "apk add curl"},
+		{"tabs", "apk add\tcurl\t\tgit", "apk add curl  git"},
+		{"newlines", "apk update &&\n    apk add curl", "apk update &&     apk add curl"},
+		{"crlf", "make\r\nmake install\rclean", "make make install clean"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := singleLine(tt.cmd); got != tt.want {
+				t.Errorf("singleLine(%q) = %q, want %q", tt.cmd, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrintTextCommand(t *testing.T) {
+	withoutColor(t)
+	layers := []*dolay.Layer{testLayer(0, "/bin/sh -c apk add\tcurl &&\n\trm -rf /var/cache/apk", testFile("usr/bin/curl", 300))}
+	var buf bytes.Buffer
+	printText(&buf, buildReports(layers, testReportOptions(t)), summarize(layers), testTextOptions())
+	var line string
+	for _, l := range strings.Split(buf.String(), "\n") {
+		if i := strings.Index(l, " $ "); i >= 0 {
+			line = l[i+3:]
+		}
+	}
+	if want := "apk add curl &&  rm -rf /var/cache/apk"; line != want {
+		t.Errorf("printed command = %q, want %q", line, want)
+	}
+}
+
+func TestNoColor(t *testing.T) {
+	saved := color.NoColor
+	t.Cleanup(func() { color.NoColor = saved })
+	layers := []*dolay.Layer{
+		testLayer(0, "/bin/sh -c #(nop) ADD file:abc in /", testFile("bin/busybox", 900)),
+		testLayer(1, "/bin/sh -c apk add curl", testFile("usr/bin/curl", 300)),
+	}
+	render := func() string {
+		var buf bytes.Buffer
+		printText(&buf, buildReports(layers, testReportOptions(t)), summarize(layers), testTextOptions())
+		return buf.String()
+	}
+	color.NoColor = false
+	if out := render(); !strings.Contains(out, "\x1b[") {
+		t.Fatalf("output contains no escape sequences with colors:\n%s", out)
+	}
+	color.NoColor = true
+	if out := render(); strings.Contains(out, "\x1b") {
+		t.Errorf("output contains escape sequences without colors:\n%q", out)
+	}
+
+	// stdout of tests isn't a terminal
+	tests := []struct {
+		name    string
+		noColor bool
+		env     bool
+	}{
+		{"not a terminal", false, false},
+		{"-no-color", true, false},
+		{"NO_COLOR", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.env {
+				t.Setenv("NO_COLOR", "")
+			}
+			color.NoColor = false
+			setupColor(tt.noColor)
+			if !color.NoColor {
+				t.Errorf("setupColor(%v) enabled colors", tt.noColor)
+			}
+		})
+	}
+}
+
+func TestPad(t *testing.T) {
+	tests := []struct {
+		s    string
+		n    int
+		want string
+	}{
+		{"5 MB", 7, "   5 MB"},
+		{"1.2 GB", 7, " 1.2 GB"},
+		{"1023 TB", 7, "1023 TB"},
+		{"18446744 PB", 7, "18446744 PB"},
+		{"", 3, "   "},
+		{"abc", 0, "abc"},
+		{"abc", -1, "abc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			if got := pad(tt.s, tt.n); got != tt.want {
+				t.Errorf("pad(%q, %d) = %q, want %q", tt.s, tt.n, got, tt.want)
+			}
+		})
+	}
+	// sizes wider than the column don't break the output
+	withoutColor(t)
+	layer := testLayer(0, "/bin/sh -c make", testFile("big.bin", 1<<62))
+	var buf bytes.Buffer
+	printText(&buf, buildReports([]*dolay.Layer{layer}, testReportOptions(t)), summarize([]*dolay.Layer{layer}), testTextOptions())
+	if !strings.Contains(buf.String(), "4.6 EB") {
+		t.Errorf("printText() doesn't contain the size of the layer:\n%s", buf.String())
+	}
+}
+
+func TestPrintFileListLong(t *testing.T) {
+	su := testFile("bin/su", 100)
+	su.Mode, su.Uid, su.Uname = 04755, 0, "root"
+	passwd := testFile("etc/passwd", 10)
+	passwd.Uid, passwd.Gid = 1000, 1000
+	files := []FileEntry{newFileEntry(su), newFileEntry(passwd)}
+	if !files[0].Special || files[1].Special {
+		t.Fatalf("special bits = %v, %v, want only bin/su", files[0].Special, files[1].Special)
+	}
+	saved := color.NoColor
+	t.Cleanup(func() { color.NoColor = saved })
+	color.NoColor = false
+	var buf bytes.Buffer
+	printFileList(&buf, files, 110, true)
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("printFileList() = %q, want 2 lines", buf.String())
+	}
+	if want := specialColor.Sprint("-rwsr-xr-x"); !strings.Contains(lines[0], want) || !strings.Contains(lines[0], "root") {
+		t.Errorf("setuid line = %q, want highlighted mode and owner", lines[0])
+	}
+	if strings.Contains(lines[1], "\x1b") || !strings.Contains(lines[1], "-rw-r--r--") || !strings.Contains(lines[1], "1000") {
+		t.Errorf("regular line = %q", lines[1])
+	}
+}
+
+func TestDisplayName(t *testing.T) {
+	tests := []struct {
+		name string
+		h    *tar.Header
+		want string
+	}{
+		{"regular", testFile("bin/busybox", 900), "bin/busybox"},
+		{"symlink", &tar.Header{Name: "bin/sh", Typeflag: tar.TypeSymlink, Linkname: "/bin/busybox"}, "bin/sh -> /bin/busybox"},
+		{"hardlink", &tar.Header{Name: "bin/ls", Typeflag: tar.TypeLink, Linkname: "bin/busybox"}, "bin/ls link to bin/busybox"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := displayName(newFileEntry(tt.h)); got != tt.want {
+				t.Errorf("displayName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// withSizeFormat provides switching of the size format for the test
+func withSizeFormat(t *testing.T, format string, iec, comma bool) error {
+	t.Helper()
+	savedFormat, savedWidth := formatBytes, humanizedWidth
+	t.Cleanup(func() { formatBytes, humanizedWidth = savedFormat, savedWidth })
+	return setSizeFormat(format, iec, comma)
+}
+
+func TestSizeFormat(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		iec    bool
+		size   uint64
+		want   string
+		width  int
+	}{
+		{"si", sizeHuman, false, 5 << 20, "5.2 MB", 7},
+		{"iec", sizeHuman, true, 5 << 20, "5.0 MiB", 8},
+		{"si kilobytes", sizeHuman, false, 1536, "1.5 kB", 7},
+		{"iec kilobytes", sizeHuman, true, 1536, "1.5 KiB", 8},
+		{"bytes", sizeBytes, false, 5 << 20, "5242880", 12},
+		{"bytes of small size", sizeBytes, false, 12, "12", 12},
+		{"si fixed", sizeSIFixed, false, 5 << 20, "5.24 MB", 9},
+		{"si fixed bytes", sizeSIFixed, false, 999, "999 B", 9},
+		{"iec fixed", sizeSIFixed, true, 5 << 20, "5.00 MiB", 11},
+		{"iec fixed of large size", sizeSIFixed, true, 3 << 40, "3.00 TiB", 11},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := withSizeFormat(t, tt.format, tt.iec, false); err != nil {
+				t.Fatalf("setSizeFormat() error = %v", err)
+			}
+			if got := formatBytes(tt.size); got != tt.want {
+				t.Errorf("formatBytes(%d) = %q, want %q", tt.size, got, tt.want)
+			}
+			if humanizedWidth != tt.width || len(tt.want) > humanizedWidth {
+				t.Errorf("width = %d, want %d", humanizedWidth, tt.width)
+			}
+			// sizes are aligned to the right in the column
+			if got := pad(formatBytes(tt.size), humanizedWidth); len(got) != humanizedWidth || !strings.HasSuffix(got, tt.want) {
+				t.Errorf("padded size = %q", got)
+			}
+		})
+	}
+	if err := withSizeFormat(t, "rounded", false, false); err == nil {
+		t.Errorf("setSizeFormat(rounded) error = nil, want unknown size format")
+	}
+}
+
+func TestSizeFormatComma(t *testing.T) {
+	tests := []struct {
+		size uint64
+		want string
+	}{
+		{0, "0"},
+		{999, "999"},
+		{1000, "1,000"},
+		{1234567, "1,234,567"},
+		{5 << 30, "5,368,709,120"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if err := withSizeFormat(t, sizeBytes, false, true); err != nil {
+				t.Fatalf("setSizeFormat() error = %v", err)
+			}
+			if got := formatBytes(tt.size); got != tt.want {
+				t.Errorf("formatBytes(%d) = %q, want %q", tt.size, got, tt.want)
+			}
+			if got := pad(formatBytes(tt.size), humanizedWidth); len(got) != humanizedWidth {
+				t.Errorf("padded size = %q isn't %d wide", got, humanizedWidth)
+			}
+		})
+	}
+	for _, format := range []string{sizeHuman, sizeSIFixed} {
+		if err := withSizeFormat(t, format, false, true); err == nil {
+			t.Errorf("setSizeFormat(%s) with comma error = nil", format)
+		}
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	cmd := "apt-get update && apt-get install -y curl"
+	tests := []struct {
+		name  string
+		s     string
+		mode  string
+		width int
+		want  string
+	}{
+		{"end", cmd, truncateEnd, 14, "apt-get update"},
+		{"middle", cmd, truncateMiddle, 15, "apt-ge...y curl"},
+		{"middle of even width", cmd, truncateMiddle, 10, "apt-...url"},
+		{"none", cmd, truncateNone, 10, cmd},
+		{"fits", cmd, truncateEnd, len(cmd), cmd},
+		{"fits in the middle mode", cmd, truncateMiddle, 100, cmd},
+		{"narrow middle", cmd, truncateMiddle, 3, "apt"},
+		{"zero width", cmd, truncateEnd, 0, ""},
+		{"runes", "ünïcode", truncateEnd, 4, "ünïc"},
+		{"runes in the middle", "ünïcode ünïcode", truncateMiddle, 9, "ünï...ode"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := truncate(tt.s, tt.width, tt.mode); got != tt.want {
+				t.Errorf("truncate(%q, %d, %s) = %q, want %q", tt.s, tt.width, tt.mode, got, tt.want)
+			}
+		})
+	}
+
+	f := testCLIFlags()
+	f.wide = true
+	a, err := newAnalyzer(f, listMode, []string{f.tarPath}, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.text.Truncate != truncateNone {
+		t.Errorf("truncation of -wide = %s, want %s", a.text.Truncate, truncateNone)
+	}
+}
+
+func TestPrintTextCompressed(t *testing.T) {
+	withoutColor(t)
+	gzipped := testLayer(0, "/bin/sh -c #(nop) ADD file:abc in /", testFile("var/log/big.log", 1000000))
+	gzipped.Compression, gzipped.BlobSize = dolay.CompressionGzip, 250000
+	plain := testLayer(1, "/bin/sh -c make", testFile("app", 1000))
+	plain.BlobSize = 3072
+	layers := []*dolay.Layer{gzipped, plain}
+	reports := buildReports(layers, testReportOptions(t))
+	if r := reports[0]; r.Size != 1000000 || r.BlobSize != 250000 || r.Compression != dolay.CompressionGzip {
+		t.Errorf("report of gzip layer size = %d, blob = %d, compression = %q", r.Size, r.BlobSize, r.Compression)
+	}
+	summary := summarize(layers)
+	if summary.Size != 1001000 || summary.BlobSize != 250000 {
+		t.Errorf("summary size = %d, blob = %d, want 1001000 and 250000 of compressed layers", summary.Size, summary.BlobSize)
+	}
+	var buf bytes.Buffer
+	printText(&buf, reports, summary, testTextOptions())
+	for _, want := range []string{"[1 files, 0 dirs, gzip 250 kB, 25.0%]", "[1 files, 0 dirs] $ make", ", 250 kB compressed"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("printText() doesn't contain %q:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestPrintTextCreated(t *testing.T) {
+	withoutColor(t)
+	stamped := testLayer(0, "/bin/sh -c #(nop) ADD file:abc in /", testFile("bin/busybox", 900))
+	stamped.History.Created = time.Date(2024, 3, 1, 10, 30, 0, 0, time.UTC)
+	unknown := testLayer(1, "/bin/sh -c make", testFile("app", 10))
+	unknown.History.Created = time.Time{}
+	layers := []*dolay.Layer{stamped, unknown}
+	reports := buildReports(layers, testReportOptions(t))
+	if reports[0].Created == nil || !reports[0].Created.Equal(stamped.History.Created) || reports[1].Created != nil {
+		t.Fatalf("created of reports = %v, %v", reports[0].Created, reports[1].Created)
+	}
+	data, err := json.Marshal(reports)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(data), `"created":"2024-03-01T10:30:00Z"`); n != 1 || strings.Count(string(data), `"created"`) != 1 {
+		t.Errorf("json of reports = %s, want created only of the first layer", data)
+	}
+
+	tests := []struct {
+		name     string
+		relative bool
+		want     string
+	}{
+		{"relative", true, "created 2024-03-01T10:30:00Z ("},
+		{"rfc3339", false, "created 2024-03-01T10:30:00Z\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			saved := relativeTime
+			relativeTime = tt.relative
+			defer func() { relativeTime = saved }()
+			opts := testTextOptions()
+			opts.Created = true
+			var buf bytes.Buffer
+			printText(&buf, reports, summarize(layers), opts)
+			if !strings.Contains(buf.String(), tt.want) || strings.Count(buf.String(), "created ") != 1 {
+				t.Errorf("printText() doesn't contain %q once:\n%s", tt.want, buf.String())
+			}
+		})
+	}
+}
+
+func TestPrintTextNoCommand(t *testing.T) {
+	withoutColor(t)
+	layers := []*dolay.Layer{testLayer(0, "", testFile("bin/busybox", 900))}
+	var buf bytes.Buffer
+	printText(&buf, buildReports(layers, testReportOptions(t)), summarize(layers), testTextOptions())
+	if !strings.Contains(buf.String(), " $ "+noCommand) {
+		t.Errorf("printText() = %q, want %q for the layer without history", buf.String(), noCommand)
+	}
+}
+
+func TestPercent(t *testing.T) {
+	tests := []struct {
+		part, total uint64
+		want        string
+	}{
+		{0, 0, "  (0%)"},
+		{1, 3, " (33%)"},
+		{2, 3, " (67%)"},
+		{6, 1000, "  (1%)"},
+		{3, 3, "(100%)"},
+	}
+	for _, tt := range tests {
+		if got := percent(tt.part, tt.total); got != tt.want {
+			t.Errorf("percent(%d, %d) = %q, want %q", tt.part, tt.total, got, tt.want)
+		}
+	}
+}
+
+// percentages returns percentages of layers in the image and of files
+// in each layer printed by the text output
+func percentages(t *testing.T, text string) (layers []int, files [][]int) {
+	t.Helper()
+	for _, line := range strings.Split(text, "\n") {
+		start, end := strings.Index(line, "("), strings.Index(line, "%)")
+		if start < 0 || end < start {
+			continue
+		}
+		var p int
+		if _, err := fmt.Sscan(line[start+1:end], &p); err != nil {
+			t.Fatalf("line %q: %v", line, err)
+		}
+		if strings.Contains(line, " $ ") {
+			layers = append(layers, p)
+			files = append(files, nil)
+			continue
+		}
+		files[len(files)-1] = append(files[len(files)-1], p)
+	}
+	return layers, files
+}
+
+// checkPercentages fails if percentages don't sum to 100 within rounding
+// of each one by at most a half
+func checkPercentages(t *testing.T, name string, got []int, want int) {
+	t.Helper()
+	if len(got) != want {
+		t.Fatalf("percentages of %s = %v, want %d", name, got, want)
+	}
+	sum := 0
+	for _, p := range got {
+		sum += p
+	}
+	if diff := sum - 100; 2*diff > len(got) || -2*diff > len(got) {
+		t.Errorf("percentages of %s = %v, sum %d, want 100 within rounding", name, got, sum)
+	}
+}
+
+func TestPrintTextPercentages(t *testing.T) {
+	withoutColor(t)
+	tests := []struct {
+		name  string
+		sizes []int64
+	}{
+		{"thirds", []int64{100, 100, 100}},
+		{"sevenths", []int64{100, 100, 100, 100, 100, 100, 100}},
+		{"uneven", []int64{1, 333, 666, 2000}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var layers []*dolay.Layer
+			for i, size := range tt.sizes {
+				var files []*tar.Header
+				for j, s := range tt.sizes {
+					files = append(files, testFile(fmt.Sprintf("layer%d/file%d", i, j), s*size))
+				}
+				layers = append(layers, testLayer(i, fmt.Sprintf("/bin/sh -c step %d", i), files...))
+			}
+			var buf bytes.Buffer
+			printText(&buf, buildReports(layers, testReportOptions(t)), summarize(layers), testTextOptions())
+			layerPercents, filePercents := percentages(t, buf.String())
+			checkPercentages(t, "layers", layerPercents, len(tt.sizes))
+			for i, files := range filePercents {
+				checkPercentages(t, fmt.Sprintf("files of layer %d", i), files, len(tt.sizes))
+			}
+		})
+	}
+}
+
+func TestPrintTextAge(t *testing.T) {
+	withoutColor(t)
+	layers := []*dolay.Layer{testLayer(0, "/bin/sh -c #(nop) ADD file:abc in /", testFile("bin/busybox", 900))}
+	tests := []struct {
+		name     string
+		built    time.Time
+		relative bool
+		want     string
+	}{
+		{"days", time.Now().Add(-73 * time.Hour), true, ", built 3 days ago\n"},
+		{"months", time.Now().AddDate(0, -2, -1), true, ", built 2 months ago\n"},
+		{"rfc3339", time.Date(2024, 3, 1, 10, 30, 0, 0, time.UTC), false, ", built 2024-03-01T10:30:00Z\n"},
+		{"unknown", time.Time{}, true, "total: 1 layers, 1 files\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			saved := relativeTime
+			relativeTime = tt.relative
+			defer func() { relativeTime = saved }()
+			summary := summarize(layers)
+			summary.Built = tt.built
+			var buf bytes.Buffer
+			printText(&buf, buildReports(layers, testReportOptions(t)), summary, testTextOptions())
+			if !strings.Contains(buf.String(), tt.want) {
+				t.Errorf("printText() doesn't contain %q:\n%s", tt.want, buf.String())
+			}
+		})
+	}
+}
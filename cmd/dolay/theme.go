@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/fatih/color"
+)
+
+// Theme defines colors of the human-readable output
+type Theme struct {
+	Header  *color.Color
+	Added   *color.Color
+	Removed *color.Color
+	// Small, Medium and Large are colors of layer headers by size
+	Small  *color.Color
+	Medium *color.Color
+	Large  *color.Color
+	// MediumSize and LargeSize are the smallest sizes
+	// of layers which are colored as medium and large
+	MediumSize uint64
+	LargeSize  uint64
+}
+
+// Sizes of layers which are colored as medium and large by default
+const (
+	defaultMediumSize = 10 * 1000 * 1000
+	defaultLargeSize  = 100 * 1000 * 1000
+)
+
+// themes contains themes by name. "dark" uses bright colors
+// for dark backgrounds, "light" avoids yellow, which is hard
+// to read on light backgrounds, and "mono" uses only bold text
+var themes = map[string]Theme{
+	"default": {
+		Header:  color.New(color.FgBlue),
+		Added:   color.New(color.FgGreen),
+		Removed: color.New(color.FgRed),
+		Small:   color.New(color.FgGreen),
+		Medium:  color.New(color.FgYellow),
+		Large:   color.New(color.FgRed),
+	},
+	"dark": {
+		Header:  color.New(color.FgHiBlue),
+		Added:   color.New(color.FgHiGreen),
+		Removed: color.New(color.FgHiRed),
+		Small:   color.New(color.FgHiGreen),
+		Medium:  color.New(color.FgHiYellow),
+		Large:   color.New(color.FgHiRed),
+	},
+	"light": {
+		Header:  color.New(color.FgBlue),
+		Added:   color.New(color.FgGreen),
+		Removed: color.New(color.FgRed),
+		Small:   color.New(color.FgGreen),
+		Medium:  color.New(color.FgMagenta),
+		Large:   color.New(color.FgRed, color.Bold),
+	},
+	"mono": {
+		Header:  color.New(color.Bold),
+		Added:   color.New(color.Bold),
+		Removed: color.New(color.Bold),
+		Small:   color.New(color.Reset),
+		Medium:  color.New(color.Bold),
+		Large:   color.New(color.Bold, color.Underline),
+	},
+}
+
+// theme is the theme of the human-readable output
+var theme = withSizes(themes["default"], defaultMediumSize, defaultLargeSize)
+
+func withSizes(t Theme, medium, large uint64) Theme {
+	t.MediumSize = medium
+	t.LargeSize = large
+	return t
+}
+
+// setTheme provides selection of the theme by name
+// with sizes of layers colored as medium and large
+func setTheme(name string, medium, large uint64) error {
+	t, ok := themes[name]
+	if !ok {
+		return fmt.Errorf("unknown theme: %s", name)
+	}
+	if medium > large {
+		return fmt.Errorf("medium layer size is larger than large layer size")
+	}
+	theme = withSizes(t, medium, large)
+	return nil
+}
+
+// layer returns color of the layer header by size of the layer
+func (t Theme) layer(size uint64) *color.Color {
+	switch {
+	case size >= t.LargeSize:
+		return t.Large
+	case size >= t.MediumSize:
+		return t.Medium
+	}
+	return t.Small
+}
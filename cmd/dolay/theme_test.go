@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/fatih/color"
+)
+
+func TestThemeLayer(t *testing.T) {
+	withoutColor(t)
+	saved := theme
+	t.Cleanup(func() { theme = saved })
+	if err := setTheme("light", 1000, 5000); err != nil {
+		t.Fatalf("setTheme() error = %v", err)
+	}
+	tests := []struct {
+		size uint64
+		want *color.Color
+		name string
+	}{
+		{0, theme.Small, "small"},
+		{999, theme.Small, "small"},
+		{1000, theme.Medium, "medium"},
+		{4999, theme.Medium, "medium"},
+		{5000, theme.Large, "large"},
+		{1 << 40, theme.Large, "large"},
+	}
+	for _, tt := range tests {
+		if got := theme.layer(tt.size); got != tt.want {
+			t.Errorf("layer(%d) isn't the %s color", tt.size, tt.name)
+		}
+	}
+	if theme.layer(0).Sprint("a") != "a" {
+		t.Errorf("colors are applied with -no-color")
+	}
+
+	invalid := []struct {
+		name          string
+		medium, large uint64
+	}{
+		{"neon", 1, 2},
+		{"dark", 10, 1},
+	}
+	for _, tt := range invalid {
+		if err := setTheme(tt.name, tt.medium, tt.large); err == nil {
+			t.Errorf("setTheme(%s, %d, %d) error = nil", tt.name, tt.medium, tt.large)
+		}
+	}
+	for name := range themes {
+		if err := setTheme(name, defaultMediumSize, defaultLargeSize); err != nil {
+			t.Errorf("setTheme(%s) error = %v", name, err)
+		}
+	}
+}
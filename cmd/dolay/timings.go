@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/saromanov/dolay"
+)
+
+// slowestLayers defines number of layers in the timings summary
+const slowestLayers = 5
+
+// layerTiming defines time of the layer decoding
+type layerTiming struct {
+	path string
+	size uint64
+	took time.Duration
+}
+
+// timings provides measuring of the archive reading
+// and of decoding of each layer
+type timings struct {
+	start  time.Time
+	total  time.Duration
+	layers []layerTiming
+}
+
+func newTimings() *timings {
+	return &timings{start: time.Now()}
+}
+
+// track returns options which record decode time of layers
+func (t *timings) track(opts dolay.Options) dolay.Options {
+	keep := opts.Keep
+	opts.Keep = func(l *dolay.Layer) *dolay.Layer {
+		t.record(l)
+		if keep != nil {
+			return keep(l)
+		}
+		return l
+	}
+	return opts
+}
+
+// record provides adding of the layer decode time
+func (t *timings) record(l *dolay.Layer) {
+	t.layers = append(t.layers, layerTiming{path: l.Path, size: l.Size, took: l.DecodeTime})
+}
+
+// stop provides stopping of the archive reading measure
+func (t *timings) stop() {
+	t.total = time.Since(t.start)
+}
+
+// print provides output of the total time, time of decoding
+// of all layers and the slowest layers
+func (t *timings) print(w io.Writer) {
+	if t.total == 0 {
+		t.stop()
+	}
+	var decode time.Duration
+	for _, l := range t.layers {
+		decode += l.took
+	}
+	sorted := append([]layerTiming(nil), t.layers...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].took > sorted[j].took
+	})
+	if len(sorted) > slowestLayers {
+		sorted = sorted[:slowestLayers]
+	}
+	if logFormat == logJSON {
+		slowest := make([]map[string]interface{}, 0, len(sorted))
+		for _, l := range sorted {
+			slowest = append(slowest, map[string]interface{}{"path": l.path, "size": l.size, "took": l.took.String()})
+		}
+		logf(levelInfo, map[string]interface{}{"total": t.total.String(), "decode": decode.String(),
+			"layers": len(t.layers), "slowest": slowest}, "timings")
+		return
+	}
+	fmt.Fprintf(w, "timings: total %v, decoding of %d layers %v\n", t.total, len(t.layers), decode)
+	for _, l := range sorted {
+		fmt.Fprintf(w, "  %12v %s %s\n", l.took, humanizeBytes(l.size), l.path)
+	}
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/saromanov/dolay"
+)
+
+// tuiRow defines line of the interactive view. It's either
+// the layer header or the entry of the layer tree
+type tuiRow struct {
+	layer int
+	node  *dolay.Node
+	depth int
+}
+
+// tui defines state of the interactive view over layers
+type tui struct {
+	screen tcell.Screen
+	layers []*dolay.Layer
+	trees  []*dolay.Node
+	// expanded contains opened layers and directories
+	expanded map[interface{}]bool
+	rows     []tuiRow
+	cursor   int
+	offset   int
+	byName   bool
+	filter   string
+	// input is the filter being typed after "/"
+	input   string
+	editing bool
+}
+
+// runTUI provides interactive exploring of layers. Arrow keys move
+// the cursor, Enter expands layers and directories, "/" filters
+// files by substring, "s" switches sorting and "q" quits
+func runTUI(layers []*dolay.Layer) error {
+	screen, err := tcell.NewScreen()
+	if err != nil {
+		return fmt.Errorf("unable to create screen: %v", err)
+	}
+	if err := screen.Init(); err != nil {
+		return fmt.Errorf("unable to init screen: %v", err)
+	}
+	defer screen.Fini()
+
+	t := &tui{
+		screen:   screen,
+		layers:   layers,
+		expanded: make(map[interface{}]bool),
+	}
+	t.build()
+	for {
+		t.draw()
+		switch ev := screen.PollEvent().(type) {
+		case *tcell.EventResize:
+			screen.Sync()
+		case *tcell.EventKey:
+			if !t.handle(ev) {
+				return nil
+			}
+		}
+	}
+}
+
+// build provides building of layer trees by the filter
+func (t *tui) build() {
+	t.trees = make([]*dolay.Node, len(t.layers))
+	for i, l := range t.layers {
+		var files dolay.Files
+		for _, f := range l.Files {
+			if strings.Contains(f.Name, t.filter) {
+				files = append(files, f)
+			}
+		}
+		t.trees[i] = dolay.NewTree(files)
+	}
+	t.flatten()
+}
+
+// flatten provides building of visible rows from expanded entries
+func (t *tui) flatten() {
+	t.rows = t.rows[:0]
+	for i := range t.layers {
+		t.rows = append(t.rows, tuiRow{layer: i})
+		if t.expanded[t.layers[i]] {
+			t.appendChildren(i, t.trees[i], 1)
+		}
+	}
+	if t.cursor >= len(t.rows) {
+		t.cursor = len(t.rows) - 1
+	}
+}
+
+func (t *tui) appendChildren(layer int, n *dolay.Node, depth int) {
+	children := n.Children
+	if t.byName {
+		children = append([]*dolay.Node(nil), children...)
+		sort.Slice(children, func(i, j int) bool {
+			return children[i].Name < children[j].Name
+		})
+	}
+	for _, c := range children {
+		t.rows = append(t.rows, tuiRow{layer: layer, node: c, depth: depth})
+		if c.IsDir() && t.expanded[c] {
+			t.appendChildren(layer, c, depth+1)
+		}
+	}
+}
+
+// handle provides handling of the key. false is returned on quit
+func (t *tui) handle(ev *tcell.EventKey) bool {
+	if t.editing {
+		switch ev.Key() {
+		case tcell.KeyEnter:
+			t.editing = false
+			t.filter = t.input
+			t.build()
+		case tcell.KeyEscape:
+			t.editing = false
+		case tcell.KeyBackspace, tcell.KeyBackspace2:
+			if len(t.input) > 0 {
+				r := []rune(t.input)
+				t.input = string(r[:len(r)-1])
+			}
+		case tcell.KeyRune:
+			t.input += string(ev.Rune())
+		}
+		return true
+	}
+	_, height := t.screen.Size()
+	page := height - 2
+	switch ev.Key() {
+	case tcell.KeyCtrlC, tcell.KeyEscape:
+		return false
+	case tcell.KeyUp:
+		t.move(-1)
+	case tcell.KeyDown:
+		t.move(1)
+	case tcell.KeyPgUp:
+		t.move(-page)
+	case tcell.KeyPgDn:
+		t.move(page)
+	case tcell.KeyHome:
+		t.move(-len(t.rows))
+	case tcell.KeyEnd:
+		t.move(len(t.rows))
+	case tcell.KeyEnter:
+		t.toggle()
+	case tcell.KeyRune:
+		switch ev.Rune() {
+		case 'q':
+			return false
+		case '/':
+			t.editing = true
+			t.input = t.filter
+		case 's':
+			t.byName = !t.byName
+			t.flatten()
+		}
+	}
+	return true
+}
+
+func (t *tui) move(delta int) {
+	t.cursor += delta
+	if t.cursor >= len(t.rows) {
+		t.cursor = len(t.rows) - 1
+	}
+	if t.cursor < 0 {
+		t.cursor = 0
+	}
+}
+
+// toggle provides expanding or collapsing of the row at the cursor
+func (t *tui) toggle() {
+	if len(t.rows) == 0 {
+		return
+	}
+	row := t.rows[t.cursor]
+	var key interface{} = t.layers[row.layer]
+	if row.node != nil {
+		if !row.node.IsDir() {
+			return
+		}
+		key = row.node
+	}
+	t.expanded[key] = !t.expanded[key]
+	t.flatten()
+}
+
+// draw provides drawing of visible rows with the status line
+func (t *tui) draw() {
+	t.screen.Clear()
+	width, height := t.screen.Size()
+	view := height - 2
+	if t.cursor < t.offset {
+		t.offset = t.cursor
+	}
+	if view > 0 && t.cursor >= t.offset+view {
+		t.offset = t.cursor - view + 1
+	}
+
+	summary := summarize(t.layers)
+	title := fmt.Sprintf("dolay: %d layers, %d files, %s", summary.Layers, summary.Files, formatBytes(summary.Size))
+	t.text(0, 0, width, title, tcell.StyleDefault.Bold(true))
+	for y := 0; y < view && t.offset+y < len(t.rows); y++ {
+		i := t.offset + y
+		style := tcell.StyleDefault
+		if i == t.cursor {
+			style = style.Reverse(true)
+		}
+		t.text(0, y+1, width, t.line(t.rows[i]), style)
+	}
+
+	sorting := "size"
+	if t.byName {
+		sorting = "name"
+	}
+	status := fmt.Sprintf("enter: expand  /: filter  s: sort (%s)  q: quit", sorting)
+	if t.filter != "" {
+		status = fmt.Sprintf("filter: %s  %s", t.filter, status)
+	}
+	if t.editing {
+		status = "/" + t.input
+	}
+	t.text(0, height-1, width, status, tcell.StyleDefault.Dim(true))
+	t.screen.Show()
+}
+
+// line returns text of the row
+func (t *tui) line(row tuiRow) string {
+	if row.node == nil {
+		l := t.layers[row.layer]
+		mark := "+"
+		if t.expanded[l] {
+			mark = "-"
+		}
+		return fmt.Sprintf("%s %s [%d files] $ %s", mark, humanizeBytes(l.Size),
+			len(l.Files), singleLine(dolay.Command(l.History)))
+	}
+	name := row.node.Name
+	if row.node.IsDir() {
+		name += "/"
+	}
+	return fmt.Sprintf("  %s %s%s", humanizeBytes(row.node.Size), strings.Repeat("  ", row.depth), name)
+}
+
+// text provides drawing of s at the line cut to the width
+func (t *tui) text(x, y, width int, s string, style tcell.Style) {
+	for _, r := range s {
+		if x >= width {
+			return
+		}
+		t.screen.SetContent(x, y, r, nil, style)
+		x++
+	}
+	for ; x < width; x++ {
+		t.screen.SetContent(x, y, ' ', nil, style)
+	}
+}
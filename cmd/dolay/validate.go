@@ -0,0 +1,58 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/saromanov/dolay"
+)
+
+// errInvalid is returned when the archive fails validation
+var errInvalid = errors.New("archive is invalid")
+
+// validateArchive provides checking of the archive integrity without
+// the listing. The whole archive is read, so missing layers, broken
+// tars of layers and mismatch of layers and history are reported
+func validateArchive(w io.Writer, r io.Reader, analysis dolay.Options, raw bool) error {
+	result, err := checkArchive(r, analysis, raw)
+	if err != nil {
+		fmt.Fprintln(w, theme.Removed.Sprintf("FAIL: %v", err))
+		return errInvalid
+	}
+	fmt.Fprintln(w, theme.Added.Sprintf("PASS: %s", result))
+	return nil
+}
+
+// checkArchive returns description of the valid archive or the
+// problem of the archive. Files are dropped after the layer is read
+func checkArchive(r io.Reader, analysis dolay.Options, raw bool) (string, error) {
+	if raw {
+		layer, err := dolay.ReadLayer(r)
+		if err != nil {
+			return "", fmt.Errorf("layer is broken: %v", err)
+		}
+		return fmt.Sprintf("layer with %d files", len(layer.Files)), nil
+	}
+	keep := analysis.Keep
+	analysis.Keep = func(l *dolay.Layer) *dolay.Layer {
+		if keep != nil {
+			l = keep(l)
+		}
+		return &dolay.Layer{Path: l.Path, Size: l.Size}
+	}
+	report, err := dolay.AnalyzeWithOptions(r, analysis)
+	if err != nil {
+		return "", err
+	}
+	var records int
+	for _, h := range report.History {
+		if !h.EmptyLayer {
+			records++
+		}
+	}
+	if records != len(report.Layers) && (records > 0 || !analysis.NoHistory) {
+		return "", fmt.Errorf("manifest has %d layers, but history has %d non-empty records", len(report.Layers), records)
+	}
+	return fmt.Sprintf("%d layers, %d history records", len(report.Layers), len(report.History)), nil
+}
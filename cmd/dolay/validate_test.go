@@ -0,0 +1,71 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/saromanov/dolay"
+)
+
+// withoutEntry returns the archive without the entry of the name
+func withoutEntry(t *testing.T, data []byte, name string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tr, tw := tar.NewReader(bytes.NewReader(data)), tar.NewWriter(&buf)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if h.Name == name {
+			continue
+		}
+		if err := tw.WriteHeader(h); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.Copy(tw, tr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestValidateArchive(t *testing.T) {
+	withoutColor(t)
+	base := testTar(t, testFile("bin/sh", 100), testFile("etc/passwd", 20))
+	layer := testTar(t, testFile("usr/bin/curl", 3000))
+	tests := []struct {
+		name string
+		data []byte
+		raw  bool
+		want string
+	}{
+		{"valid", testArchive(t, base, layer), false, "PASS: 2 layers, 2 history records"},
+		{"missing layer", withoutEntry(t, testArchive(t, base, layer), "1/layer.tar"), false, "FAIL: layer is not found in archive: 1/layer.tar"},
+		{"truncated layer", testArchive(t, base, layer[:1000]), false, "FAIL: corrupt layer 1/layer.tar"},
+		{"valid layer", layer, true, "PASS: layer with 1 files"},
+		{"truncated raw layer", layer[:1000], true, "FAIL: layer is broken"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := validateArchive(&buf, bytes.NewReader(tt.data), dolay.Options{}, tt.raw)
+			if strings.HasPrefix(tt.want, "FAIL") != errors.Is(err, errInvalid) {
+				t.Errorf("validateArchive() error = %v, output %q", err, buf.String())
+			}
+			if !strings.HasPrefix(buf.String(), tt.want) {
+				t.Errorf("validateArchive() output = %q, want %q", buf.String(), tt.want)
+			}
+		})
+	}
+}
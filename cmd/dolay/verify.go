@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/saromanov/dolay"
+)
+
+// errDigestMismatch is returned when layers don't match their digests
+var errDigestMismatch = errors.New("layers don't match their digests")
+
+// verifyDigests provides checking of sha256 of layer blobs against
+// digests of the manifest. Files are dropped after the layer is read
+func verifyDigests(w io.Writer, r io.Reader, analysis dolay.Options, asJSON bool) error {
+	analysis.VerifyDigests = true
+	keep := analysis.Keep
+	analysis.Keep = func(l *dolay.Layer) *dolay.Layer {
+		if keep != nil {
+			l = keep(l)
+		}
+		return &dolay.Layer{Path: l.Path, Size: l.Size, Compression: l.Compression, BlobDigest: l.BlobDigest}
+	}
+	report, err := dolay.AnalyzeWithOptions(r, analysis)
+	if err != nil {
+		return err
+	}
+	checks := dolay.CheckDigests(report.Layers, report.Image.RootFS.DiffIDs)
+	var failed int
+	for _, c := range checks {
+		if !c.OK() {
+			failed++
+		}
+	}
+	if asJSON {
+		if err := writeJSON(w, checks); err != nil {
+			return err
+		}
+	} else {
+		printDigestChecks(w, checks)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%w: %d of %d", errDigestMismatch, failed, len(checks))
+	}
+	return nil
+}
+
+// printDigestChecks provides human-readable output of digest checks
+func printDigestChecks(w io.Writer, checks []dolay.DigestCheck) {
+	for _, c := range checks {
+		switch {
+		case c.Expected == "":
+			fmt.Fprintf(w, "%3d  ?       %s %s (no expected digest)\n", c.Index, c.Actual, c.Path)
+		case c.OK():
+			fmt.Fprintln(w, theme.Added.Sprintf("%3d  OK      %s %s", c.Index, c.Actual, c.Path))
+		default:
+			fmt.Fprintln(w, theme.Removed.Sprintf("%3d  FAIL    %s %s (expected %s)", c.Index, c.Actual, c.Path, c.Expected))
+		}
+	}
+}
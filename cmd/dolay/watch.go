@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-isatty"
+)
+
+// watchInterval defines interval of checking of the watched archive
+var watchInterval = time.Second
+
+// watchArchive provides re-running of the analysis whenever the archive
+// changes. Analysis runs in a child process with the same arguments
+// except -watch, so each run starts from the clean state. The archive
+// is compared by identity, size and mtime, so replacing by rename is
+// noticed, and runs are delayed until the archive is not changing
+func watchArchive(path string) error {
+	if path == "-" || isURL(path) {
+		return fmt.Errorf("-watch requires path of the archive file")
+	}
+	var last os.FileInfo
+	for {
+		info, err := stableStat(path)
+		if err != nil {
+			return err
+		}
+		if last == nil || !sameState(last, info) {
+			last = info
+			if isatty.IsTerminal(os.Stdout.Fd()) {
+				fmt.Print("\x1b[H\x1b[2J")
+			}
+			cmd := exec.Command(os.Args[0], withoutWatch(os.Args[1:])...)
+			cmd.Stdin = os.Stdin
+			cmd.Stdout = os.Stdout
+			cmd.Stderr = os.Stderr
+			if err := cmd.Run(); err != nil {
+				logf(levelError, map[string]interface{}{"error": err.Error()}, "analysis failed: %v", err)
+			}
+			logf(levelInfo, map[string]interface{}{"path": path}, "watching %s for changes", path)
+		}
+		time.Sleep(watchInterval)
+	}
+}
+
+// stableStat returns info of the file when it has not changed
+// during the interval. Missing file is waited for, since it can
+// be replaced at the moment
+func stableStat(path string) (os.FileInfo, error) {
+	var prev os.FileInfo
+	for {
+		info, err := os.Stat(path)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("unable to stat archive: %v", err)
+		}
+		if err == nil && prev != nil && sameState(prev, info) {
+			return info, nil
+		}
+		prev = info
+		time.Sleep(watchInterval)
+	}
+}
+
+// sameState returns true if both infos describe the same unchanged file
+func sameState(a, b os.FileInfo) bool {
+	return os.SameFile(a, b) && a.Size() == b.Size() && a.ModTime().Equal(b.ModTime())
+}
+
+// withoutWatch returns arguments without the -watch flag
+func withoutWatch(args []string) []string {
+	result := make([]string, 0, len(args))
+	for _, arg := range args {
+		name := strings.TrimLeft(arg, "-")
+		if strings.HasPrefix(arg, "-") && (name == "watch" || strings.HasPrefix(name, "watch=")) {
+			continue
+		}
+		result = append(result, arg)
+	}
+	return result
+}
@@ -0,0 +1,64 @@
+package dolay
+
+import "strings"
+
+// ShellPrefixes contains wrappers of RUN commands which are stripped
+// from history records. Anything before the wrapper, like BuildKit
+// "RUN" and build arguments ("|1 VERSION=1.0"), is stripped too
+var ShellPrefixes = []string{
+	"/bin/sh -c ",
+	"/bin/bash -o pipefail -c ",
+	"/bin/bash -c ",
+	"cmd /S /C ",
+}
+
+const (
+	// nopMarker marks metadata instructions of the classic builder
+	nopMarker = "#(nop)"
+	// buildkitMarker is the suffix of records made by BuildKit
+	buildkitMarker = "# buildkit"
+)
+
+// execForms contains instructions which can have JSON array argument
+var execForms = map[string]bool{
+	"CMD":        true,
+	"ENTRYPOINT": true,
+	"SHELL":      true,
+	"RUN":        true,
+}
+
+// CleanCommand returns readable command from the created_by record:
+//
+//	/bin/sh -c #(nop)  CMD ["/bin/sh" "-c" "x"]  ->  CMD ["/bin/sh", "-c", "x"]
+//	|1 VERSION=1.0 /bin/sh -c make               ->  make
+//	RUN /bin/sh -c apk add curl # buildkit       ->  apk add curl
+//	COPY . /app # buildkit                       ->  COPY . /app
+func CleanCommand(createdBy string) string {
+	cmd := strings.TrimSpace(createdBy)
+	cmd = strings.TrimSpace(strings.TrimSuffix(cmd, buildkitMarker))
+	// the earliest wrapper is stripped, since the command
+	// itself can contain another one
+	start := -1
+	for _, p := range ShellPrefixes {
+		if i := strings.Index(cmd, p); i >= 0 && (start < 0 || i < start) {
+			start = i + len(p)
+		}
+	}
+	if start >= 0 {
+		cmd = cmd[start:]
+	}
+	cmd = strings.TrimSpace(cmd)
+	if strings.HasPrefix(cmd, nopMarker) {
+		cmd = strings.TrimSpace(strings.TrimPrefix(cmd, nopMarker))
+	}
+	tokens := strings.SplitN(cmd, " ", 2)
+	if len(tokens) == 2 && execForms[tokens[0]] {
+		args := strings.TrimSpace(tokens[1])
+		// classic builder prints arrays without commas
+		if strings.HasPrefix(args, "[") && strings.HasSuffix(args, "]") && !strings.Contains(args, "\",") {
+			args = strings.Replace(args, "\" \"", "\", \"", -1)
+		}
+		cmd = tokens[0] + " " + args
+	}
+	return cmd
+}
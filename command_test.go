@@ -0,0 +1,47 @@
+package dolay
+
+import "testing"
+
+func TestCleanCommand(t *testing.T) {
+	tests := []struct {
+		name      string
+		createdBy string
+		want      string
+	}{
+		{"classic run", "/bin/sh -c apk add --no-cache curl", "apk add --no-cache curl"},
+		{"classic add", "/bin/sh -c #(nop) ADD file:9a4f77dfaba7fd2aa78186e4ef0e7486ad55101cefc1fabbc1b385601bb38920 in / ",
+			"ADD file:9a4f77dfaba7fd2aa78186e4ef0e7486ad55101cefc1fabbc1b385601bb38920 in /"},
+		{"classic cmd", `/bin/sh -c #(nop)  CMD ["/bin/sh" "-c" "nginx -g 'daemon off;'"]`, `CMD ["/bin/sh", "-c", "nginx -g 'daemon off;'"]`},
+		{"classic entrypoint", `/bin/sh -c #(nop)  ENTRYPOINT ["docker-entrypoint.sh"]`, `ENTRYPOINT ["docker-entrypoint.sh"]`},
+		{"classic env", "/bin/sh -c #(nop)  ENV PATH=/usr/local/bin:/usr/bin", "ENV PATH=/usr/local/bin:/usr/bin"},
+		{"buildkit run", "RUN /bin/sh -c apt-get update && apt-get install -y curl # buildkit", "apt-get update && apt-get install -y curl"},
+		{"buildkit args", "RUN |2 VERSION=1.21 TARGETOS=linux /bin/sh -c go build -o /app . # buildkit", "go build -o /app ."},
+		{"buildkit pipefail", "RUN /bin/bash -o pipefail -c curl -fsSL https://deb.nodesource.com/setup | bash - # buildkit",
+			"curl -fsSL https://deb.nodesource.com/setup | bash -"},
+		{"buildkit copy", "COPY . /app # buildkit", "COPY . /app"},
+		{"buildkit copy from", "COPY /out/app /usr/local/bin/app # buildkit", "COPY /out/app /usr/local/bin/app"},
+		{"buildkit cmd", `CMD ["node" "server.js"]`, `CMD ["node", "server.js"]`},
+		{"buildkit cmd with commas", `CMD ["node", "server.js"]`, `CMD ["node", "server.js"]`},
+		{"buildkit workdir", "WORKDIR /app", "WORKDIR /app"},
+		{"buildkit exec run", `RUN ["apk" "add" "curl"] # buildkit`, `RUN ["apk", "add", "curl"]`},
+		{"nested shell", `/bin/sh -c sh -c "/bin/sh -c true"`, `sh -c "/bin/sh -c true"`},
+		{"windows", `cmd /S /C powershell -Command Install-Module`, `powershell -Command Install-Module`},
+		{"empty", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CleanCommand(tt.createdBy); got != tt.want {
+				t.Errorf("CleanCommand(%q) = %q, want %q", tt.createdBy, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShellPrefixes(t *testing.T) {
+	saved := ShellPrefixes
+	t.Cleanup(func() { ShellPrefixes = saved })
+	ShellPrefixes = append(ShellPrefixes, "/bin/ash -eo pipefail -c ")
+	if got := CleanCommand("RUN /bin/ash -eo pipefail -c make install # buildkit"); got != "make install" {
+		t.Errorf("CleanCommand() with the custom prefix = %q, want %q", got, "make install")
+	}
+}
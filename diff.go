@@ -0,0 +1,115 @@
+package dolay
+
+import (
+	"sort"
+)
+
+// Change marks
+const (
+	ChangeAdded    = "+"
+	ChangeRemoved  = "-"
+	ChangeModified = "~"
+)
+
+// FileDiff defines change of the file between layers
+type FileDiff struct {
+	Change string `json:"change"`
+	Name   string `json:"name"`
+	Delta  int64  `json:"delta"`
+}
+
+// LayerDiff defines change of the layer between two images
+type LayerDiff struct {
+	Change  string     `json:"change"`
+	Command string     `json:"command"`
+	Size    uint64     `json:"size"`
+	Delta   int64      `json:"delta"`
+	Files   []FileDiff `json:"files,omitempty"`
+}
+
+// layerKey returns key for matching of layers between images.
+// Layers are matched by the command, and by path if command is unknown
+func layerKey(l *Layer) string {
+	if l.History.CreatedBy != "" {
+		return l.History.CreatedBy
+	}
+	return l.Path
+}
+
+// Diff returns changes from the old image to the new one.
+// Layers with the same key are compared file by file
+func Diff(old, new []*Layer) []LayerDiff {
+	byKey := make(map[string][]int)
+	for i, l := range old {
+		key := layerKey(l)
+		byKey[key] = append(byKey[key], i)
+	}
+
+	result := []LayerDiff{}
+	matched := make(map[int]bool)
+	for _, l := range new {
+		key := layerKey(l)
+		candidates := byKey[key]
+		if len(candidates) == 0 {
+			result = append(result, LayerDiff{
+				Change:  ChangeAdded,
+				Command: Command(l.History),
+				Size:    l.Size,
+				Delta:   int64(l.Size),
+			})
+			continue
+		}
+		prev := old[candidates[0]]
+		byKey[key] = candidates[1:]
+		matched[candidates[0]] = true
+		files := diffFiles(prev.Files, l.Files)
+		if len(files) == 0 {
+			continue
+		}
+		result = append(result, LayerDiff{
+			Change:  ChangeModified,
+			Command: Command(l.History),
+			Size:    l.Size,
+			Delta:   int64(l.Size) - int64(prev.Size),
+			Files:   files,
+		})
+	}
+	for i, l := range old {
+		if matched[i] {
+			continue
+		}
+		result = append(result, LayerDiff{
+			Change:  ChangeRemoved,
+			Command: Command(l.History),
+			Size:    l.Size,
+			Delta:   -int64(l.Size),
+		})
+	}
+	return result
+}
+
+// diffFiles returns changes of files sorted by name
+func diffFiles(old, new Files) []FileDiff {
+	sizes := make(map[string]int64, len(old))
+	for _, f := range old {
+		sizes[f.Name] = f.Size
+	}
+	var result []FileDiff
+	for _, f := range new {
+		size, ok := sizes[f.Name]
+		delete(sizes, f.Name)
+		switch {
+		case !ok:
+			result = append(result, FileDiff{Change: ChangeAdded, Name: f.Name, Delta: f.Size})
+		case size != f.Size:
+			result = append(result, FileDiff{Change: ChangeModified, Name: f.Name, Delta: f.Size - size})
+		}
+	}
+	for name, size := range sizes {
+		result = append(result, FileDiff{Change: ChangeRemoved, Name: name, Delta: -size})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
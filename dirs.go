@@ -0,0 +1,57 @@
+package dolay
+
+import (
+	"path"
+	"sort"
+)
+
+// RootDir defines label of the image root directory
+const RootDir = "/"
+
+// DirStat defines totals of files in the directory
+type DirStat struct {
+	Dir   string `json:"dir"`
+	Count int    `json:"count"`
+	Size  uint64 `json:"size"`
+}
+
+// ByDirectory returns files grouped by the parent directory, sorted
+// by total size from the largest. If recursive is true, files are
+// counted in all ancestor directories too, so "usr" contains "usr/lib"
+func ByDirectory(files Files, recursive bool) []DirStat {
+	groups := make(map[string]*DirStat)
+	add := func(dir string, size uint64) {
+		g, ok := groups[dir]
+		if !ok {
+			g = &DirStat{Dir: dir}
+			groups[dir] = g
+		}
+		g.Count++
+		g.Size += size
+	}
+	for _, f := range files {
+		dir := path.Dir(path.Clean(f.Name))
+		if !recursive {
+			if dir == "." {
+				dir = RootDir
+			}
+			add(dir, uint64(f.Size))
+			continue
+		}
+		for ; dir != "." && dir != "/"; dir = path.Dir(dir) {
+			add(dir, uint64(f.Size))
+		}
+		add(RootDir, uint64(f.Size))
+	}
+	stats := make([]DirStat, 0, len(groups))
+	for _, g := range groups {
+		stats = append(stats, *g)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Size != stats[j].Size {
+			return stats[i].Size > stats[j].Size
+		}
+		return stats[i].Dir < stats[j].Dir
+	})
+	return stats
+}
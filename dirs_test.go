@@ -0,0 +1,45 @@
+package dolay
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestByDirectory(t *testing.T) {
+	files := Files{
+		regular("usr/lib/libc.so", 500).Header,
+		regular("usr/lib/python3/os.py", 300).Header,
+		regular("usr/lib/python3/re.py", 200).Header,
+		regular("usr/bin/curl", 100).Header,
+		regular("./etc/passwd", 50).Header,
+		regular("init", 10).Header,
+	}
+	tests := []struct {
+		name      string
+		recursive bool
+		want      []DirStat
+	}{
+		{"parent directories", false, []DirStat{
+			{Dir: "usr/lib", Count: 1, Size: 500},
+			{Dir: "usr/lib/python3", Count: 2, Size: 500},
+			{Dir: "usr/bin", Count: 1, Size: 100},
+			{Dir: "etc", Count: 1, Size: 50},
+			{Dir: RootDir, Count: 1, Size: 10},
+		}},
+		{"recursive", true, []DirStat{
+			{Dir: RootDir, Count: 6, Size: 1160},
+			{Dir: "usr", Count: 4, Size: 1100},
+			{Dir: "usr/lib", Count: 3, Size: 1000},
+			{Dir: "usr/lib/python3", Count: 2, Size: 500},
+			{Dir: "usr/bin", Count: 1, Size: 100},
+			{Dir: "etc", Count: 1, Size: 50},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ByDirectory(files, tt.recursive); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ByDirectory() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,526 @@
+// Package dolay provides analysis of docker image archives.
+//
+// Archive can be produced by "docker save" or be an OCI image layout:
+//
+//	f, err := os.Open("image.tar")
+//	if err != nil {
+//		return err
+//	}
+//	defer f.Close()
+//	report, err := dolay.Analyze(f)
+//	if err != nil {
+//		return err
+//	}
+//	for _, layer := range report.Layers {
+//		fmt.Println(layer.Size, dolay.Command(layer.History))
+//	}
+package dolay
+
+import (
+	"archive/tar"
+	"bufio"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+	"time"
+)
+
+// Files defines type for tar headers
+type Files []*tar.Header
+
+// Implementation of sorting for headers
+
+// Len returns length of header
+func (s Files) Len() int {
+	return len(s)
+}
+
+// Swap provides swaping of two headers
+func (s Files) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+// Less provides compare of two files
+func (s Files) Less(i, j int) bool {
+	if s[i].Size != s[j].Size {
+		return s[i].Size > s[j].Size
+	}
+	return s[i].Name < s[j].Name
+}
+
+// ManifestItem defines manifest
+// for the docker manifest
+type ManifestItem struct {
+	Config   string
+	RepoTags []string
+	Layers   []string
+	// LayerSources contains descriptors of layers by digest.
+	// It's written by the newer docker versions
+	LayerSources map[string]Descriptor `json:",omitempty"`
+}
+
+// History defines struct for the layer's history
+type History struct {
+	Created    time.Time `json:"created,omitempty"`
+	EmptyLayer bool      `json:"empty_layer,omitempty"`
+	CreatedBy  string    `json:"created_by,omitempty"`
+}
+
+// ImageConfig defines runtime configuration of the image
+type ImageConfig struct {
+	User         string              `json:"User,omitempty"`
+	ExposedPorts map[string]struct{} `json:"ExposedPorts,omitempty"`
+	Env          []string            `json:"Env,omitempty"`
+	Entrypoint   []string            `json:"Entrypoint,omitempty"`
+	Cmd          []string            `json:"Cmd,omitempty"`
+	WorkingDir   string              `json:"WorkingDir,omitempty"`
+	Labels       map[string]string   `json:"Labels,omitempty"`
+}
+
+// Image defines image config
+type Image struct {
+	Created      time.Time   `json:"created,omitempty"`
+	Architecture string      `json:"architecture,omitempty"`
+	OS           string      `json:"os,omitempty"`
+	Config       ImageConfig `json:"config,omitempty"`
+	History      []History   `json:"history,omitempty"`
+	RootFS       RootFS      `json:"rootfs,omitempty"`
+}
+
+// RootFS defines layers of the image filesystem
+// by digests of their uncompressed content
+type RootFS struct {
+	DiffIDs []string `json:"diff_ids,omitempty"`
+}
+
+// Layer defines docker layer
+type Layer struct {
+	// Index is position of the layer in the image from the bottom
+	Index int
+	// Path is the layer path inside of the archive
+	Path  string
+	Files Files
+	// Count is number of files of the layer, which is more than
+	// number of Files if they are limited by Options.MaxFiles
+	Count int
+	// Size is total logical size of files from tar headers. Holes
+	// of sparse files are counted, and links have no size
+	Size uint64
+	// Dirs is number of directories in the layer
+	Dirs int
+	// Whiteouts contains entries which mark deletions
+	Whiteouts Files
+	// History is the record which produced the layer
+	History History
+	// DecodeTime is time spent on reading of the layer content
+	DecodeTime time.Duration
+	// Compression is the compression of the layer blob
+	// (CompressionGzip or CompressionZstd), or empty string
+	Compression string
+	// BlobSize is size of the layer entry in the archive, so it's
+	// the compressed size for compressed layers. It's zero for
+	// standalone layers
+	BlobSize uint64
+	// Archives contains tar archives among files of the layer,
+	// which are expanded if Options.Recurse is set
+	Archives []*Archive
+	// BlobDigest is sha256 of the layer entry in the archive if
+	// Options.VerifyDigests is set
+	BlobDigest string
+}
+
+// Report defines result of the image archive analysis
+type Report struct {
+	// Manifest is the analyzed image
+	Manifest ManifestItem
+	// Manifests contains all images of the archive
+	Manifests []ManifestItem
+	Image     Image
+	// History contains all history records including empty layers
+	History []History
+	// Layers contains layers of the image from the bottom to the top
+	Layers []*Layer
+}
+
+// Options defines options of the archive analysis
+type Options struct {
+	// Select returns index of the image to analyze from manifests
+	// of the archive. The first image is analyzed if it's nil
+	Select func(manifests []ManifestItem) (int, error)
+	// Keep is called for each layer after it's read and returns
+	// layer to hold, so it allows to drop data which is not needed
+	Keep func(*Layer) *Layer
+	// Jobs is number of layers decoded in parallel. Content of
+	// the layer is buffered in memory before decoding, so it's
+	// useful for compressed layers. Layers are decoded at the
+	// reading goroutine if it's less than 2
+	Jobs int
+	// NoHistory allows archives without the image config or history.
+	// Layers are listed in order of the manifest without history
+	// records then
+	NoHistory bool
+	// Content is called for each regular file of layers with the reader
+	// of its content, and layer is path of the layer in the archive.
+	// Contents are skipped if it's nil. Layers are decoded at the reading
+	// goroutine when it's set, so it doesn't need synchronization
+	Content func(layer string, h *tar.Header, r io.Reader) error
+	// Recurse is depth of expanding of tar archives among files of
+	// layers. Content isn't called for expanded archives
+	Recurse int
+	// MaxFiles limits files held for each layer to the largest ones
+	// passing Match (or all files if it's nil), so memory doesn't grow
+	// with number of files of layers. Size and Count of layers are of
+	// all files. Files are not limited if it's zero
+	MaxFiles int
+	Match    func(*tar.Header) bool
+	// VerifyDigests enables computing of sha256 of layer entries as they
+	// are stored in the archive. It's the digest of the compressed blob
+	// for compressed layers, which matches the distribution digest of
+	// the manifest, and not the diff ID of the uncompressed content
+	VerifyDigests bool
+}
+
+// archive defines parsed content of the image archive
+type archive struct {
+	manifests []ManifestItem
+	// manifest is the analyzed image
+	manifest *ManifestItem
+	image    Image
+	layers   map[string]*Layer
+	index    []byte
+	// resolved is set when the manifest and the config are resolved
+	resolved bool
+	// blobs contains JSON entries of the archive by path. Only
+	// the entry referenced as the config of the manifest is decoded
+	// as image config, so other JSON files don't break analysis
+	blobs map[string][]byte
+}
+
+// errPending is returned when archive is not resolved yet
+var errPending = errors.New("archive is not resolved yet")
+
+// slot defines position of the layer in the image
+type slot struct {
+	path    string
+	history History
+}
+
+const manifest = "manifest.json"
+
+// Analyze provides reading of the image archive in a single pass
+// and returns report for the first image of the archive.
+// The reader is never seeked, so it can be stream (like stdin).
+// Archive can be compressed with xz or gzip
+func Analyze(r io.Reader) (*Report, error) {
+	return AnalyzeWithOptions(r, Options{})
+}
+
+// AnalyzeWithOptions provides reading of the image archive
+// in a single pass and returns report for the selected image
+func AnalyzeWithOptions(r io.Reader, opts Options) (*Report, error) {
+	var layers []*Layer
+	a, err := stream(r, opts, func(l *Layer) error {
+		layers = append(layers, l)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Report{
+		Manifest:  *a.manifest,
+		Manifests: a.manifests,
+		Image:     a.image,
+		History:   a.image.History,
+		Layers:    layers,
+	}, nil
+}
+
+// Stream provides reading of the image archive in a single pass.
+// fn is called for layers of the selected image in order from the
+// bottom as soon as the layer and all layers below it are read.
+// Layers which are read before the manifest and the image config
+// (or the layers below them) are held until then, so opts.Keep
+// allows to reduce the layer before it's held
+func Stream(r io.Reader, opts Options, fn func(*Layer) error) error {
+	_, err := stream(r, opts, fn)
+	return err
+}
+
+func stream(r io.Reader, opts Options, fn func(*Layer) error) (*archive, error) {
+	a := &archive{
+		layers: make(map[string]*Layer),
+		blobs:  make(map[string][]byte),
+	}
+	var slots []slot
+	uses := make(map[string]int)
+	next := 0
+	// emit provides passing of the read layers to fn in order.
+	// It does nothing until manifest and config are known
+	emit := func() error {
+		if slots == nil {
+			err := a.resolve(opts, false)
+			if err == errPending {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			s, err := alignSlots(*a.manifest, a.image.History)
+			if err != nil {
+				return err
+			}
+			slots = s
+			for _, s := range slots {
+				uses[s.path]++
+			}
+		}
+		for next < len(slots) {
+			s := slots[next]
+			layer, ok := a.layers[s.path]
+			if !ok {
+				return nil
+			}
+			// copy is made, because the same layer can be
+			// referenced by the several history records
+			l := *layer
+			l.Index = next
+			l.History = s.history
+			if src, ok := a.manifest.LayerSources[Digest(s.path)]; ok {
+				l.describe(src)
+			}
+			if err := fn(&l); err != nil {
+				return err
+			}
+			if uses[s.path]--; uses[s.path] == 0 {
+				delete(a.layers, s.path)
+			}
+			next++
+		}
+		return nil
+	}
+
+	// sizes contains sizes of layer entries, which are read
+	// at the worker if layers are decoded in parallel
+	sizes := make(map[string]uint64)
+	digests := make(map[string]string)
+	store := func(name string, layer *Layer) error {
+		layer.Path = name
+		layer.BlobSize = sizes[name]
+		layer.BlobDigest = digests[name]
+		delete(sizes, name)
+		delete(digests, name)
+		if opts.Keep != nil {
+			layer = opts.Keep(layer)
+		}
+		a.layers[name] = layer
+		return emit()
+	}
+	var dec *decoder
+	if opts.Jobs > 1 && opts.Content == nil {
+		dec = newDecoder(opts.Jobs, opts.layer(""), store)
+		defer dec.close()
+	}
+
+	content, err := unpack(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to unpack archive: %v", ErrCorruptArchive, err)
+	}
+	tr := tar.NewReader(content)
+	for entries := 0; ; entries++ {
+		if dec != nil {
+			if err := dec.poll(); err != nil {
+				return nil, err
+			}
+		}
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// input which fails at the first header isn't a tar
+			if entries == 0 {
+				return nil, fmt.Errorf("%w: %v", ErrUnsupportedFormat, err)
+			}
+			return nil, fmt.Errorf("%w: %v", ErrCorruptArchive, err)
+		}
+		if hdr.Typeflag == tar.TypeDir || hdr.Typeflag == tar.TypeXGlobalHeader {
+			continue
+		}
+		name := strings.TrimPrefix(hdr.Name, "./")
+
+		entry := io.Reader(tr)
+		var sum hash.Hash
+		if opts.VerifyDigests && (isBlob(name) || isLayer(name)) {
+			sum = sha256.New()
+			entry = io.TeeReader(tr, sum)
+		}
+		var layer *Layer
+		switch {
+		case isBlob(name):
+			// blobs are content-addressed, so content defines
+			// whether it's a layer or a manifest/config
+			br := bufio.NewReader(entry)
+			if magic, _ := br.Peek(1); len(magic) == 1 && magic[0] == '{' {
+				data, err := io.ReadAll(br)
+				if err != nil {
+					return nil, err
+				}
+				a.blobs[name] = data
+				break
+			}
+			sizes[name] = uint64(hdr.Size)
+			if dec != nil {
+				if err := dec.decode(name, br); err != nil {
+					return nil, err
+				}
+				break
+			}
+			layer, err = readLayer(br, opts.layer(name))
+			if err != nil {
+				return nil, fmt.Errorf("%w %s: %v", ErrCorruptLayer, name, err)
+			}
+
+		case isLayer(name):
+			sizes[name] = uint64(hdr.Size)
+			if dec != nil {
+				if err := dec.decode(name, entry); err != nil {
+					return nil, err
+				}
+				break
+			}
+			layer, err = readLayer(entry, opts.layer(name))
+			if err != nil {
+				return nil, fmt.Errorf("%w %s: %v", ErrCorruptLayer, name, err)
+			}
+
+		case name == manifest:
+			if err := json.NewDecoder(tr).Decode(&a.manifests); err != nil {
+				return nil, fmt.Errorf("%w: unable to decode %s: %v", ErrCorruptArchive, manifest, err)
+			}
+		case name == ociIndex:
+			data, err := io.ReadAll(tr)
+			if err != nil {
+				return nil, err
+			}
+			a.index = data
+		case strings.HasSuffix(name, ".json"):
+			data, err := io.ReadAll(tr)
+			if err != nil {
+				return nil, err
+			}
+			a.blobs[name] = data
+		}
+		// digest is of the whole entry, which is hashed while it's read
+		if _, ok := sizes[name]; ok && sum != nil {
+			if digests[name], err = blobDigest(entry, sum); err != nil {
+				return nil, fmt.Errorf("%w %s: %v", ErrCorruptLayer, name, err)
+			}
+		}
+		if layer != nil {
+			if err := store(name, layer); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err := emit(); err != nil {
+			return nil, err
+		}
+	}
+	if dec != nil {
+		if err := dec.wait(); err != nil {
+			return nil, err
+		}
+	}
+	if err := a.resolve(opts, true); err != nil {
+		return nil, err
+	}
+	if err := emit(); err != nil {
+		return nil, err
+	}
+	if next < len(slots) {
+		return nil, fmt.Errorf("%w: %s", ErrLayerNotFound, slots[next].path)
+	}
+	return a, nil
+}
+
+// resolve provides selection of the analyzed manifest and resolving
+// of its config. The manifest is resolved from the OCI index, and the
+// config is decoded from the JSON entry referenced by the manifest.
+// Until final, errPending is returned if something is not read yet
+func (a *archive) resolve(opts Options, final bool) error {
+	if a.resolved {
+		return nil
+	}
+	pending := func(err error) error {
+		if final {
+			return err
+		}
+		return errPending
+	}
+	if len(a.manifests) == 0 && a.index != nil {
+		manifests, err := resolveIndex(a.index, a.blobs)
+		if err != nil {
+			return pending(err)
+		}
+		a.manifests = manifests
+	}
+	if len(a.manifests) == 0 {
+		return pending(fmt.Errorf("%w (no %s or %s)", ErrNoManifest, manifest, ociIndex))
+	}
+	if a.manifest == nil {
+		selected := 0
+		if opts.Select != nil {
+			i, err := opts.Select(a.manifests)
+			if err != nil {
+				return err
+			}
+			selected = i
+		}
+		a.manifest = &a.manifests[selected]
+	}
+
+	if config, ok := a.blobs[a.manifest.Config]; ok {
+		if err := json.Unmarshal(config, &a.image); err != nil {
+			return fmt.Errorf("%w: unable to decode image config: %v", ErrCorruptArchive, err)
+		}
+	} else if !final || !opts.NoHistory {
+		return pending(fmt.Errorf("%w: %s", ErrNoConfig, a.manifest.Config))
+	}
+	if len(a.image.History) == 0 && !opts.NoHistory {
+		return ErrNoHistory
+	}
+	a.resolved = true
+	return nil
+}
+
+// alignSlots returns layers of the manifest matched with history records.
+// History contains records for empty layers too, so the layer cursor
+// moves only on non-empty records
+func alignSlots(m ManifestItem, history []History) ([]slot, error) {
+	slots := make([]slot, 0, len(m.Layers))
+	next := 0
+	for _, action := range history {
+		if action.EmptyLayer {
+			continue
+		}
+		if next >= len(m.Layers) {
+			return nil, fmt.Errorf("%w: more non-empty records than %d layers", ErrHistoryMismatch, len(m.Layers))
+		}
+		slots = append(slots, slot{path: m.Layers[next], history: action})
+		next++
+	}
+	for _, path := range m.Layers[next:] {
+		slots = append(slots, slot{path: path})
+	}
+	return slots, nil
+}
+
+// Command returns readable command of the history entry
+// without the shell wrapper and markers of the builder
+func Command(action History) string {
+	return CleanCommand(action.CreatedBy)
+}
@@ -0,0 +1,562 @@
+package dolay
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"runtime"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// testTime defines modification time of generated entries
+var testTime = time.Date(2020, 9, 13, 12, 0, 0, 0, time.UTC)
+
+// tarEntry defines entry of the generated tar archive
+type tarEntry struct {
+	*tar.Header
+	Body []byte
+}
+
+// regular returns entry of the regular file of size bytes
+func regular(name string, size int) tarEntry {
+	return tarEntry{
+		Header: &tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(size), ModTime: testTime},
+		Body:   bytes.Repeat([]byte{'x'}, size),
+	}
+}
+
+// directory returns entry of the directory
+func directory(name string) tarEntry {
+	return tarEntry{Header: &tar.Header{Name: name, Typeflag: tar.TypeDir, Mode: 0755, ModTime: testTime}}
+}
+
+// link returns entry of the symlink or the hardlink
+func link(typeflag byte, name, target string) tarEntry {
+	return tarEntry{Header: &tar.Header{Name: name, Typeflag: typeflag, Linkname: target, Mode: 0777, ModTime: testTime}}
+}
+
+// buildTar returns tar archive of the entries
+func buildTar(t testing.TB, entries ...tarEntry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range entries {
+		if err := tw.WriteHeader(e.Header); err != nil {
+			t.Fatalf("unable to write header %s: %v", e.Name, err)
+		}
+		if _, err := tw.Write(e.Body); err != nil {
+			t.Fatalf("unable to write %s: %v", e.Name, err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// gzipData returns data compressed with gzip
+func gzipData(t testing.TB, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// zstdData returns data compressed with zstd
+func zstdData(t testing.TB, data []byte) []byte {
+	t.Helper()
+	zw, err := zstd.NewWriter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zw.Close()
+	return zw.EncodeAll(data, nil)
+}
+
+// xzData returns data compressed with xz
+func xzData(t testing.TB, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw, err := xz.NewWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := zw.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// sha256Hex returns hex of sha256 of data
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// testImage defines image of the generated archive
+type testImage struct {
+	// Layers contains blobs of layers, which can be compressed
+	Layers [][]byte
+	// History contains records of the config. Record is
+	// generated for each layer if it's nil
+	History  []History
+	RepoTags []string
+	// Paths replaces paths of layers in the docker archive if it's set
+	Paths []string
+	// Config replaces the generated config if it's set
+	Config []byte
+	// Extra contains entries written after entries of the image
+	Extra []tarEntry
+}
+
+// history returns records of the image config
+func (img testImage) history() []History {
+	if img.History != nil {
+		return img.History
+	}
+	history := make([]History, 0, len(img.Layers))
+	for i := range img.Layers {
+		history = append(history, History{
+			Created:   testTime.Add(time.Duration(i) * time.Hour),
+			CreatedBy: fmt.Sprintf("/bin/sh -c #(nop) ADD file:%d in /", i),
+		})
+	}
+	return history
+}
+
+// config returns the image config
+func (img testImage) config(t testing.TB) []byte {
+	t.Helper()
+	if img.Config != nil {
+		return img.Config
+	}
+	image := Image{Created: testTime, Architecture: "amd64", OS: "linux", History: img.history()}
+	for _, l := range img.Layers {
+		image.RootFS.DiffIDs = append(image.RootFS.DiffIDs, "sha256:"+sha256Hex(l))
+	}
+	data, err := json.Marshal(image)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// docker returns archive of the image in the layout of "docker save".
+// Layers are "<hex>/layer.tar" entries, unless Paths are set, with the
+// config "<hex>.json"
+func (img testImage) docker(t testing.TB) []byte {
+	t.Helper()
+	config := img.config(t)
+	item := ManifestItem{Config: sha256Hex(config) + ".json", RepoTags: img.RepoTags}
+	var entries []tarEntry
+	for i, l := range img.Layers {
+		path := sha256Hex(l) + "/layer.tar"
+		if img.Paths != nil {
+			path = img.Paths[i]
+		}
+		item.Layers = append(item.Layers, path)
+		entries = append(entries, directory(sha256Hex(l)), file(path, l))
+	}
+	manifest, err := json.Marshal([]ManifestItem{item})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries = append(entries, file(item.Config, config), file("manifest.json", manifest))
+	return buildTar(t, append(entries, img.Extra...)...)
+}
+
+// oci returns archive of the image in the OCI image layout
+func (img testImage) oci(t testing.TB) []byte {
+	t.Helper()
+	config := img.config(t)
+	m := OCIManifest{Config: Descriptor{
+		MediaType: "application/vnd.oci.image.config.v1+json",
+		Digest:    "sha256:" + sha256Hex(config),
+		Size:      int64(len(config)),
+	}}
+	entries := []tarEntry{file(blobPath(m.Config.Digest), config)}
+	for _, l := range img.Layers {
+		mediaType := "application/vnd.oci.image.layer.v1.tar"
+		switch {
+		case bytes.HasPrefix(l, gzipMagic):
+			mediaType += "+gzip"
+		case bytes.HasPrefix(l, zstdMagic):
+			mediaType += "+zstd"
+		}
+		d := Descriptor{MediaType: mediaType, Digest: "sha256:" + sha256Hex(l), Size: int64(len(l))}
+		m.Layers = append(m.Layers, d)
+		entries = append(entries, file(blobPath(d.Digest), l))
+	}
+	manifest, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := Descriptor{MediaType: "application/vnd.oci.image.manifest.v1+json", Digest: "sha256:" + sha256Hex(manifest), Size: int64(len(manifest))}
+	if len(img.RepoTags) > 0 {
+		d.Annotations = map[string]string{"org.opencontainers.image.ref.name": img.RepoTags[0]}
+	}
+	index, err := json.Marshal(OCIIndex{Manifests: []Descriptor{d}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries = append(entries,
+		file(blobPath(d.Digest), manifest),
+		file("oci-layout", []byte(`{"imageLayoutVersion":"1.0.0"}`)),
+		file(ociIndex, index))
+	return buildTar(t, entries...)
+}
+
+// file returns entry of the regular file with the content
+func file(name string, body []byte) tarEntry {
+	return tarEntry{
+		Header: &tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(body)), ModTime: testTime},
+		Body:   body,
+	}
+}
+
+// testLayer returns layer of the entries created by the command
+// like it's read from the archive, so directories are counted and
+// whiteouts are held apart from files
+func testLayer(index int, command string, entries ...tarEntry) *Layer {
+	l := &Layer{Index: index, History: History{CreatedBy: command}}
+	for _, e := range entries {
+		switch {
+		case e.Typeflag == tar.TypeDir:
+			l.Dirs++
+		case IsWhiteout(e.Name):
+			l.Whiteouts = append(l.Whiteouts, e.Header)
+		default:
+			l.Files = append(l.Files, e.Header)
+		}
+	}
+	l.Count = len(l.Files)
+	l.Size = l.TotalSize()
+	return l
+}
+
+// names returns names of files in order
+func names(files Files) []string {
+	result := make([]string, 0, len(files))
+	for _, f := range files {
+		result = append(result, f.Name)
+	}
+	return result
+}
+
+func TestAnalyzeCompressedLayers(t *testing.T) {
+	base := buildTar(t, directory("bin/"), regular("bin/busybox", 900), link(tar.TypeSymlink, "bin/sh", "busybox"))
+	app := buildTar(t, regular("app/main", 300), regular("app/config.json", 20))
+	tests := []struct {
+		name        string
+		img         testImage
+		compression []string
+	}{
+		{"uncompressed", testImage{Layers: [][]byte{base, app}}, []string{"", ""}},
+		{"gzip as layer.tar", testImage{Layers: [][]byte{gzipData(t, base), app}}, []string{CompressionGzip, ""}},
+		{
+			"gzip suffixes",
+			testImage{Layers: [][]byte{gzipData(t, base), gzipData(t, app)}, Paths: []string{"a/layer.tar.gz", "b.tgz"}},
+			[]string{CompressionGzip, CompressionGzip},
+		},
+		{"zstd", testImage{Layers: [][]byte{zstdData(t, base), app}, Paths: []string{"a.tar.zst", "b/layer.tar"}}, []string{CompressionZstd, ""}},
+	}
+	want := [][]string{{"bin/busybox", "bin/sh"}, {"app/main", "app/config.json"}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := Analyze(bytes.NewReader(tt.img.docker(t)))
+			if err != nil {
+				t.Fatalf("Analyze() error = %v", err)
+			}
+			if len(report.Layers) != len(want) {
+				t.Fatalf("Analyze() layers = %d, want %d", len(report.Layers), len(want))
+			}
+			for i, layer := range report.Layers {
+				if got := names(layer.Files); !reflect.DeepEqual(got, want[i]) {
+					t.Errorf("layer %d files = %v, want %v", i, got, want[i])
+				}
+				if layer.Compression != tt.compression[i] {
+					t.Errorf("layer %d compression = %q, want %q", i, layer.Compression, tt.compression[i])
+				}
+				if layer.BlobSize != uint64(len(tt.img.Layers[i])) {
+					t.Errorf("layer %d blob size = %d, want %d", i, layer.BlobSize, len(tt.img.Layers[i]))
+				}
+			}
+			if l := report.Layers[0]; l.Size != 900 || l.Dirs != 1 {
+				t.Errorf("layer 0 size = %d, dirs = %d, want 900 and 1", l.Size, l.Dirs)
+			}
+		})
+	}
+}
+
+func TestAnalyzeStream(t *testing.T) {
+	layer := buildTar(t, regular("etc/passwd", 100), regular("bin/busybox", 900))
+	archive := testImage{Layers: [][]byte{layer}}.docker(t)
+	tests := []struct {
+		name string
+		r    func() io.Reader
+	}{
+		{"one byte reads", func() io.Reader { return iotest.OneByteReader(bytes.NewReader(archive)) }},
+		{"pipe", func() io.Reader {
+			pr, pw := io.Pipe()
+			go func() {
+				_, err := pw.Write(archive)
+				pw.CloseWithError(err)
+			}()
+			return pr
+		}},
+		{"gzip", func() io.Reader { return bytes.NewReader(gzipData(t, archive)) }},
+		{"xz", func() io.Reader { return bytes.NewReader(xzData(t, archive)) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := Analyze(tt.r())
+			if err != nil {
+				t.Fatalf("Analyze() error = %v", err)
+			}
+			if len(report.Layers) != 1 {
+				t.Fatalf("Analyze() layers = %d, want 1", len(report.Layers))
+			}
+			if got, want := names(report.Layers[0].Files), []string{"etc/passwd", "bin/busybox"}; !reflect.DeepEqual(got, want) {
+				t.Errorf("layer files = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestAnalyzeErrors(t *testing.T) {
+	layer := buildTar(t, regular("a", 1))
+	img := testImage{Layers: [][]byte{layer}}
+	noHistory := testImage{Layers: [][]byte{layer}, Config: []byte(`{"architecture":"amd64","history":[]}`)}
+	tagged := testImage{Layers: [][]byte{layer}, RepoTags: []string{"app:latest"}}
+	missing := buildTar(t, file("c.json", img.config(t)), file("manifest.json", []byte(`[{"Config":"c.json","Layers":["a/layer.tar"]}]`)))
+	tests := []struct {
+		name    string
+		archive []byte
+		opts    Options
+		want    error
+	}{
+		{"empty archive", buildTar(t), Options{}, ErrNoManifest},
+		{"no manifest", buildTar(t, file("a/layer.tar", layer), file("c.json", img.config(t))), Options{}, ErrNoManifest},
+		{"empty manifest", buildTar(t, file("manifest.json", []byte("[]"))), Options{}, ErrNoManifest},
+		{"corrupt manifest", buildTar(t, file("manifest.json", []byte("{"))), Options{}, ErrCorruptArchive},
+		{"no config", buildTar(t, file("a/layer.tar", layer), file("manifest.json", []byte(`[{"Config":"c.json","Layers":["a/layer.tar"]}]`))), Options{}, ErrNoConfig},
+		{"empty history", noHistory.docker(t), Options{}, ErrNoHistory},
+		{"not tar", []byte("plain text, which isn't a tar archive at all"), Options{}, ErrUnsupportedFormat},
+		{"corrupt config", testImage{Layers: [][]byte{layer}, Config: []byte("{")}.docker(t), Options{}, ErrCorruptArchive},
+		{"missing layer", missing, Options{}, ErrLayerNotFound},
+		{"truncated layer", testImage{Layers: [][]byte{layer[:300]}}.docker(t), Options{}, ErrCorruptLayer},
+		{"broken gzip layer", testImage{Layers: [][]byte{gzipData(t, layer)[:20]}}.docker(t), Options{}, ErrCorruptLayer},
+		{"unknown tag", tagged.docker(t), Options{Select: SelectByRepoTag("other")}, ErrImageNotFound},
+		{"index out of range", tagged.docker(t), Options{Select: SelectByIndex(1)}, ErrImageNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := AnalyzeWithOptions(bytes.NewReader(tt.archive), tt.opts)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("AnalyzeWithOptions() error = %v, want %v", err, tt.want)
+			}
+			if report != nil {
+				t.Errorf("AnalyzeWithOptions() report = %+v, want nil", report)
+			}
+		})
+	}
+}
+
+func TestAnalyzeHistoryAlignment(t *testing.T) {
+	layers := [][]byte{
+		buildTar(t, regular("bin/busybox", 900)),
+		buildTar(t, regular("app/main", 300)),
+		buildTar(t, regular("app/config.json", 20)),
+	}
+	history := []History{
+		{CreatedBy: "/bin/sh -c #(nop) ADD file:base in /"},
+		{CreatedBy: `/bin/sh -c #(nop)  ENV PATH=/bin`, EmptyLayer: true},
+		{CreatedBy: `/bin/sh -c #(nop)  LABEL a=b`, EmptyLayer: true},
+		{CreatedBy: "/bin/sh -c #(nop) COPY file:main in /app"},
+		{CreatedBy: `/bin/sh -c #(nop)  WORKDIR /app`, EmptyLayer: true},
+		{CreatedBy: "/bin/sh -c #(nop) COPY file:config in /app"},
+		{CreatedBy: `/bin/sh -c #(nop)  CMD ["/app/main"]`, EmptyLayer: true},
+	}
+	report, err := Analyze(bytes.NewReader(testImage{Layers: layers, History: history}.docker(t)))
+	if err != nil {
+		t.Fatalf("Analyze() error = %v", err)
+	}
+	if len(report.History) != len(history) {
+		t.Errorf("Analyze() history = %d records, want %d", len(report.History), len(history))
+	}
+	want := []struct {
+		command string
+		file    string
+	}{
+		{"ADD file:base in /", "bin/busybox"},
+		{"COPY file:main in /app", "app/main"},
+		{"COPY file:config in /app", "app/config.json"},
+	}
+	if len(report.Layers) != len(want) {
+		t.Fatalf("Analyze() layers = %d, want %d", len(report.Layers), len(want))
+	}
+	for i, w := range want {
+		l := report.Layers[i]
+		if l.Index != i || Command(l.History) != w.command || len(l.Files) != 1 || l.Files[0].Name != w.file {
+			t.Errorf("layer %d = index %d, command %q, files %v, want %q with %s", i, l.Index, Command(l.History), names(l.Files), w.command, w.file)
+		}
+	}
+
+	extra := append(append([]History(nil), history...), History{CreatedBy: "/bin/sh -c make"})
+	_, err = Analyze(bytes.NewReader(testImage{Layers: layers, History: extra}.docker(t)))
+	if !errors.Is(err, ErrHistoryMismatch) {
+		t.Errorf("Analyze() error = %v, want %v", err, ErrHistoryMismatch)
+	}
+	// layers without records are listed without commands
+	report, err = Analyze(bytes.NewReader(testImage{Layers: layers, History: history[:3]}.docker(t)))
+	if err != nil {
+		t.Fatalf("Analyze() error = %v", err)
+	}
+	if len(report.Layers) != 3 || report.Layers[1].History.CreatedBy != "" || report.Layers[2].Files[0].Name != "app/config.json" {
+		t.Errorf("Analyze() layers without records = %+v", report.Layers)
+	}
+}
+
+func TestAnalyzeBlobSize(t *testing.T) {
+	layer := buildTar(t, regular("var/log/big.log", 1<<20))
+	blob := gzipData(t, layer)
+	report, err := Analyze(bytes.NewReader(testImage{Layers: [][]byte{blob, layer}}.docker(t)))
+	if err != nil {
+		t.Fatalf("Analyze() error = %v", err)
+	}
+	tests := []struct {
+		compression string
+		size        uint64
+		blobSize    uint64
+	}{
+		{CompressionGzip, 1 << 20, uint64(len(blob))},
+		{"", 1 << 20, uint64(len(layer))},
+	}
+	for i, tt := range tests {
+		l := report.Layers[i]
+		if l.Compression != tt.compression || l.Size != tt.size || l.BlobSize != tt.blobSize {
+			t.Errorf("layer %d = %q, size %d, blob %d, want %q, %d and %d", i, l.Compression, l.Size, l.BlobSize, tt.compression, tt.size, tt.blobSize)
+		}
+	}
+	if report.Layers[0].BlobSize*10 > report.Layers[0].Size {
+		t.Errorf("blob of gzip layer = %d isn't smaller than content = %d", report.Layers[0].BlobSize, report.Layers[0].Size)
+	}
+}
+
+func TestAnalyzeNoHistory(t *testing.T) {
+	base, app := buildTar(t, regular("bin/busybox", 900)), buildTar(t, regular("app/main", 300))
+	manifest := []byte(`[{"Config":"c.json","Layers":["a/layer.tar","b/layer.tar"]}]`)
+	tests := []struct {
+		name    string
+		archive []byte
+		want    error
+	}{
+		{"no config", buildTar(t, file("a/layer.tar", base), file("b/layer.tar", app), file("manifest.json", manifest)), ErrNoConfig},
+		{"config without history", buildTar(t, file("a/layer.tar", base), file("b/layer.tar", app),
+			file("c.json", []byte(`{"architecture":"amd64"}`)), file("manifest.json", manifest)), ErrNoHistory},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Analyze(bytes.NewReader(tt.archive)); !errors.Is(err, tt.want) {
+				t.Errorf("Analyze() error = %v, want %v", err, tt.want)
+			}
+			report, err := AnalyzeWithOptions(bytes.NewReader(tt.archive), Options{NoHistory: true})
+			if err != nil {
+				t.Fatalf("AnalyzeWithOptions() error = %v", err)
+			}
+			if len(report.History) != 0 || len(report.Layers) != 2 {
+				t.Fatalf("AnalyzeWithOptions() = %d records and %d layers, want 0 and 2", len(report.History), len(report.Layers))
+			}
+			for i, want := range []string{"bin/busybox", "app/main"} {
+				l := report.Layers[i]
+				if l.Index != i || l.History.CreatedBy != "" || len(l.Files) != 1 || l.Files[0].Name != want {
+					t.Errorf("layer %d = index %d, command %q, files %v, want %s", i, l.Index, l.History.CreatedBy, names(l.Files), want)
+				}
+			}
+		})
+	}
+}
+
+func TestAnalyzeStrayJSON(t *testing.T) {
+	layers := [][]byte{buildTar(t, regular("bin/busybox", 900)), buildTar(t, regular("app/main", 300))}
+	stray := testImage{Layers: layers, History: []History{{CreatedBy: "/bin/sh -c stray"}}}
+	tests := []struct {
+		name  string
+		extra []tarEntry
+	}{
+		{"image config", []tarEntry{file("stray.json", stray.config(t))}},
+		{"package.json", []tarEntry{file("package.json", []byte(`{"name":"app","history":[]}`))}},
+		{"invalid json", []tarEntry{file("invalid.json", []byte("{"))}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := testImage{Layers: layers, Extra: tt.extra}
+			report, err := Analyze(bytes.NewReader(img.docker(t)))
+			if err != nil {
+				t.Fatalf("Analyze() error = %v", err)
+			}
+			if !reflect.DeepEqual(report.History, img.history()) {
+				t.Errorf("Analyze() history = %+v, want %+v of the config of the manifest", report.History, img.history())
+			}
+			if want := sha256Hex(img.config(t)) + ".json"; report.Manifest.Config != want {
+				t.Errorf("Analyze() config = %s, want %s", report.Manifest.Config, want)
+			}
+		})
+	}
+}
+
+func BenchmarkAnalyze(b *testing.B) {
+	entries := make([]tarEntry, 0, 100000)
+	for i := 0; i < 100000; i++ {
+		entries = append(entries, regular(fmt.Sprintf("usr/share/doc/pkg%d/file%d.txt", i/100, i), i%100))
+	}
+	archive := testImage{Layers: [][]byte{buildTar(b, entries...)}}.docker(b)
+	tests := []struct {
+		name string
+		opts Options
+	}{
+		{"all files", Options{}},
+		{"max files", Options{MaxFiles: 10}},
+	}
+	for _, tt := range tests {
+		b.Run(tt.name, func(b *testing.B) {
+			b.ReportAllocs()
+			var before, after runtime.MemStats
+			runtime.GC()
+			runtime.ReadMemStats(&before)
+			var report *Report
+			for i := 0; i < b.N; i++ {
+				var err error
+				if report, err = AnalyzeWithOptions(bytes.NewReader(archive), tt.opts); err != nil {
+					b.Fatal(err)
+				}
+			}
+			// memory held by the report of the last run
+			runtime.GC()
+			runtime.ReadMemStats(&after)
+			b.ReportMetric(float64(int64(after.HeapAlloc)-int64(before.HeapAlloc)), "held-B")
+			runtime.KeepAlive(report)
+		})
+	}
+}
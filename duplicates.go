@@ -0,0 +1,81 @@
+package dolay
+
+import (
+	"sort"
+	"strings"
+)
+
+// Duplicate defines file which was written in several layers
+// or was deleted by the upper layer after it was added
+type Duplicate struct {
+	Name   string `json:"name"`
+	Layers []int  `json:"layers"`
+	// Size is cumulative size of all copies of the file
+	Size uint64 `json:"size"`
+	// Wasted is size of copies which are not visible at the image
+	Wasted  uint64 `json:"wasted"`
+	Deleted bool   `json:"deleted,omitempty"`
+}
+
+// duplicate defines state of the path while walking layers
+type duplicate struct {
+	Duplicate
+	live uint64
+	gone bool
+}
+
+// FindDuplicates provides walking of layers from bottom to top
+// and returns files which waste space of the image. Files are
+// matched by path relative to the image root, and layers of files
+// are their indexes. Result is sorted by wasted size
+func FindDuplicates(layers []*Layer) []Duplicate {
+	paths := make(map[string]*duplicate)
+	remove := func(d *duplicate) {
+		if d.gone {
+			return
+		}
+		d.Wasted += d.live
+		d.live = 0
+		d.gone = true
+		d.Deleted = true
+	}
+	for _, layer := range layers {
+		for _, w := range layer.Whiteouts {
+			target, opaque := WhiteoutTarget(cleanPath(w.Name))
+			for p, d := range paths {
+				if (!opaque && p == target) || strings.HasPrefix(p, target+"/") {
+					remove(d)
+				}
+			}
+		}
+		for _, f := range layer.Files {
+			name := cleanPath(f.Name)
+			d, ok := paths[name]
+			if !ok {
+				d = &duplicate{Duplicate: Duplicate{Name: name}}
+				paths[name] = d
+			}
+			if !d.gone {
+				d.Wasted += d.live
+			}
+			d.Layers = append(d.Layers, layer.Index)
+			d.Size += uint64(f.Size)
+			d.live = uint64(f.Size)
+			d.gone = false
+		}
+	}
+
+	result := []Duplicate{}
+	for _, d := range paths {
+		if len(d.Layers) > 1 || d.Deleted {
+			result = append(result, d.Duplicate)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Wasted != result[j].Wasted {
+			return result[i].Wasted > result[j].Wasted
+		}
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
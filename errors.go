@@ -0,0 +1,30 @@
+package dolay
+
+import "errors"
+
+// Errors of the archive analysis. Returned errors wrap them,
+// so they can be checked with errors.Is
+var (
+	// ErrUnsupportedFormat is returned when the input is not a tar archive
+	ErrUnsupportedFormat = errors.New("unsupported archive format")
+	// ErrCorruptArchive is returned when the archive or its
+	// manifests and config are malformed
+	ErrCorruptArchive = errors.New("corrupt archive")
+	// ErrCorruptLayer is returned when the layer is not a valid tar
+	// or its compressed stream is broken
+	ErrCorruptLayer = errors.New("corrupt layer")
+	// ErrNoManifest is returned when the archive has no manifest,
+	// so it's not an image archive
+	ErrNoManifest = errors.New("manifest is not found in archive")
+	// ErrNoConfig is returned when config of the image is missing
+	ErrNoConfig = errors.New("image config is not found in archive")
+	// ErrNoHistory is returned when config of the image has no history
+	ErrNoHistory = errors.New("no history found in image config")
+	// ErrLayerNotFound is returned when the layer of the manifest is missing
+	ErrLayerNotFound = errors.New("layer is not found in archive")
+	// ErrHistoryMismatch is returned when history records
+	// can't be matched with layers of the manifest
+	ErrHistoryMismatch = errors.New("history doesn't match layers of the manifest")
+	// ErrImageNotFound is returned when the selected image is not in the archive
+	ErrImageNotFound = errors.New("image is not found in archive")
+)
@@ -0,0 +1,61 @@
+package dolay_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"log"
+
+	"github.com/saromanov/dolay"
+)
+
+// exampleArchive returns archive of the image with the single layer
+// in the layout of "docker save"
+func exampleArchive() *bytes.Buffer {
+	var layer bytes.Buffer
+	lw := tar.NewWriter(&layer)
+	lw.WriteHeader(&tar.Header{Name: "bin/busybox", Typeflag: tar.TypeReg, Mode: 0755, Size: 900})
+	lw.Write(make([]byte, 900))
+	lw.Close()
+	entries := []struct {
+		name string
+		body []byte
+	}{
+		{"a/layer.tar", layer.Bytes()},
+		{"config.json", []byte(`{"history":[{"created_by":"/bin/sh -c #(nop) ADD file:busybox in /"},` +
+			`{"created_by":"/bin/sh -c #(nop)  CMD [\"sh\"]","empty_layer":true}]}`)},
+		{"manifest.json", []byte(`[{"Config":"config.json","RepoTags":["busybox:latest"],"Layers":["a/layer.tar"]}]`)},
+	}
+	var archive bytes.Buffer
+	tw := tar.NewWriter(&archive)
+	for _, e := range entries {
+		tw.WriteHeader(&tar.Header{Name: e.name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(e.body))})
+		tw.Write(e.body)
+	}
+	tw.Close()
+	return &archive
+}
+
+func ExampleAnalyze() {
+	// the archive is the output of "docker save busybox"
+	report, err := dolay.Analyze(exampleArchive())
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(report.Manifest.RepoTags)
+	for _, h := range report.History {
+		fmt.Println(dolay.Command(h))
+	}
+	for _, layer := range report.Layers {
+		fmt.Printf("layer %d: %d bytes, %s\n", layer.Index, layer.Size, dolay.Command(layer.History))
+		for _, f := range layer.TopFiles(10) {
+			fmt.Println(" ", f.Name, f.Size)
+		}
+	}
+	// Output:
+	// [busybox:latest]
+	// ADD file:busybox in /
+	// CMD ["sh"]
+	// layer 0: 900 bytes, ADD file:busybox in /
+	//   bin/busybox 900
+}
@@ -0,0 +1,55 @@
+package dolay
+
+import (
+	"path"
+	"sort"
+	"strings"
+)
+
+// NoExtension defines label of files without extension
+const NoExtension = "(none)"
+
+// ExtensionStat defines totals of files with the same extension
+type ExtensionStat struct {
+	Ext   string `json:"ext"`
+	Count int    `json:"count"`
+	Size  uint64 `json:"size"`
+}
+
+// Extension returns extension of the file name (like ".so"),
+// or NoExtension. Leading dot of hidden files is not an extension
+func Extension(name string) string {
+	base := strings.TrimLeft(path.Base(name), ".")
+	ext := path.Ext(base)
+	if ext == "" {
+		return NoExtension
+	}
+	return ext
+}
+
+// ByExtension returns files grouped by extension,
+// sorted by total size from the largest
+func ByExtension(files Files) []ExtensionStat {
+	groups := make(map[string]*ExtensionStat)
+	for _, f := range files {
+		ext := Extension(f.Name)
+		g, ok := groups[ext]
+		if !ok {
+			g = &ExtensionStat{Ext: ext}
+			groups[ext] = g
+		}
+		g.Count++
+		g.Size += uint64(f.Size)
+	}
+	stats := make([]ExtensionStat, 0, len(groups))
+	for _, g := range groups {
+		stats = append(stats, *g)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Size != stats[j].Size {
+			return stats[i].Size > stats[j].Size
+		}
+		return stats[i].Ext < stats[j].Ext
+	})
+	return stats
+}
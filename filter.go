@@ -0,0 +1,102 @@
+package dolay
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// PathFilter defines filter of files by patterns of the path.
+// File passes the filter if it matches any of include patterns
+// (or there are no include patterns) and none of exclude patterns
+type PathFilter struct {
+	include []*regexp.Regexp
+	exclude []*regexp.Regexp
+}
+
+// NewPathFilter returns filter by glob patterns,
+// or by regular expressions if regex is true
+func NewPathFilter(include, exclude []string, regex bool) (*PathFilter, error) {
+	var err error
+	f := &PathFilter{}
+	if f.include, err = compilePatterns(include, regex); err != nil {
+		return nil, err
+	}
+	if f.exclude, err = compilePatterns(exclude, regex); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Match returns true if path passes the filter
+func (f *PathFilter) Match(name string) bool {
+	if f == nil {
+		return true
+	}
+	if len(f.include) > 0 && !matchAny(f.include, name) {
+		return false
+	}
+	return !matchAny(f.exclude, name)
+}
+
+func matchAny(patterns []*regexp.Regexp, name string) bool {
+	for _, p := range patterns {
+		if p.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+func compilePatterns(patterns []string, regex bool) ([]*regexp.Regexp, error) {
+	result := make([]*regexp.Regexp, 0, len(patterns))
+	for _, p := range patterns {
+		expr := p
+		if !regex {
+			expr = globToRegexp(p)
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %v", p, err)
+		}
+		result = append(result, re)
+	}
+	return result, nil
+}
+
+// globToRegexp returns regular expression for the glob pattern
+// matched against the full path. "*" matches any sequence
+// of characters including "/", "**/" matches any number of
+// leading directories, "?" matches single character
+func globToRegexp(glob string) string {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			b.WriteString("(.*/)?")
+			i += 2
+		case c == '*':
+			b.WriteString(".*")
+		case c == '?':
+			b.WriteString(".")
+		case c == '[':
+			end := strings.IndexByte(glob[i:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + class + "]")
+			i += end
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return b.String()
+}
@@ -0,0 +1,57 @@
+package dolay
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPathFilter(t *testing.T) {
+	paths := []string{
+		"usr/lib/libssl.so",
+		"usr/lib/libssl.so.3",
+		"usr/lib/libc.a",
+		"app/node_modules/left-pad/index.js",
+		"node_modules/react/index.js",
+		"etc/passwd",
+	}
+	tests := []struct {
+		name    string
+		include []string
+		exclude []string
+		regex   bool
+		want    []string
+	}{
+		{"no patterns", nil, nil, false, paths},
+		{"include *.so", []string{"*.so"}, nil, false, []string{"usr/lib/libssl.so"}},
+		{"several includes", []string{"*.so", "*.a"}, nil, false, []string{"usr/lib/libssl.so", "usr/lib/libc.a"}},
+		{"any directories", []string{"**/node_modules/**"}, nil, false, []string{"app/node_modules/left-pad/index.js", "node_modules/react/index.js"}},
+		{"exclude", nil, []string{"usr/*", "etc/passwd"}, false, []string{"app/node_modules/left-pad/index.js", "node_modules/react/index.js"}},
+		{"include and exclude", []string{"usr/lib/*"}, []string{"*.a"}, false, []string{"usr/lib/libssl.so", "usr/lib/libssl.so.3"}},
+		{"class", []string{"usr/lib/lib[cs]*"}, []string{"*.so*"}, false, []string{"usr/lib/libc.a"}},
+		{"regex", []string{`\.so(\.\d+)*$`}, nil, true, []string{"usr/lib/libssl.so", "usr/lib/libssl.so.3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := NewPathFilter(tt.include, tt.exclude, tt.regex)
+			if err != nil {
+				t.Fatalf("NewPathFilter() error = %v", err)
+			}
+			var got []string
+			for _, p := range paths {
+				if f.Match(p) {
+					got = append(got, p)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("matched = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if _, err := NewPathFilter([]string{"(lib"}, nil, true); err == nil {
+		t.Errorf("NewPathFilter() error = nil for invalid expression")
+	}
+	var none *PathFilter
+	if !none.Match("etc/passwd") {
+		t.Errorf("nil filter doesn't match")
+	}
+}
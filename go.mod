@@ -1,9 +1,23 @@
 module github.com/saromanov/dolay
 
+go 1.27.1
+
 require (
 	github.com/dustin/go-humanize v0.0.0-20180713052910-9f541cc9db5d
 	github.com/fatih/color v1.7.0
+	github.com/gdamore/tcell/v2 v2.4.0
+	github.com/klauspost/compress v1.15.9
+	github.com/mattn/go-isatty v0.0.4
+	github.com/ulikunitz/xz v0.5.10
+	golang.org/x/term v0.0.0-20201210144234-2321bbc49cbf
+)
+
+require (
+	github.com/gdamore/encoding v1.0.0 // indirect
+	github.com/lucasb-eyer/go-colorful v1.0.3 // indirect
 	github.com/mattn/go-colorable v0.0.9 // indirect
-	github.com/mattn/go-isatty v0.0.4 // indirect
-	golang.org/x/sys v0.0.0-20180909124046-d0be0721c37e // indirect
+	github.com/mattn/go-runewidth v0.0.10 // indirect
+	github.com/rivo/uniseg v0.1.0 // indirect
+	golang.org/x/sys v0.0.0-20201119102817-f84b799fce68 // indirect
+	golang.org/x/text v0.3.0 // indirect
 )
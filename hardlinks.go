@@ -0,0 +1,45 @@
+package dolay
+
+import (
+	"archive/tar"
+	"path"
+	"strings"
+)
+
+// HardlinkStat defines hardlinks of the layer. Tar stores content
+// of the file once, and links have no size, so sizes of files are
+// already deduplicated. Size is what is counted additionally by tools
+// summing logical size of every link
+type HardlinkStat struct {
+	Links int    `json:"links"`
+	Size  uint64 `json:"size"`
+}
+
+// Hardlinks returns hardlinks of the layer files with total size
+// of their targets. Targets of hardlinks are in the same layer
+func Hardlinks(files Files) HardlinkStat {
+	var stat HardlinkStat
+	var sizes map[string]int64
+	for _, f := range files {
+		if f.Typeflag != tar.TypeLink {
+			continue
+		}
+		if sizes == nil {
+			sizes = make(map[string]int64, len(files))
+			for _, t := range files {
+				if t.Typeflag != tar.TypeLink {
+					sizes[linkPath(t.Name)] = t.Size
+				}
+			}
+		}
+		stat.Links++
+		stat.Size += uint64(sizes[linkPath(f.Linkname)])
+	}
+	return stat
+}
+
+// linkPath returns path of the entry without leading "./" and "/",
+// since names and link targets are written in either form
+func linkPath(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
@@ -0,0 +1,60 @@
+package dolay
+
+import (
+	"archive/tar"
+	"bytes"
+	"testing"
+)
+
+func TestHardlinks(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []tarEntry
+		want    HardlinkStat
+	}{
+		{"no links", []tarEntry{regular("bin/sh", 100)}, HardlinkStat{}},
+		{"two links to one file", []tarEntry{
+			regular("usr/bin/python3.11", 4000),
+			link(tar.TypeLink, "usr/bin/python3", "usr/bin/python3.11"),
+			link(tar.TypeLink, "usr/bin/python", "usr/bin/python3.11"),
+		}, HardlinkStat{Links: 2, Size: 8000}},
+		{"link before the target", []tarEntry{
+			link(tar.TypeLink, "bin/ash", "bin/busybox"),
+			regular("bin/busybox", 900),
+		}, HardlinkStat{Links: 1, Size: 900}},
+		{"leading slash and dot", []tarEntry{
+			regular("./bin/busybox", 900),
+			link(tar.TypeLink, "./bin/sh", "/bin/busybox"),
+		}, HardlinkStat{Links: 1, Size: 900}},
+		{"symlinks", []tarEntry{
+			regular("bin/busybox", 900),
+			link(tar.TypeSymlink, "bin/sh", "busybox"),
+		}, HardlinkStat{}},
+		{"target of lower layer", []tarEntry{link(tar.TypeLink, "bin/sh", "bin/busybox")}, HardlinkStat{Links: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Hardlinks(testLayer(0, "", tt.entries...).Files); got != tt.want {
+				t.Errorf("Hardlinks() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHardlinksSize(t *testing.T) {
+	layer, err := ReadLayer(bytes.NewReader(buildTar(t,
+		regular("usr/bin/python3.11", 4000),
+		link(tar.TypeLink, "usr/bin/python3", "usr/bin/python3.11"),
+		link(tar.TypeLink, "usr/bin/python", "usr/bin/python3.11"),
+	)))
+	if err != nil {
+		t.Fatalf("ReadLayer() error = %v", err)
+	}
+	// content of hardlinked file is stored once
+	if layer.Size != 4000 || len(layer.Files) != 3 {
+		t.Errorf("ReadLayer() = %d files of %d bytes, want 3 files of 4000 bytes", len(layer.Files), layer.Size)
+	}
+	if stat := Hardlinks(layer.Files); layer.Size+stat.Size != 12000 {
+		t.Errorf("size counting every link = %d, want 12000", layer.Size+stat.Size)
+	}
+}
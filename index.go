@@ -0,0 +1,149 @@
+package dolay
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// entry defines position of the layer content in the archive
+type entry struct {
+	offset int64
+	size   int64
+}
+
+// ArchiveIndex defines offsets of layers in the seekable archive,
+// so layers can be read on demand without walking the whole archive
+type ArchiveIndex struct {
+	// Manifest is the analyzed image
+	Manifest ManifestItem
+	// Manifests contains all images of the archive
+	Manifests []ManifestItem
+	Image     Image
+	r         io.ReaderAt
+	slots     []slot
+	entries   map[string]entry
+}
+
+// OpenIndex provides indexing of the uncompressed archive of size bytes.
+// Tar reader seeks over content of layers, so only headers, manifests
+// and configs are read. The archive is walked once, and layers are read
+// by ArchiveIndex.ReadLayer later
+func OpenIndex(r io.ReaderAt, size int64, opts Options) (*ArchiveIndex, error) {
+	magic := make([]byte, len(xzMagic))
+	if n, _ := r.ReadAt(magic, 0); n == len(magic) && (bytes.Equal(magic, xzMagic) || bytes.HasPrefix(magic, gzipMagic)) {
+		return nil, fmt.Errorf("%w: compressed archive can't be indexed", ErrUnsupportedFormat)
+	}
+	a := &archive{
+		blobs: make(map[string][]byte),
+	}
+	entries := make(map[string]entry)
+	// tar reader doesn't read ahead, so the position of the section
+	// after the header is the offset of the entry content
+	sr := io.NewSectionReader(r, 0, size)
+	tr := tar.NewReader(sr)
+	for count := 0; ; count++ {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if count == 0 {
+				return nil, fmt.Errorf("%w: %v", ErrUnsupportedFormat, err)
+			}
+			return nil, fmt.Errorf("%w: %v", ErrCorruptArchive, err)
+		}
+		if hdr.Typeflag == tar.TypeDir || hdr.Typeflag == tar.TypeXGlobalHeader {
+			continue
+		}
+		name := strings.TrimPrefix(hdr.Name, "./")
+		offset, err := sr.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, err
+		}
+		e := entry{offset: offset, size: hdr.Size}
+
+		switch {
+		case isBlob(name):
+			magic := make([]byte, 1)
+			if n, _ := r.ReadAt(magic, e.offset); n == 1 && magic[0] == '{' && e.size > 0 {
+				data, err := io.ReadAll(tr)
+				if err != nil {
+					return nil, err
+				}
+				a.blobs[name] = data
+				break
+			}
+			entries[name] = e
+		case isLayer(name):
+			entries[name] = e
+		case name == manifest:
+			if err := json.NewDecoder(tr).Decode(&a.manifests); err != nil {
+				return nil, fmt.Errorf("%w: unable to decode %s: %v", ErrCorruptArchive, manifest, err)
+			}
+		case name == ociIndex:
+			data, err := io.ReadAll(tr)
+			if err != nil {
+				return nil, err
+			}
+			a.index = data
+		case strings.HasSuffix(name, ".json"):
+			data, err := io.ReadAll(tr)
+			if err != nil {
+				return nil, err
+			}
+			a.blobs[name] = data
+		}
+	}
+	if err := a.resolve(opts, true); err != nil {
+		return nil, err
+	}
+	slots, err := alignSlots(*a.manifest, a.image.History)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range slots {
+		if _, ok := entries[s.path]; !ok {
+			return nil, fmt.Errorf("%w: %s", ErrLayerNotFound, s.path)
+		}
+	}
+	return &ArchiveIndex{
+		Manifest:  *a.manifest,
+		Manifests: a.manifests,
+		Image:     a.image,
+		r:         r,
+		slots:     slots,
+		entries:   entries,
+	}, nil
+}
+
+// Len returns number of layers of the image
+func (x *ArchiveIndex) Len() int {
+	return len(x.slots)
+}
+
+// ReadLayer returns layer of the image by index from the bottom.
+// Only content of the layer is read, and content of files of the
+// uncompressed layer is skipped. It's safe to read layers concurrently
+func (x *ArchiveIndex) ReadLayer(index int) (*Layer, error) {
+	if index < 0 || index >= len(x.slots) {
+		return nil, fmt.Errorf("layer %d is out of range, image contains %d layers", index, len(x.slots))
+	}
+	s := x.slots[index]
+	e := x.entries[s.path]
+	layer, err := readLayer(io.NewSectionReader(x.r, e.offset, e.size), layerOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("%w %s: %v", ErrCorruptLayer, s.path, err)
+	}
+	layer.Index = index
+	layer.Path = s.path
+	layer.History = s.history
+	layer.BlobSize = uint64(e.size)
+	if src, ok := x.Manifest.LayerSources[Digest(s.path)]; ok {
+		layer.describe(src)
+	}
+	return layer, nil
+}
@@ -0,0 +1,132 @@
+package dolay
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"sync/atomic"
+	"testing"
+)
+
+// countingReaderAt provides counting of bytes read at offsets
+type countingReaderAt struct {
+	r *bytes.Reader
+	n int64
+}
+
+func (c *countingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	n, err := c.r.ReadAt(p, off)
+	atomic.AddInt64(&c.n, int64(n))
+	return n, err
+}
+
+func TestArchiveIndexReadLayer(t *testing.T) {
+	layers := [][]byte{
+		buildTar(t, regular("bin/busybox", 100000)),
+		gzipData(t, buildTar(t, regular("etc/passwd", 100), regular("etc/group", 50))),
+		buildTar(t, regular("app/big.bin", 200000)),
+	}
+	img := testImage{Layers: layers}
+	tests := []struct {
+		name    string
+		archive []byte
+	}{
+		{"docker", img.docker(t)},
+		{"oci", img.oci(t)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ra := &countingReaderAt{r: bytes.NewReader(tt.archive)}
+			index, err := OpenIndex(ra, int64(len(tt.archive)), Options{})
+			if err != nil {
+				t.Fatalf("OpenIndex() error = %v", err)
+			}
+			// content of layers is skipped by seeking
+			if ra.n > int64(len(tt.archive))/4 {
+				t.Errorf("OpenIndex() read %d of %d bytes", ra.n, len(tt.archive))
+			}
+			if index.Len() != 3 {
+				t.Fatalf("Len() = %d, want 3", index.Len())
+			}
+			want := [][]string{{"bin/busybox"}, {"etc/passwd", "etc/group"}, {"app/big.bin"}}
+			for _, i := range []int{2, 0, 1, 2} {
+				layer, err := index.ReadLayer(i)
+				if err != nil {
+					t.Fatalf("ReadLayer(%d) error = %v", i, err)
+				}
+				if got := names(layer.Files); !reflect.DeepEqual(got, want[i]) {
+					t.Errorf("ReadLayer(%d) files = %v, want %v", i, got, want[i])
+				}
+				if layer.Index != i || layer.History.CreatedBy != img.history()[i].CreatedBy {
+					t.Errorf("ReadLayer(%d) index = %d, history = %q", i, layer.Index, layer.History.CreatedBy)
+				}
+				if layer.BlobSize != uint64(len(layers[i])) {
+					t.Errorf("ReadLayer(%d) blob size = %d, want %d", i, layer.BlobSize, len(layers[i]))
+				}
+			}
+			compressed, err := index.ReadLayer(1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if compressed.Compression != CompressionGzip || compressed.Size != 150 {
+				t.Errorf("ReadLayer(1) compression = %q, size = %d", compressed.Compression, compressed.Size)
+			}
+		})
+	}
+}
+
+func TestArchiveIndexSkipsContent(t *testing.T) {
+	archive := testImage{Layers: [][]byte{buildTar(t, regular("big.bin", 1<<20), regular("small", 10))}}.docker(t)
+	ra := &countingReaderAt{r: bytes.NewReader(archive)}
+	index, err := OpenIndex(ra, int64(len(archive)), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ra.n = 0
+	layer, err := index.ReadLayer(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if layer.Size != 1<<20+10 || len(layer.Files) != 2 {
+		t.Errorf("ReadLayer(0) size = %d, files = %v", layer.Size, names(layer.Files))
+	}
+	if ra.n > 1<<16 {
+		t.Errorf("ReadLayer(0) read %d bytes of the uncompressed layer", ra.n)
+	}
+}
+
+func TestOpenIndexErrors(t *testing.T) {
+	layer := buildTar(t, regular("a", 1))
+	archive := testImage{Layers: [][]byte{layer}}.docker(t)
+	missing := buildTar(t,
+		file("c.json", testImage{Layers: [][]byte{layer}}.config(t)),
+		file("manifest.json", []byte(`[{"Config":"c.json","Layers":["x/layer.tar"]}]`)))
+	tests := []struct {
+		name    string
+		archive []byte
+		want    error
+	}{
+		{"gzip archive", gzipData(t, archive), ErrUnsupportedFormat},
+		{"not tar", []byte("plain text, which isn't a tar archive at all"), ErrUnsupportedFormat},
+		{"no manifest", buildTar(t, file("a/layer.tar", layer)), ErrNoManifest},
+		{"missing layer", missing, ErrLayerNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := OpenIndex(bytes.NewReader(tt.archive), int64(len(tt.archive)), Options{})
+			if !errors.Is(err, tt.want) {
+				t.Errorf("OpenIndex() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	index, err := OpenIndex(bytes.NewReader(archive), int64(len(archive)), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, i := range []int{-1, 1} {
+		if _, err := index.ReadLayer(i); err == nil {
+			t.Errorf("ReadLayer(%d) error = nil, want out of range", i)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package dolay
+
+import (
+	"sort"
+	"strings"
+)
+
+// UnknownInstruction defines label of records without known instruction
+const UnknownInstruction = "(unknown)"
+
+// instructions contains instructions of the Dockerfile
+var instructions = map[string]bool{
+	"ADD":         true,
+	"ARG":         true,
+	"CMD":         true,
+	"COPY":        true,
+	"ENTRYPOINT":  true,
+	"ENV":         true,
+	"EXPOSE":      true,
+	"HEALTHCHECK": true,
+	"LABEL":       true,
+	"MAINTAINER":  true,
+	"ONBUILD":     true,
+	"RUN":         true,
+	"SHELL":       true,
+	"STOPSIGNAL":  true,
+	"USER":        true,
+	"VOLUME":      true,
+	"WORKDIR":     true,
+}
+
+// InstructionStat defines totals of layers made by the same instruction
+type InstructionStat struct {
+	Instruction string `json:"instruction"`
+	Layers      int    `json:"layers"`
+	Size        uint64 `json:"size"`
+}
+
+// CommandStat defines totals of layers made by the same command
+type CommandStat struct {
+	Command string `json:"command"`
+	Layers  int    `json:"layers"`
+	Size    uint64 `json:"size"`
+}
+
+// Instruction returns instruction of the Dockerfile which produced
+// the created_by record. The classic builder marks instructions other
+// than RUN with "#(nop)", and runs RUN through the shell wrapper.
+// BuildKit writes the instruction at the start of the record
+func Instruction(createdBy string) string {
+	cmd := strings.TrimSpace(createdBy)
+	if i := strings.Index(cmd, nopMarker); i >= 0 {
+		return instructionWord(strings.TrimSpace(cmd[i+len(nopMarker):]))
+	}
+	if word := instructionWord(cmd); word != UnknownInstruction {
+		return word
+	}
+	for _, p := range ShellPrefixes {
+		if strings.Contains(cmd, p) {
+			return "RUN"
+		}
+	}
+	return UnknownInstruction
+}
+
+// instructionWord returns the instruction at the start of s
+func instructionWord(s string) string {
+	word := strings.SplitN(s, " ", 2)[0]
+	if instructions[word] {
+		return word
+	}
+	return UnknownInstruction
+}
+
+// ByInstruction returns layers grouped by the instruction,
+// sorted by total size from the largest
+func ByInstruction(layers []*Layer) []InstructionStat {
+	groups := make(map[string]*InstructionStat)
+	for _, l := range layers {
+		name := Instruction(l.History.CreatedBy)
+		g, ok := groups[name]
+		if !ok {
+			g = &InstructionStat{Instruction: name}
+			groups[name] = g
+		}
+		g.Layers++
+		g.Size += l.Size
+	}
+	stats := make([]InstructionStat, 0, len(groups))
+	for _, g := range groups {
+		stats = append(stats, *g)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Size != stats[j].Size {
+			return stats[i].Size > stats[j].Size
+		}
+		return stats[i].Instruction < stats[j].Instruction
+	})
+	return stats
+}
+
+// ByCommand returns layers grouped by the command, sorted by total size
+// from the largest. Commands are compared after shell wrappers and
+// builder markers are stripped, so the same RUN of different stages
+// or images is counted together
+func ByCommand(layers []*Layer) []CommandStat {
+	groups := make(map[string]*CommandStat)
+	for _, l := range layers {
+		cmd := CleanCommand(l.History.CreatedBy)
+		g, ok := groups[cmd]
+		if !ok {
+			g = &CommandStat{Command: cmd}
+			groups[cmd] = g
+		}
+		g.Layers++
+		g.Size += l.Size
+	}
+	stats := make([]CommandStat, 0, len(groups))
+	for _, g := range groups {
+		stats = append(stats, *g)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Size != stats[j].Size {
+			return stats[i].Size > stats[j].Size
+		}
+		return stats[i].Command < stats[j].Command
+	})
+	return stats
+}
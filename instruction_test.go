@@ -0,0 +1,84 @@
+package dolay
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestInstruction(t *testing.T) {
+	tests := []struct {
+		name      string
+		createdBy string
+		want      string
+	}{
+		{"classic run", "/bin/sh -c apk add --no-cache curl", "RUN"},
+		{"classic add", "/bin/sh -c #(nop) ADD file:9a4f77df in / ", "ADD"},
+		{"classic copy", "/bin/sh -c #(nop) COPY dir:5b1a in /app ", "COPY"},
+		{"classic cmd", `/bin/sh -c #(nop)  CMD ["/bin/sh"]`, "CMD"},
+		{"classic env", "/bin/sh -c #(nop)  ENV PATH=/usr/local/bin", "ENV"},
+		{"classic workdir", "/bin/sh -c #(nop) WORKDIR /app", "WORKDIR"},
+		{"classic unknown", "/bin/sh -c #(nop) FROM scratch", UnknownInstruction},
+		{"buildkit run", "RUN /bin/sh -c apt-get update # buildkit", "RUN"},
+		{"buildkit copy", "COPY . /app # buildkit", "COPY"},
+		{"buildkit entrypoint", `ENTRYPOINT ["docker-entrypoint.sh"]`, "ENTRYPOINT"},
+		{"buildkit user", "USER 1000", "USER"},
+		{"buildkit label", "LABEL org.opencontainers.image.title=app", "LABEL"},
+		{"shell without marker", "/bin/bash -o pipefail -c make", "RUN"},
+		{"lowercase", "run make", UnknownInstruction},
+		{"empty", "", UnknownInstruction},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Instruction(tt.createdBy); got != tt.want {
+				t.Errorf("Instruction(%q) = %q, want %q", tt.createdBy, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestByInstruction(t *testing.T) {
+	layers := []*Layer{
+		testLayer(0, "/bin/sh -c #(nop) ADD file:abc in /", regular("bin/sh", 500)),
+		testLayer(1, "/bin/sh -c apk add curl", regular("usr/bin/curl", 300)),
+		testLayer(2, "/bin/sh -c #(nop) COPY file:def in /app", regular("app/main", 200)),
+		testLayer(3, "RUN /bin/sh -c make # buildkit", regular("app/out", 400)),
+		testLayer(4, "", regular("etc/motd", 10)),
+	}
+	want := []InstructionStat{
+		{Instruction: "RUN", Layers: 2, Size: 700},
+		{Instruction: "ADD", Layers: 1, Size: 500},
+		{Instruction: "COPY", Layers: 1, Size: 200},
+		{Instruction: UnknownInstruction, Layers: 1, Size: 10},
+	}
+	if got := ByInstruction(layers); !reflect.DeepEqual(got, want) {
+		t.Errorf("ByInstruction() = %+v, want %+v", got, want)
+	}
+	if got := ByInstruction(nil); got == nil || len(got) != 0 {
+		t.Errorf("ByInstruction(nil) = %#v, want empty slice", got)
+	}
+}
+
+func TestByCommand(t *testing.T) {
+	layers := []*Layer{
+		testLayer(0, "/bin/sh -c #(nop) ADD file:abc in /", regular("bin/sh", 500)),
+		testLayer(1, "/bin/sh -c apt-get update && apt-get install -y curl", regular("usr/bin/curl", 300)),
+		testLayer(2, "RUN /bin/sh -c apt-get update && apt-get install -y curl # buildkit", regular("usr/bin/curl", 350)),
+		testLayer(3, "/bin/sh -c #(nop) COPY file:def in /app", regular("app/main", 200)),
+	}
+	want := []CommandStat{
+		{Command: "apt-get update && apt-get install -y curl", Layers: 2, Size: 650},
+		{Command: "ADD file:abc in /", Layers: 1, Size: 500},
+		{Command: "COPY file:def in /app", Layers: 1, Size: 200},
+	}
+	if got := ByCommand(layers); !reflect.DeepEqual(got, want) {
+		t.Errorf("ByCommand() = %+v, want %+v", got, want)
+	}
+	// commands of the same size are sorted by name
+	tied := []*Layer{
+		testLayer(0, "/bin/sh -c make b", regular("b", 100)),
+		testLayer(1, "/bin/sh -c make a", regular("a", 100)),
+	}
+	if got := ByCommand(tied); len(got) != 2 || got[0].Command != "make a" || got[1].Command != "make b" {
+		t.Errorf("ByCommand() of the same sizes = %+v", got)
+	}
+}
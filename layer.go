@@ -0,0 +1,241 @@
+package dolay
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// gzipMagic defines first bytes of the gzip stream
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// zstdMagic defines first bytes of the zstd frame
+var zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+// xzMagic defines first bytes of the xz stream
+var xzMagic = []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}
+
+// unpack returns reader of the archive content.
+// Archive is unpacked if it starts with xz or gzip magic bytes
+func unpack(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(len(xzMagic))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	switch {
+	case bytes.Equal(magic, xzMagic):
+		return xz.NewReader(br)
+	case bytes.HasPrefix(magic, gzipMagic):
+		return gzip.NewReader(br)
+	}
+	return br, nil
+}
+
+// isLayer returns true if archive entry contains layer
+func isLayer(name string) bool {
+	return strings.HasSuffix(name, "/layer.tar") ||
+		strings.HasSuffix(name, ".tar.gz") ||
+		strings.HasSuffix(name, ".tgz") ||
+		strings.HasSuffix(name, ".tar.zst")
+}
+
+// Compressions of layers
+const (
+	CompressionGzip = "gzip"
+	CompressionZstd = "zstd"
+)
+
+// decompress returns reader of the layer content and its compression.
+// Layer is unpacked if it starts with gzip or zstd magic bytes. The
+// uncompressed section is returned as is, so tar reader seeks over
+// content of files which isn't read
+func decompress(r io.Reader) (io.Reader, string, error) {
+	if sr, ok := r.(*io.SectionReader); ok {
+		magic := make([]byte, len(zstdMagic))
+		n, err := sr.ReadAt(magic, 0)
+		if err != nil && err != io.EOF {
+			return nil, "", err
+		}
+		if !bytes.HasPrefix(magic[:n], gzipMagic) && !bytes.HasPrefix(magic[:n], zstdMagic) {
+			return sr, "", nil
+		}
+	}
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(len(zstdMagic))
+	if err != nil && err != io.EOF {
+		return nil, "", err
+	}
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		zr, err := gzip.NewReader(br)
+		return zr, CompressionGzip, err
+	case bytes.HasPrefix(magic, zstdMagic):
+		zr, err := zstd.NewReader(br, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, "", err
+		}
+		return zr.IOReadCloser(), CompressionZstd, nil
+	}
+	return br, "", nil
+}
+
+// ReadLayer provides reading of the standalone layer tar,
+// which can be compressed with gzip or zstd
+func ReadLayer(r io.Reader) (*Layer, error) {
+	layer, err := readLayer(r, layerOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCorruptLayer, err)
+	}
+	return layer, nil
+}
+
+// layerOptions defines options of reading of the layer
+type layerOptions struct {
+	// visit is called with content of regular files if it's set
+	visit func(*tar.Header, io.Reader) error
+	// depth is depth of expanding of archives
+	depth int
+	// maxFiles limits held files to the largest ones passing match
+	maxFiles int
+	match    func(*tar.Header) bool
+}
+
+// layer returns options of reading of the layer by path
+func (opts Options) layer(path string) layerOptions {
+	lo := layerOptions{depth: opts.Recurse, maxFiles: opts.MaxFiles, match: opts.Match}
+	if opts.Content != nil {
+		lo.visit = func(h *tar.Header, r io.Reader) error {
+			return opts.Content(path, h, r)
+		}
+	}
+	return lo
+}
+
+// readLayer provides reading of files from the layer. Content of
+// regular files is passed to visit, archives are expanded and only
+// the largest files are held by options
+func readLayer(r io.Reader, opts layerOptions) (*Layer, error) {
+	start := time.Now()
+	content, compression, err := decompress(r)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decompress layer: %v", err)
+	}
+	if c, ok := content.(io.Closer); ok {
+		defer c.Close()
+	}
+	record := tar.NewReader(content)
+
+	var fs, whiteouts []*tar.Header
+	var archives []*Archive
+	var top *topFiles
+	if opts.maxFiles > 0 {
+		top = &topFiles{}
+	}
+	var total uint64
+	var dirs, count int
+	for {
+		h, err := record.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		// global PAX header carries records for the following
+		// entries and isn't a file of the layer
+		if h.Typeflag == tar.TypeXGlobalHeader {
+			continue
+		}
+		fi := h.FileInfo()
+		if fi.IsDir() {
+			dirs++
+			continue
+		}
+		if IsWhiteout(h.Name) {
+			whiteouts = append(whiteouts, h)
+			continue
+		}
+		// sparse file is a regular file with holes, and its size
+		// is the logical one for both GNU and PAX sparse formats
+		if h.Typeflag == tar.TypeGNUSparse {
+			h.Typeflag = tar.TypeReg
+		}
+		switch {
+		case opts.depth > 0 && h.Typeflag == tar.TypeReg && IsArchive(h.Name):
+			// file which isn't readable as tar is left as is
+			if a, err := readArchive(h.Name, record, opts.depth); err == nil {
+				archives = append(archives, a)
+			}
+		case opts.visit != nil && h.Typeflag == tar.TypeReg:
+			if err := opts.visit(h, record); err != nil {
+				return nil, err
+			}
+		}
+		count++
+		total += uint64(h.Size)
+		if top == nil {
+			fs = append(fs, h)
+		} else if opts.match == nil || opts.match(h) {
+			top.add(h, opts.maxFiles)
+		}
+	}
+	if top != nil {
+		fs = top.Files
+		sort.Stable(Files(fs))
+	}
+	return &Layer{
+		Files:       fs,
+		Count:       count,
+		Size:        total,
+		Dirs:        dirs,
+		Whiteouts:   whiteouts,
+		Compression: compression,
+		DecodeTime:  time.Since(start),
+		Archives:    archives,
+	}, nil
+}
+
+// TopFiles returns at most n largest files of the layer.
+// Files of the layer are not reordered
+func (l *Layer) TopFiles(n int) Files {
+	files := append(Files(nil), l.Files...)
+	sort.Stable(files)
+	if n < 0 {
+		n = 0
+	}
+	if n < len(files) {
+		files = files[:n]
+	}
+	return files
+}
+
+// TotalSize returns total size of files of the layer. It's the same
+// as Size unless files were changed after the layer was read
+func (l *Layer) TotalSize() uint64 {
+	var total uint64
+	for _, f := range l.Files {
+		total += uint64(f.Size)
+	}
+	return total
+}
+
+// FilterFiles returns files of the layer matching the predicate
+func (l *Layer) FilterFiles(pred func(*tar.Header) bool) Files {
+	var files Files
+	for _, f := range l.Files {
+		if pred(f) {
+			files = append(files, f)
+		}
+	}
+	return files
+}
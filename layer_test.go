@@ -0,0 +1,273 @@
+package dolay
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadLayerLinks(t *testing.T) {
+	layer, err := ReadLayer(bytes.NewReader(buildTar(t,
+		regular("bin/busybox", 900),
+		link(tar.TypeSymlink, "bin/sh", "/bin/busybox"),
+		link(tar.TypeLink, "bin/ls", "bin/busybox"),
+	)))
+	if err != nil {
+		t.Fatalf("ReadLayer() error = %v", err)
+	}
+	tests := []struct {
+		name     string
+		typeflag byte
+		target   string
+		size     int64
+	}{
+		{"bin/busybox", tar.TypeReg, "", 900},
+		{"bin/sh", tar.TypeSymlink, "/bin/busybox", 0},
+		{"bin/ls", tar.TypeLink, "bin/busybox", 0},
+	}
+	if len(layer.Files) != len(tests) {
+		t.Fatalf("ReadLayer() files = %v", names(layer.Files))
+	}
+	for i, tt := range tests {
+		f := layer.Files[i]
+		if f.Name != tt.name || f.Typeflag != tt.typeflag || f.Linkname != tt.target || f.Size != tt.size {
+			t.Errorf("file %d = %s (%c) -> %q, %d bytes, want %s (%c) -> %q, %d bytes",
+				i, f.Name, f.Typeflag, f.Linkname, f.Size, tt.name, tt.typeflag, tt.target, tt.size)
+		}
+	}
+	if layer.Count != 3 || layer.Size != 900 {
+		t.Errorf("ReadLayer() count = %d, size = %d, want 3 and 900", layer.Count, layer.Size)
+	}
+}
+
+func TestReadLayer(t *testing.T) {
+	layer := buildTar(t, directory("etc/"), regular("etc/passwd", 100), regular("usr/bin/curl", 300))
+	tests := []struct {
+		name        string
+		data        []byte
+		compression string
+	}{
+		{"bare", layer, ""},
+		{"gzip", gzipData(t, layer), CompressionGzip},
+		{"zstd", zstdData(t, layer), CompressionZstd},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := ReadLayer(bytes.NewReader(tt.data))
+			if err != nil {
+				t.Fatalf("ReadLayer() error = %v", err)
+			}
+			if got := names(l.Files); !reflect.DeepEqual(got, []string{"etc/passwd", "usr/bin/curl"}) {
+				t.Errorf("ReadLayer() files = %v", got)
+			}
+			if l.Size != 400 || l.Dirs != 1 || l.Compression != tt.compression {
+				t.Errorf("ReadLayer() size = %d, dirs = %d, compression = %q", l.Size, l.Dirs, l.Compression)
+			}
+		})
+	}
+	// image archive is a layer with files of the archive
+	archive := testImage{Layers: [][]byte{layer}}.docker(t)
+	l, err := ReadLayer(bytes.NewReader(archive))
+	if err != nil {
+		t.Fatalf("ReadLayer() of the image archive error = %v", err)
+	}
+	if len(l.Files) != 3 {
+		t.Errorf("ReadLayer() of the image archive files = %v", names(l.Files))
+	}
+	if _, err := ReadLayer(bytes.NewReader(layer[:700])); !errors.Is(err, ErrCorruptLayer) {
+		t.Errorf("ReadLayer() of the truncated layer error = %v, want %v", err, ErrCorruptLayer)
+	}
+}
+
+func TestLayerTopFiles(t *testing.T) {
+	layer := testLayer(0, "", regular("a", 100), regular("b", 300), regular("c", 200), regular("d", 300))
+	tests := []struct {
+		n    int
+		want []string
+	}{
+		{0, []string{}},
+		{-1, []string{}},
+		{1, []string{"b"}},
+		{3, []string{"b", "d", "c"}},
+		{4, []string{"b", "d", "c", "a"}},
+		{100, []string{"b", "d", "c", "a"}},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.n), func(t *testing.T) {
+			if got := names(layer.TopFiles(tt.n)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("TopFiles(%d) = %v, want %v", tt.n, got, tt.want)
+			}
+		})
+	}
+	if got := names(layer.Files); !reflect.DeepEqual(got, []string{"a", "b", "c", "d"}) {
+		t.Errorf("files of the layer are reordered: %v", got)
+	}
+	if got := (&Layer{}).TopFiles(10); len(got) != 0 {
+		t.Errorf("TopFiles() of the empty layer = %v", names(got))
+	}
+}
+
+func TestLayerTotalSize(t *testing.T) {
+	tests := []struct {
+		name  string
+		layer *Layer
+		want  uint64
+	}{
+		{"empty", &Layer{}, 0},
+		{"files", testLayer(0, "", regular("a", 100), regular("b", 300)), 400},
+		{"links", testLayer(0, "", regular("a", 100), link(tar.TypeSymlink, "b", "a")), 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.layer.TotalSize(); got != tt.want {
+				t.Errorf("TotalSize() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLayerFilterFiles(t *testing.T) {
+	layer := testLayer(0, "", regular("etc/passwd", 100), regular("usr/lib/libc.so", 900), regular("usr/lib/libz.so", 90))
+	tests := []struct {
+		name string
+		pred func(*tar.Header) bool
+		want []string
+	}{
+		{"all", func(*tar.Header) bool { return true }, []string{"etc/passwd", "usr/lib/libc.so", "usr/lib/libz.so"}},
+		{"none", func(*tar.Header) bool { return false }, []string{}},
+		{"by size", func(h *tar.Header) bool { return h.Size >= 100 }, []string{"etc/passwd", "usr/lib/libc.so"}},
+		{"by name", func(h *tar.Header) bool { return strings.HasSuffix(h.Name, ".so") }, []string{"usr/lib/libc.so", "usr/lib/libz.so"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := names(layer.FilterFiles(tt.pred)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FilterFiles() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadLayerLongNames(t *testing.T) {
+	dir := strings.Repeat("very-long-directory-name/", 12)
+	name := dir + "lib/libexample.so.1.2.3"
+	if len(name) < 300 {
+		t.Fatalf("name of %d bytes isn't long", len(name))
+	}
+	tests := []struct {
+		name   string
+		format tar.Format
+	}{
+		{"pax", tar.FormatPAX},
+		{"gnu", tar.FormatGNU},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries := []tarEntry{directory(dir), regular(name, 700), regular(dir+".wh.old.so", 0)}
+			for _, e := range entries {
+				e.Format = tt.format
+			}
+			if tt.format == tar.FormatPAX {
+				global := tarEntry{Header: &tar.Header{Typeflag: tar.TypeXGlobalHeader, PAXRecords: map[string]string{"comment": "generated"}}}
+				entries = append([]tarEntry{global}, entries...)
+			}
+			layer, err := ReadLayer(bytes.NewReader(buildTar(t, entries...)))
+			if err != nil {
+				t.Fatalf("ReadLayer() error = %v", err)
+			}
+			if got := names(layer.Files); !reflect.DeepEqual(got, []string{name}) {
+				t.Errorf("ReadLayer() files = %v, want %s", got, name)
+			}
+			if got := names(layer.Whiteouts); !reflect.DeepEqual(got, []string{dir + ".wh.old.so"}) {
+				t.Errorf("ReadLayer() whiteouts = %v", got)
+			}
+			if layer.Count != 1 || layer.Size != 700 || layer.Dirs != 1 {
+				t.Errorf("ReadLayer() count = %d, size = %d, dirs = %d, want 1, 700 and 1", layer.Count, layer.Size, layer.Dirs)
+			}
+		})
+	}
+}
+
+// setChecksum provides computing of the checksum of the header block
+// after its fields are changed
+func setChecksum(block []byte) {
+	copy(block[148:156], "        ")
+	var sum int
+	for _, b := range block[:512] {
+		sum += int(b)
+	}
+	copy(block[148:156], fmt.Sprintf("%06o\x00 ", sum))
+}
+
+// paxRecord returns the record of the PAX extended header
+func paxRecord(key, value string) string {
+	record := fmt.Sprintf(" %s=%s\n", key, value)
+	n := len(record)
+	for len(fmt.Sprint(n))+len(record) != n {
+		n = len(fmt.Sprint(n)) + len(record)
+	}
+	return fmt.Sprint(n) + record
+}
+
+// gnuSparse returns archive of the file in the old GNU sparse format
+// with data of the last stored bytes of the logical size
+func gnuSparse(t *testing.T, name string, stored, size int) []byte {
+	t.Helper()
+	data := buildTar(t, tarEntry{
+		Header: &tar.Header{Name: name, Typeflag: tar.TypeGNUSparse, Size: int64(stored), Format: tar.FormatGNU, ModTime: testTime},
+		Body:   bytes.Repeat([]byte{'x'}, stored),
+	})
+	copy(data[386:], fmt.Sprintf("%011o\x00%011o\x00", size-stored, stored))
+	copy(data[483:], fmt.Sprintf("%011o\x00", size))
+	setChecksum(data)
+	return data
+}
+
+// paxSparse returns archive of the file in the PAX sparse format 1.0
+// with data of the last stored bytes of the logical size. archive/tar
+// doesn't write reserved GNU.sparse records, so the extended header
+// is written as a regular file and its type is changed
+func paxSparse(t *testing.T, name string, stored, size int) []byte {
+	t.Helper()
+	records := paxRecord("GNU.sparse.major", "1") + paxRecord("GNU.sparse.minor", "0") +
+		paxRecord("GNU.sparse.name", name) + paxRecord("GNU.sparse.realsize", fmt.Sprint(size))
+	sparseMap := fmt.Sprintf("1\n%d\n%d\n", size-stored, stored)
+	body := append([]byte(sparseMap), make([]byte, 512-len(sparseMap))...)
+	data := buildTar(t,
+		file("PaxHeaders.0/data", []byte(records)),
+		file("GNUSparseFile.0/data", append(body, bytes.Repeat([]byte{'x'}, stored)...)))
+	data[156] = tar.TypeXHeader
+	setChecksum(data)
+	return data
+}
+
+func TestReadLayerSparse(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"gnu", gnuSparse(t, "var/log/lastlog", 512, 1<<20)},
+		{"pax", paxSparse(t, "var/log/lastlog", 512, 1<<20)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			layer, err := ReadLayer(bytes.NewReader(tt.data))
+			if err != nil {
+				t.Fatalf("ReadLayer() error = %v", err)
+			}
+			if len(layer.Files) != 1 {
+				t.Fatalf("ReadLayer() files = %v, want var/log/lastlog", names(layer.Files))
+			}
+			f := layer.Files[0]
+			if f.Name != "var/log/lastlog" || f.Typeflag != tar.TypeReg || f.Size != 1<<20 {
+				t.Errorf("ReadLayer() file = %s (%c), %d bytes, want var/log/lastlog (0), %d bytes", f.Name, f.Typeflag, f.Size, 1<<20)
+			}
+			if layer.Count != 1 || layer.Size != 1<<20 || layer.TotalSize() != 1<<20 {
+				t.Errorf("ReadLayer() count = %d, size = %d, total size = %d, want 1 and the logical size", layer.Count, layer.Size, layer.TotalSize())
+			}
+		})
+	}
+}
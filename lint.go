@@ -0,0 +1,70 @@
+package dolay
+
+import "strings"
+
+// CacheRule defines known location of the package-manager cache
+type CacheRule struct {
+	Name string
+	// Paths are matched at any directory of the file path,
+	// so ".cache/pip/" matches home directory of any user
+	Paths []string
+	// Fix is the suggested cleanup
+	Fix string
+}
+
+// CacheRules contains known package-manager caches
+var CacheRules = []CacheRule{
+	{Name: "apt", Paths: []string{"var/cache/apt/", "var/lib/apt/lists/"},
+		Fix: "run apt-get clean && rm -rf /var/lib/apt/lists/* in the same RUN"},
+	{Name: "apk", Paths: []string{"var/cache/apk/"},
+		Fix: "use apk add --no-cache"},
+	{Name: "yum", Paths: []string{"var/cache/yum/"},
+		Fix: "run yum clean all in the same RUN"},
+	{Name: "dnf", Paths: []string{"var/cache/dnf/"},
+		Fix: "run dnf clean all in the same RUN"},
+	{Name: "pip", Paths: []string{".cache/pip/"},
+		Fix: "use pip install --no-cache-dir"},
+	{Name: "npm", Paths: []string{".npm/_cacache/"},
+		Fix: "run npm cache clean --force in the same RUN"},
+}
+
+// Match returns true if the file is inside of the cache
+func (c CacheRule) Match(name string) bool {
+	for _, p := range c.Paths {
+		if strings.HasPrefix(name, p) || strings.Contains(name, "/"+p) {
+			return true
+		}
+	}
+	return false
+}
+
+// Warning defines cache left in the layer
+type Warning struct {
+	Layer   int    `json:"layer"`
+	Command string `json:"command"`
+	Rule    string `json:"rule"`
+	Files   int    `json:"files"`
+	Size    uint64 `json:"size"`
+	Fix     string `json:"fix"`
+}
+
+// Lint returns warnings about package-manager caches in layers,
+// in order of layers and of rules
+func Lint(layers []*Layer, rules []CacheRule) []Warning {
+	warnings := []Warning{}
+	for _, l := range layers {
+		for _, rule := range rules {
+			w := Warning{Layer: l.Index, Command: Command(l.History), Rule: rule.Name, Fix: rule.Fix}
+			for _, f := range l.Files {
+				if rule.Match(f.Name) {
+					w.Files++
+					w.Size += uint64(f.Size)
+				}
+			}
+			if w.Files > 0 {
+				warnings = append(warnings, w)
+			}
+		}
+	}
+	return warnings
+}
@@ -0,0 +1,34 @@
+package dolay
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	layers := []*Layer{
+		testLayer(0, "/bin/sh -c apt-get update && apt-get install -y curl",
+			regular("usr/bin/curl", 300),
+			regular("var/cache/apt/archives/curl.deb", 200),
+			regular("var/cache/apt/pkgcache.bin", 100),
+			regular("var/lib/apt/lists/deb.debian.org_dists", 50)),
+		testLayer(1, "/bin/sh -c apk add curl",
+			regular("./var/cache/apk/APKINDEX.tar.gz", 40),
+			regular("usr/bin/curl", 300)),
+		testLayer(2, "/bin/sh -c apk add --no-cache curl && pip install requests",
+			regular("usr/bin/curl", 300),
+			regular("root/.cache/pip/http/wheel", 70),
+			regular("var/cache/apkfile", 5)),
+	}
+	want := []Warning{
+		{Layer: 0, Command: "apt-get update && apt-get install -y curl", Rule: "apt", Files: 3, Size: 350, Fix: CacheRules[0].Fix},
+		{Layer: 1, Command: "apk add curl", Rule: "apk", Files: 1, Size: 40, Fix: CacheRules[1].Fix},
+		{Layer: 2, Command: "apk add --no-cache curl && pip install requests", Rule: "pip", Files: 1, Size: 70, Fix: CacheRules[4].Fix},
+	}
+	if got := Lint(layers, CacheRules); !reflect.DeepEqual(got, want) {
+		t.Errorf("Lint() = %+v, want %+v", got, want)
+	}
+	if got := Lint(layers[:0], CacheRules); got == nil || len(got) != 0 {
+		t.Errorf("Lint() without layers = %#v, want empty", got)
+	}
+}
@@ -0,0 +1,55 @@
+package dolay
+
+import (
+	"archive/tar"
+)
+
+// Special mode bits
+const (
+	modeSetuid = 04000
+	modeSetgid = 02000
+	modeSticky = 01000
+)
+
+// Permissions returns mode of the entry as ls -l permission string,
+// like "-rwsr-xr-x" for the setuid binary
+func Permissions(h *tar.Header) string {
+	const rwx = "rwxrwxrwx"
+	b := []byte("----------")
+	switch h.Typeflag {
+	case tar.TypeDir:
+		b[0] = 'd'
+	case tar.TypeSymlink:
+		b[0] = 'l'
+	case tar.TypeChar:
+		b[0] = 'c'
+	case tar.TypeBlock:
+		b[0] = 'b'
+	case tar.TypeFifo:
+		b[0] = 'p'
+	}
+	for i := 0; i < 9; i++ {
+		if h.Mode&(1<<uint(8-i)) != 0 {
+			b[i+1] = rwx[i]
+		}
+	}
+	special := func(bit int64, pos int, set, unset byte) {
+		if h.Mode&bit == 0 {
+			return
+		}
+		if b[pos] == '-' {
+			b[pos] = unset
+		} else {
+			b[pos] = set
+		}
+	}
+	special(modeSetuid, 3, 's', 'S')
+	special(modeSetgid, 6, 's', 'S')
+	special(modeSticky, 9, 't', 'T')
+	return string(b)
+}
+
+// HasSpecialBits returns true if setuid, setgid or sticky bit is set
+func HasSpecialBits(h *tar.Header) bool {
+	return h.Mode&(modeSetuid|modeSetgid|modeSticky) != 0
+}
@@ -0,0 +1,38 @@
+package dolay
+
+import (
+	"archive/tar"
+	"testing"
+)
+
+func TestPermissions(t *testing.T) {
+	tests := []struct {
+		name     string
+		typeflag byte
+		mode     int64
+		want     string
+		special  bool
+	}{
+		{"bin/su", tar.TypeReg, 04755, "-rwsr-xr-x", true},
+		{"usr/bin/wall", tar.TypeReg, 02755, "-rwxr-sr-x", true},
+		{"tmp", tar.TypeDir, 01777, "drwxrwxrwt", true},
+		{"setuid without exec", tar.TypeReg, 04644, "-rwSr--r--", true},
+		{"sticky without exec", tar.TypeDir, 01754, "drwxr-xr-T", true},
+		{"etc/passwd", tar.TypeReg, 0644, "-rw-r--r--", false},
+		{"bin/sh", tar.TypeSymlink, 0777, "lrwxrwxrwx", false},
+		{"dev/null", tar.TypeChar, 0666, "crw-rw-rw-", false},
+		{"dev/sda", tar.TypeBlock, 0660, "brw-rw----", false},
+		{"run/fifo", tar.TypeFifo, 0600, "prw-------", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &tar.Header{Name: tt.name, Typeflag: tt.typeflag, Mode: tt.mode}
+			if got := Permissions(h); got != tt.want {
+				t.Errorf("Permissions(%o) = %s, want %s", tt.mode, got, tt.want)
+			}
+			if got := HasSpecialBits(h); got != tt.special {
+				t.Errorf("HasSpecialBits(%o) = %v, want %v", tt.mode, got, tt.special)
+			}
+		})
+	}
+}
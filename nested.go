@@ -0,0 +1,50 @@
+package dolay
+
+import (
+	"errors"
+	"io"
+	"strings"
+)
+
+// archiveSuffixes contains extensions of tar archives,
+// which can be compressed with gzip or zstd
+var archiveSuffixes = []string{".tar", ".tar.gz", ".tgz", ".tar.zst", ".tar.zstd"}
+
+// IsArchive returns true if the file is a tar archive by its name
+func IsArchive(name string) bool {
+	for _, s := range archiveSuffixes {
+		if strings.HasSuffix(name, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// Archive defines tar archive which is a file of the layer,
+// like vendored assets or the cache of the package manager
+type Archive struct {
+	// Name is path of the archive in the layer
+	Name  string
+	Files Files
+	// Size is total logical size of files of the archive
+	Size uint64
+	// Archives contains archives inside of the archive
+	Archives []*Archive
+}
+
+// errNoEntries means that the file has no tar entries, like
+// the empty file or zero blocks, so it's not reported as archive
+var errNoEntries = errors.New("no entries in archive")
+
+// readArchive provides reading of files from the archive.
+// Archives inside of it are expanded up to depth-1 levels
+func readArchive(name string, r io.Reader, depth int) (*Archive, error) {
+	l, err := readLayer(r, layerOptions{depth: depth - 1})
+	if err != nil {
+		return nil, err
+	}
+	if l.Count == 0 && l.Dirs == 0 && len(l.Whiteouts) == 0 {
+		return nil, errNoEntries
+	}
+	return &Archive{Name: name, Files: l.Files, Size: l.Size, Archives: l.Archives}, nil
+}
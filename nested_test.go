@@ -0,0 +1,86 @@
+package dolay
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestIsArchive(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"vendor/assets.tar", true},
+		{"cache/pkg.tar.gz", true},
+		{"cache/pkg.tgz", true},
+		{"cache/pkg.tar.zst", true},
+		{"cache/pkg.tar.zstd", true},
+		{"usr/lib/libtar.so", false},
+		{"app/data.gz", false},
+		{"app/tar", false},
+	}
+	for _, tt := range tests {
+		if got := IsArchive(tt.name); got != tt.want {
+			t.Errorf("IsArchive(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// archiveNames returns names of archives and of their files by levels
+func archiveNames(archives []*Archive) []string {
+	var result []string
+	for _, a := range archives {
+		result = append(result, a.Name)
+		for _, f := range a.Files {
+			result = append(result, a.Name+":"+f.Name)
+		}
+		for _, name := range archiveNames(a.Archives) {
+			result = append(result, a.Name+">"+name)
+		}
+	}
+	return result
+}
+
+func TestAnalyzeRecurse(t *testing.T) {
+	inner := buildTar(t, regular("index.js", 300))
+	outer := gzipData(t, buildTar(t, regular("README", 10), file("inner.tar", inner)))
+	dirs := buildTar(t, directory("cache/"))
+	layer := buildTar(t,
+		regular("bin/sh", 100),
+		file("vendor/assets.tar.gz", outer),
+		file("broken.tar", []byte("not a tar archive")),
+		file("empty.tar", nil),
+		file("zeros.tar", make([]byte, 1024)),
+		file("dirs.tar", dirs),
+	)
+	archive := testImage{Layers: [][]byte{layer}}.docker(t)
+	tests := []struct {
+		name  string
+		depth int
+		want  []string
+	}{
+		{"disabled", 0, nil},
+		{"one level", 1, []string{"vendor/assets.tar.gz", "vendor/assets.tar.gz:README", "vendor/assets.tar.gz:inner.tar", "dirs.tar"}},
+		{"two levels", 2, []string{
+			"vendor/assets.tar.gz", "vendor/assets.tar.gz:README", "vendor/assets.tar.gz:inner.tar",
+			"vendor/assets.tar.gz>inner.tar", "vendor/assets.tar.gz>inner.tar:index.js", "dirs.tar",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := AnalyzeWithOptions(bytes.NewReader(archive), Options{Recurse: tt.depth})
+			if err != nil {
+				t.Fatalf("AnalyzeWithOptions() error = %v", err)
+			}
+			l := report.Layers[0]
+			if got := archiveNames(l.Archives); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("archives = %v, want %v", got, tt.want)
+			}
+			// archives, and files which aren't archives, are files of the layer too
+			if len(l.Files) != 6 || l.Size != uint64(100+len(outer)+len("not a tar archive")+1024+len(dirs)) {
+				t.Errorf("layer = %v of %d bytes, want 6 files", names(l.Files), l.Size)
+			}
+		})
+	}
+}
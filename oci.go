@@ -0,0 +1,135 @@
+package dolay
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const (
+	ociIndex = "index.json"
+	blobsDir = "blobs/"
+
+	// maxIndexDepth limits nesting of the image indexes
+	maxIndexDepth = 4
+)
+
+// Descriptor defines OCI content descriptor
+type Descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// OCIIndex defines OCI image index (index.json)
+type OCIIndex struct {
+	Manifests []Descriptor `json:"manifests"`
+}
+
+// OCIManifest defines OCI image manifest
+type OCIManifest struct {
+	Config Descriptor   `json:"config"`
+	Layers []Descriptor `json:"layers"`
+}
+
+// mediaCompression returns compression of the layer by its media type,
+// or empty string if it's uncompressed or unknown
+func mediaCompression(mediaType string) string {
+	switch {
+	case strings.HasSuffix(mediaType, "+gzip"), strings.HasSuffix(mediaType, ".tar.gzip"):
+		return CompressionGzip
+	case strings.HasSuffix(mediaType, "+zstd"), strings.HasSuffix(mediaType, ".tar.zstd"):
+		return CompressionZstd
+	}
+	return ""
+}
+
+// describe provides setting of the compressed size and compression
+// of the layer from its descriptor, which is authoritative over
+// the measured ones
+func (l *Layer) describe(d Descriptor) {
+	if d.Size > 0 {
+		l.BlobSize = uint64(d.Size)
+	}
+	if c := mediaCompression(d.MediaType); c != "" {
+		l.Compression = c
+	}
+}
+
+// isBlob returns true if archive entry is content-addressed blob
+func isBlob(name string) bool {
+	return strings.HasPrefix(name, blobsDir)
+}
+
+// blobPath returns path of the blob inside of the archive by digest
+func blobPath(digest string) string {
+	return blobsDir + strings.Replace(digest, ":", "/", 1)
+}
+
+// Digest returns digest of the layer or the config by its path
+// inside of the archive. Paths of the OCI blobs contain digest,
+// and paths of the legacy docker archive contain its hex value
+// ("<hex>/layer.tar" or "<hex>.json"). Empty string is returned
+// if the path doesn't contain digest
+func Digest(path string) string {
+	if isBlob(path) {
+		return strings.Replace(strings.TrimPrefix(path, blobsDir), "/", ":", 1)
+	}
+	hex := strings.TrimSuffix(strings.SplitN(path, "/", 2)[0], ".json")
+	if len(hex) != 64 || strings.Trim(hex, "0123456789abcdef") != "" {
+		return ""
+	}
+	return "sha256:" + hex
+}
+
+// resolveIndex provides resolving of the OCI index into manifest items
+// in the same form as docker manifest.json
+func resolveIndex(index []byte, blobs map[string][]byte) ([]ManifestItem, error) {
+	var items []ManifestItem
+	if err := resolveIndexDepth(index, blobs, nil, 0, &items); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func resolveIndexDepth(index []byte, blobs map[string][]byte, tags []string, depth int, items *[]ManifestItem) error {
+	if depth > maxIndexDepth {
+		return fmt.Errorf("%w: image index is nested too deeply", ErrUnsupportedFormat)
+	}
+	var idx OCIIndex
+	if err := json.Unmarshal(index, &idx); err != nil {
+		return fmt.Errorf("%w: unable to decode image index: %v", ErrCorruptArchive, err)
+	}
+	for _, d := range idx.Manifests {
+		data, ok := blobs[blobPath(d.Digest)]
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrNoManifest, d.Digest)
+		}
+		refTags := tags
+		if ref, ok := d.Annotations["org.opencontainers.image.ref.name"]; ok {
+			refTags = []string{ref}
+		}
+		if strings.HasSuffix(d.MediaType, "index.v1+json") || strings.HasSuffix(d.MediaType, "manifest.list.v2+json") {
+			if err := resolveIndexDepth(data, blobs, refTags, depth+1, items); err != nil {
+				return err
+			}
+			continue
+		}
+		var m OCIManifest
+		if err := json.Unmarshal(data, &m); err != nil {
+			return fmt.Errorf("%w: unable to decode manifest %s: %v", ErrCorruptArchive, d.Digest, err)
+		}
+		item := ManifestItem{
+			Config:       blobPath(m.Config.Digest),
+			RepoTags:     refTags,
+			LayerSources: make(map[string]Descriptor),
+		}
+		for _, l := range m.Layers {
+			item.Layers = append(item.Layers, blobPath(l.Digest))
+			item.LayerSources[l.Digest] = l
+		}
+		*items = append(*items, item)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package dolay
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestDigest(t *testing.T) {
+	hex := strings.Repeat("0a", 32)
+	tests := []struct {
+		path string
+		want string
+	}{
+		{hex + "/layer.tar", "sha256:" + hex},
+		{hex + ".json", "sha256:" + hex},
+		{"blobs/sha256/" + hex, "sha256:" + hex},
+		{"blobs/sha512/" + strings.Repeat("ff", 64), "sha512:" + strings.Repeat("ff", 64)},
+		{"layer/layer.tar", ""},
+		{strings.ToUpper(hex) + "/layer.tar", ""},
+		{hex[:62] + "/layer.tar", ""},
+		{"manifest.json", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := Digest(tt.path); got != tt.want {
+				t.Errorf("Digest(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+
+	// digests of generated archives are of their layers and configs
+	format := regexp.MustCompile(`^sha256:[0-9a-f]{64}$`)
+	layer := buildTar(t, regular("a", 1))
+	for name, archive := range map[string][]byte{
+		"docker": testImage{Layers: [][]byte{layer}}.docker(t),
+		"oci":    testImage{Layers: [][]byte{layer}}.oci(t),
+	} {
+		report, err := Analyze(bytes.NewReader(archive))
+		if err != nil {
+			t.Fatalf("%s: Analyze() error = %v", name, err)
+		}
+		if got := Digest(report.Layers[0].Path); got != "sha256:"+sha256Hex(layer) {
+			t.Errorf("%s: digest of the layer = %q, want sha256 of the layer", name, got)
+		}
+		if got := Digest(report.Manifest.Config); !format.MatchString(got) {
+			t.Errorf("%s: digest of the config = %q, want sha256:<hex>", name, got)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package dolay
+
+import (
+	"archive/tar"
+	"path"
+	"sort"
+	"strings"
+)
+
+// cleanPath returns path of the entry relative to the image root
+func cleanPath(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
+
+// MergeStat defines totals of the image filesystem composed from layers
+type MergeStat struct {
+	// Files is number of files of the filesystem
+	Files int `json:"files"`
+	// Overwritten is number of files replaced by upper layers
+	Overwritten int `json:"overwritten"`
+	// Deleted is number of files deleted by whiteouts of upper layers
+	Deleted int `json:"deleted"`
+}
+
+// merge returns files of the image filesystem by path with totals
+func merge(layers []*Layer) (map[string]*tar.Header, MergeStat) {
+	fs := make(map[string]*tar.Header)
+	var stat MergeStat
+	// remove deletes the target with everything under it. Content
+	// of the directory is kept if it's the opaque whiteout
+	remove := func(target string, opaque bool) {
+		if _, ok := fs[target]; ok && !opaque {
+			delete(fs, target)
+			stat.Deleted++
+		}
+		prefix := target + "/"
+		for name := range fs {
+			if strings.HasPrefix(name, prefix) {
+				delete(fs, name)
+				stat.Deleted++
+			}
+		}
+	}
+	for _, l := range layers {
+		// whiteouts hide only files of lower layers,
+		// so they are applied before files of the layer
+		for _, w := range l.Whiteouts {
+			target, opaque := WhiteoutTarget(cleanPath(w.Name))
+			remove(target, opaque)
+		}
+		for _, f := range l.Files {
+			name := cleanPath(f.Name)
+			if _, ok := fs[name]; ok {
+				stat.Overwritten++
+			}
+			fs[name] = f
+		}
+	}
+	stat.Files = len(fs)
+	return fs, stat
+}
+
+// MergeStats returns totals of the image filesystem, which is composed
+// from layers like by Merge. Sum of files of layers is the number of
+// files of the filesystem with overwritten and deleted ones
+func MergeStats(layers []*Layer) MergeStat {
+	_, stat := merge(layers)
+	return stat
+}
+
+// Merge returns files of the image filesystem, which is composed from
+// layers in order from the bottom. Files of upper layers override files
+// with the same path, and whiteouts delete files of lower layers.
+// Files are sorted by path
+func Merge(layers []*Layer) Files {
+	fs, _ := merge(layers)
+	names := make([]string, 0, len(fs))
+	for name := range fs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	files := make(Files, 0, len(names))
+	for _, name := range names {
+		files = append(files, fs[name])
+	}
+	return files
+}
+
+// Since returns changes of the image filesystem made by layers from
+// the index onward. Filesystem composed from layers below the index is
+// compared with the final one, so files which are added and deleted
+// later are not listed. Files rewritten by the layers are modified
+// even if their size is the same. Changes are sorted by path
+func Since(layers []*Layer, index int) []FileDiff {
+	var base []*Layer
+	for _, l := range layers {
+		if l.Index < index {
+			base = append(base, l)
+		}
+	}
+	before := make(map[string]*tar.Header)
+	for _, f := range Merge(base) {
+		before[cleanPath(f.Name)] = f
+	}
+	result := []FileDiff{}
+	for _, f := range Merge(layers) {
+		name := cleanPath(f.Name)
+		old, ok := before[name]
+		delete(before, name)
+		switch {
+		case !ok:
+			result = append(result, FileDiff{Change: ChangeAdded, Name: name, Delta: f.Size})
+		case old != f:
+			result = append(result, FileDiff{Change: ChangeModified, Name: name, Delta: f.Size - old.Size})
+		}
+	}
+	for name, old := range before {
+		result = append(result, FileDiff{Change: ChangeRemoved, Name: name, Delta: -old.Size})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// OnlyAdded returns layers with only files which are added or modified
+// by them, and number of files which are inherited unchanged. Layers of
+// squashed or flattened images re-include files of lower layers, and
+// such file has the same path and header (type, size, mode, owner, link
+// and modification time) as the file of the filesystem below the layer.
+// Size and Count of returned layers are of their own files
+func OnlyAdded(layers []*Layer) ([]*Layer, int) {
+	fs := make(map[string]*tar.Header)
+	result := make([]*Layer, 0, len(layers))
+	var inherited int
+	for _, l := range layers {
+		for _, w := range l.Whiteouts {
+			target, opaque := WhiteoutTarget(cleanPath(w.Name))
+			prefix := target + "/"
+			for name := range fs {
+				if (name == target && !opaque) || strings.HasPrefix(name, prefix) {
+					delete(fs, name)
+				}
+			}
+		}
+		own := *l
+		own.Files = nil
+		for _, f := range l.Files {
+			name := cleanPath(f.Name)
+			if old, ok := fs[name]; ok && sameHeader(old, f) {
+				inherited++
+			} else {
+				own.Files = append(own.Files, f)
+			}
+			fs[name] = f
+		}
+		own.Count = len(own.Files)
+		own.Size = own.TotalSize()
+		result = append(result, &own)
+	}
+	return result, inherited
+}
+
+// sameHeader returns true if entries have the same metadata
+func sameHeader(a, b *tar.Header) bool {
+	return a.Typeflag == b.Typeflag && a.Size == b.Size && a.Mode == b.Mode &&
+		a.Uid == b.Uid && a.Gid == b.Gid && a.Linkname == b.Linkname && a.ModTime.Equal(b.ModTime)
+}
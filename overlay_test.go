@@ -0,0 +1,176 @@
+package dolay
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMerge(t *testing.T) {
+	layers := []*Layer{
+		testLayer(0, "ADD rootfs",
+			regular("bin/busybox", 900),
+			regular("etc/motd", 10),
+			regular("./etc/passwd", 100),
+			regular("var/cache/apk/APKINDEX", 40),
+			regular("var/cache/apk/x/y", 1),
+			regular("tmp/build/a.o", 20)),
+		testLayer(1, "RUN adduser app",
+			regular("etc/passwd", 120),
+			regular("etc/.wh.motd", 0),
+			regular("tmp/.wh.build", 0)),
+		testLayer(2, "RUN rm -rf /var/cache/apk/* && touch /var/cache/apk/new",
+			regular("var/cache/apk/.wh..wh..opq", 0),
+			regular("var/cache/apk/new", 0),
+			regular("etc/motd", 15)),
+	}
+	tests := []struct {
+		name   string
+		layers []*Layer
+		want   []string
+		sizes  map[string]int64
+		stat   MergeStat
+	}{
+		{
+			name:   "base",
+			layers: layers[:1],
+			want:   []string{"bin/busybox", "etc/motd", "./etc/passwd", "tmp/build/a.o", "var/cache/apk/APKINDEX", "var/cache/apk/x/y"},
+			stat:   MergeStat{Files: 6},
+		},
+		{
+			name:   "overwritten and deleted",
+			layers: layers[:2],
+			want:   []string{"bin/busybox", "etc/passwd", "var/cache/apk/APKINDEX", "var/cache/apk/x/y"},
+			sizes:  map[string]int64{"etc/passwd": 120},
+			stat:   MergeStat{Files: 4, Overwritten: 1, Deleted: 2},
+		},
+		{
+			name:   "opaque directory and recreated file",
+			layers: layers,
+			want:   []string{"bin/busybox", "etc/motd", "etc/passwd", "var/cache/apk/new"},
+			sizes:  map[string]int64{"etc/passwd": 120, "etc/motd": 15},
+			stat:   MergeStat{Files: 4, Overwritten: 1, Deleted: 4},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := Merge(tt.layers)
+			if got := names(files); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Merge() = %v, want %v", got, tt.want)
+			}
+			for _, f := range files {
+				if size, ok := tt.sizes[cleanPath(f.Name)]; ok && f.Size != size {
+					t.Errorf("Merge() %s size = %d, want %d of the upper layer", f.Name, f.Size, size)
+				}
+			}
+			if got := MergeStats(tt.layers); got != tt.stat {
+				t.Errorf("MergeStats() = %+v, want %+v", got, tt.stat)
+			}
+		})
+	}
+}
+
+func TestSince(t *testing.T) {
+	layers := []*Layer{
+		testLayer(0, "ADD rootfs",
+			regular("bin/busybox", 900),
+			regular("etc/passwd", 100),
+			regular("etc/motd", 10),
+			regular("var/cache/apk/APKINDEX", 40)),
+		testLayer(1, "RUN apk add curl",
+			regular("usr/bin/curl", 300),
+			regular("etc/passwd", 100),
+			regular("tmp/curl.tar", 50)),
+		testLayer(2, "RUN adduser app && rm /tmp/curl.tar",
+			regular("etc/passwd", 130),
+			regular("tmp/.wh.curl.tar", 0),
+			regular("etc/.wh.motd", 0)),
+		testLayer(3, "RUN rm -rf /var/cache/apk/*",
+			regular("var/cache/apk/.wh..wh..opq", 0),
+			regular("app/main", 500)),
+	}
+	tests := []struct {
+		name  string
+		index int
+		want  []FileDiff
+	}{
+		{"three layers", 1, []FileDiff{
+			{Change: ChangeAdded, Name: "app/main", Delta: 500},
+			{Change: ChangeRemoved, Name: "etc/motd", Delta: -10},
+			{Change: ChangeModified, Name: "etc/passwd", Delta: 30},
+			{Change: ChangeAdded, Name: "usr/bin/curl", Delta: 300},
+			{Change: ChangeRemoved, Name: "var/cache/apk/APKINDEX", Delta: -40},
+		}},
+		{"last layer", 3, []FileDiff{
+			{Change: ChangeAdded, Name: "app/main", Delta: 500},
+			{Change: ChangeRemoved, Name: "var/cache/apk/APKINDEX", Delta: -40},
+		}},
+		{"all layers", 0, []FileDiff{
+			{Change: ChangeAdded, Name: "app/main", Delta: 500},
+			{Change: ChangeAdded, Name: "bin/busybox", Delta: 900},
+			{Change: ChangeAdded, Name: "etc/passwd", Delta: 130},
+			{Change: ChangeAdded, Name: "usr/bin/curl", Delta: 300},
+		}},
+		{"above the last layer", 4, []FileDiff{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Since(layers, tt.index); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Since(%d) = %+v, want %+v", tt.index, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOnlyAdded(t *testing.T) {
+	touched := regular("etc/hosts", 20)
+	touched.ModTime = testTime.Add(time.Hour)
+	chmod := regular("usr/bin/tool", 50)
+	chmod.Mode = 0755
+	layers := []*Layer{
+		testLayer(0, "ADD rootfs",
+			regular("bin/busybox", 900),
+			regular("etc/passwd", 100),
+			regular("etc/hosts", 20),
+			regular("usr/bin/tool", 50),
+			regular("tmp/cache", 30)),
+		// flattened layer re-includes files of the base
+		testLayer(1, "COPY / /",
+			regular("bin/busybox", 900),
+			regular("etc/passwd", 120),
+			touched,
+			chmod,
+			regular("tmp/.wh.cache", 0),
+			regular("app/main", 300)),
+		testLayer(2, "COPY / /",
+			regular("bin/busybox", 900),
+			regular("tmp/cache", 30),
+			regular("app/main", 300)),
+	}
+	got, inherited := OnlyAdded(layers)
+	want := []struct {
+		files []string
+		size  uint64
+	}{
+		{[]string{"bin/busybox", "etc/passwd", "etc/hosts", "usr/bin/tool", "tmp/cache"}, 1100},
+		{[]string{"etc/passwd", "etc/hosts", "usr/bin/tool", "app/main"}, 490},
+		// the deleted file is added again
+		{[]string{"tmp/cache"}, 30},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("OnlyAdded() = %d layers, want %d", len(got), len(want))
+	}
+	for i, w := range want {
+		l := got[i]
+		if !reflect.DeepEqual(names(l.Files), w.files) || l.Size != w.size || l.Count != len(w.files) || l.Index != i {
+			t.Errorf("layer %d = %v of %d bytes, count %d, want %v of %d bytes", i, names(l.Files), l.Size, l.Count, w.files, w.size)
+		}
+	}
+	if inherited != 3 {
+		t.Errorf("OnlyAdded() inherited = %d, want 3", inherited)
+	}
+	// layers of the input are not changed
+	if len(layers[1].Files) != 5 || layers[1].Size != 1390 {
+		t.Errorf("input layer = %v of %d bytes", names(layers[1].Files), layers[1].Size)
+	}
+}
@@ -0,0 +1,55 @@
+package dolay
+
+import "sort"
+
+// OwnerStat defines totals of files of the same owner
+type OwnerStat struct {
+	UID   int    `json:"uid"`
+	GID   int    `json:"gid"`
+	Uname string `json:"uname,omitempty"`
+	Gname string `json:"gname,omitempty"`
+	Count int    `json:"count"`
+	Size  uint64 `json:"size"`
+}
+
+// ByOwner returns files of layers grouped by uid and gid with names of
+// the owner, sorted by total size from the largest. Files of all layers
+// are counted, so it's what each owner adds to the size of the image
+func ByOwner(layers []*Layer) []OwnerStat {
+	type owner struct {
+		uid, gid     int
+		uname, gname string
+	}
+	groups := make(map[owner]*OwnerStat)
+	for _, l := range layers {
+		for _, f := range l.Files {
+			key := owner{f.Uid, f.Gid, f.Uname, f.Gname}
+			g, ok := groups[key]
+			if !ok {
+				g = &OwnerStat{UID: f.Uid, GID: f.Gid, Uname: f.Uname, Gname: f.Gname}
+				groups[key] = g
+			}
+			g.Count++
+			g.Size += uint64(f.Size)
+		}
+	}
+	stats := make([]OwnerStat, 0, len(groups))
+	for _, g := range groups {
+		stats = append(stats, *g)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		a, b := stats[i], stats[j]
+		switch {
+		case a.Size != b.Size:
+			return a.Size > b.Size
+		case a.UID != b.UID:
+			return a.UID < b.UID
+		case a.GID != b.GID:
+			return a.GID < b.GID
+		case a.Uname != b.Uname:
+			return a.Uname < b.Uname
+		}
+		return a.Gname < b.Gname
+	})
+	return stats
+}
@@ -0,0 +1,42 @@
+package dolay
+
+import (
+	"reflect"
+	"testing"
+)
+
+// owned returns entry of the regular file of the owner
+func owned(name string, size, uid, gid int, uname, gname string) tarEntry {
+	e := regular(name, size)
+	e.Uid, e.Gid, e.Uname, e.Gname = uid, gid, uname, gname
+	return e
+}
+
+func TestByOwner(t *testing.T) {
+	layers := []*Layer{
+		testLayer(0, "ADD rootfs",
+			owned("bin/busybox", 900, 0, 0, "root", "root"),
+			owned("etc/passwd", 100, 0, 0, "root", "root")),
+		testLayer(1, "COPY --chown=1000:1000 . /app",
+			owned("app/main", 300, 1000, 1000, "app", "app"),
+			owned("app/data.db", 2000, 1000, 1000, "app", "app"),
+			owned("app/log", 50, 1000, 100, "app", "users")),
+		testLayer(2, "RUN chown", owned("etc/passwd", 120, 0, 0, "root", "root")),
+	}
+	want := []OwnerStat{
+		{UID: 1000, GID: 1000, Uname: "app", Gname: "app", Count: 2, Size: 2300},
+		{UID: 0, GID: 0, Uname: "root", Gname: "root", Count: 3, Size: 1120},
+		{UID: 1000, GID: 100, Uname: "app", Gname: "users", Count: 1, Size: 50},
+	}
+	if got := ByOwner(layers); !reflect.DeepEqual(got, want) {
+		t.Errorf("ByOwner() = %+v, want %+v", got, want)
+	}
+	// owners of the same size are sorted by ids
+	tied := []*Layer{testLayer(0, "", owned("b", 10, 1000, 0, "", ""), owned("a", 10, 0, 0, "", ""))}
+	if got := ByOwner(tied); len(got) != 2 || got[0].UID != 0 || got[1].UID != 1000 {
+		t.Errorf("ByOwner() of the same sizes = %+v", got)
+	}
+	if got := ByOwner(nil); got == nil || len(got) != 0 {
+		t.Errorf("ByOwner(nil) = %#v, want empty slice", got)
+	}
+}
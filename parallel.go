@@ -0,0 +1,109 @@
+package dolay
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// layerResult defines result of the layer decoding
+type layerResult struct {
+	name  string
+	layer *Layer
+	err   error
+}
+
+// decoder provides decoding of layers by the pool of workers.
+// Content of the layer is read into memory by the caller, so at most
+// jobs layers are buffered, and decoded layers are passed to apply
+// at the caller's goroutine, so apply doesn't need synchronization
+type decoder struct {
+	sem      chan struct{}
+	results  chan layerResult
+	done     chan struct{}
+	inflight int
+	apply    func(name string, layer *Layer) error
+	// opts are options of reading of layers
+	opts layerOptions
+}
+
+func newDecoder(jobs int, opts layerOptions, apply func(name string, layer *Layer) error) *decoder {
+	return &decoder{
+		opts:    opts,
+		sem:     make(chan struct{}, jobs),
+		results: make(chan layerResult, jobs),
+		done:    make(chan struct{}),
+		apply:   apply,
+	}
+}
+
+// decode provides reading of the layer content and passing of it
+// to the worker. While all workers are busy it applies results
+func (d *decoder) decode(name string, r io.Reader) error {
+	for {
+		select {
+		case d.sem <- struct{}{}:
+			data, err := io.ReadAll(r)
+			if err != nil {
+				<-d.sem
+				return err
+			}
+			d.inflight++
+			go d.work(name, data)
+			return nil
+		case res := <-d.results:
+			if err := d.receive(res); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (d *decoder) work(name string, data []byte) {
+	layer, err := readLayer(bytes.NewReader(data), d.opts)
+	if err != nil {
+		err = fmt.Errorf("%w %s: %v", ErrCorruptLayer, name, err)
+	}
+	<-d.sem
+	select {
+	case d.results <- layerResult{name: name, layer: layer, err: err}:
+	case <-d.done:
+	}
+}
+
+func (d *decoder) receive(res layerResult) error {
+	d.inflight--
+	if res.err != nil {
+		return res.err
+	}
+	return d.apply(res.name, res.layer)
+}
+
+// poll provides applying of decoded layers without blocking
+func (d *decoder) poll() error {
+	for {
+		select {
+		case res := <-d.results:
+			if err := d.receive(res); err != nil {
+				return err
+			}
+		default:
+			return nil
+		}
+	}
+}
+
+// wait provides applying of all layers in flight
+func (d *decoder) wait() error {
+	for d.inflight > 0 {
+		if err := d.receive(<-d.results); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// close releases workers which are still in flight
+func (d *decoder) close() {
+	close(d.done)
+}
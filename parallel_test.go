@@ -0,0 +1,35 @@
+package dolay
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func BenchmarkAnalyzeJobs(b *testing.B) {
+	rnd := rand.New(rand.NewSource(1))
+	words := []string{"layer ", "image ", "docker ", "file ", "size ", "tar ", "gzip ", "config "}
+	var layers [][]byte
+	for i := 0; i < 8; i++ {
+		var entries []tarEntry
+		for j := 0; j < 64; j++ {
+			var body bytes.Buffer
+			for body.Len() < 64<<10 {
+				body.WriteString(words[rnd.Intn(len(words))])
+			}
+			entries = append(entries, file(fmt.Sprintf("usr/lib/%d/%d.txt", i, j), body.Bytes()))
+		}
+		layers = append(layers, gzipData(b, buildTar(b, entries...)))
+	}
+	archive := testImage{Layers: layers}.docker(b)
+	for _, jobs := range []int{1, 4} {
+		b.Run(fmt.Sprintf("jobs %d", jobs), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := AnalyzeWithOptions(bytes.NewReader(archive), Options{Jobs: jobs}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
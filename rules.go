@@ -0,0 +1,106 @@
+package dolay
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/dustin/go-humanize"
+)
+
+// PolicyRule defines custom check of files of the image filesystem,
+// like "no source maps" or "no keys of ssh"
+type PolicyRule struct {
+	Name string `json:"name"`
+	// Paths are glob patterns of paths of files, like "**/*.map".
+	// Pattern of the directory matches all files inside of it
+	Paths []string `json:"paths"`
+	// MinSize reports only files of the size or larger, like "10MB".
+	// Files of any size are reported if it's empty
+	MinSize string `json:"min_size,omitempty"`
+	Message string `json:"message"`
+}
+
+// Violation defines file which breaks the rule
+type Violation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+	Name    string `json:"name"`
+	Size    int64  `json:"size"`
+}
+
+// rulesFile defines document with rules
+type rulesFile struct {
+	Rules []PolicyRule `json:"rules"`
+}
+
+// LoadRules returns rules from JSON document like
+// {"rules": [{"name": "maps", "paths": ["**/*.map"], "message": "..."}]}
+func LoadRules(r io.Reader) ([]PolicyRule, error) {
+	var doc rulesFile
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("unable to decode rules: %v", err)
+	}
+	for _, rule := range doc.Rules {
+		if _, _, err := rule.compile(); err != nil {
+			return nil, err
+		}
+	}
+	return doc.Rules, nil
+}
+
+// compile returns filter of paths and the size threshold of the rule
+func (rule PolicyRule) compile() (*PathFilter, uint64, error) {
+	if rule.Name == "" || len(rule.Paths) == 0 {
+		return nil, 0, fmt.Errorf("rule %q requires name and paths", rule.Name)
+	}
+	patterns := make([]string, 0, len(rule.Paths))
+	for _, p := range rule.Paths {
+		patterns = append(patterns, strings.TrimPrefix(p, "/"))
+	}
+	filter, err := NewPathFilter(patterns, nil, false)
+	if err != nil {
+		return nil, 0, fmt.Errorf("rule %s: %v", rule.Name, err)
+	}
+	var size uint64
+	if rule.MinSize != "" {
+		if size, err = humanize.ParseBytes(rule.MinSize); err != nil {
+			return nil, 0, fmt.Errorf("rule %s: invalid min size: %v", rule.Name, err)
+		}
+	}
+	return filter, size, nil
+}
+
+// matchTree returns true if the path or any of its directories passes the filter
+func matchTree(filter *PathFilter, name string) bool {
+	for p := name; p != "." && p != "/"; p = path.Dir(p) {
+		if filter.Match(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// Check returns violations of rules by files of the filesystem composed
+// from layers, in order of rules and of paths
+func Check(layers []*Layer, rules []PolicyRule) ([]Violation, error) {
+	files := Merge(layers)
+	violations := []Violation{}
+	for _, rule := range rules {
+		filter, minSize, err := rule.compile()
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range files {
+			name := cleanPath(f.Name)
+			if uint64(f.Size) >= minSize && matchTree(filter, name) {
+				violations = append(violations, Violation{Rule: rule.Name, Message: rule.Message, Name: name, Size: f.Size})
+			}
+		}
+	}
+	return violations, nil
+}
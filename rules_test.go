@@ -0,0 +1,84 @@
+package dolay
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadRules(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		want int
+		err  string
+	}{
+		{"rules", `{"rules": [{"name": "maps", "paths": ["**/*.map"], "message": "no maps"}, {"name": "big", "paths": ["**/*.so"], "min_size": "1MB"}]}`, 2, ""},
+		{"empty", `{"rules": []}`, 0, ""},
+		{"unknown key", `{"rules": [{"name": "maps", "paths": ["*.map"], "severity": "high"}]}`, 0, "unable to decode rules"},
+		{"no paths", `{"rules": [{"name": "maps"}]}`, 0, `rule "maps" requires name and paths`},
+		{"no name", `{"rules": [{"paths": ["*.map"]}]}`, 0, `rule "" requires name and paths`},
+		{"invalid size", `{"rules": [{"name": "big", "paths": ["*.so"], "min_size": "big"}]}`, 0, "rule big: invalid min size"},
+		{"not json", `rules: []`, 0, "unable to decode rules"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := LoadRules(strings.NewReader(tt.doc))
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("LoadRules() error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadRules() error = %v", err)
+			}
+			if len(rules) != tt.want {
+				t.Errorf("LoadRules() = %d rules, want %d", len(rules), tt.want)
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	rules, err := LoadRules(strings.NewReader(`{"rules": [
+		{"name": "source-maps", "paths": ["**/*.map"], "message": "don't ship source maps"},
+		{"name": "ssh", "paths": ["/root/.ssh"], "message": "keys of ssh in the image"},
+		{"name": "big-libs", "paths": ["**/*.so"], "min_size": "1kB", "message": "large library"}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	layers := []*Layer{
+		testLayer(0, "ADD rootfs",
+			regular("usr/lib/libc.so", 2000),
+			regular("usr/lib/libz.so", 100),
+			regular("root/.ssh/id_rsa", 400),
+			regular("root/.sshrc", 10)),
+		testLayer(1, "COPY dist /app",
+			regular("app/main.js", 500),
+			regular("app/main.js.map", 300),
+			regular("app/vendor/lib.js.map", 200)),
+		testLayer(2, "RUN rm -rf /root/.ssh", regular("root/.wh..ssh", 0)),
+	}
+	want := []Violation{
+		{Rule: "source-maps", Message: "don't ship source maps", Name: "app/main.js.map", Size: 300},
+		{Rule: "source-maps", Message: "don't ship source maps", Name: "app/vendor/lib.js.map", Size: 200},
+		{Rule: "big-libs", Message: "large library", Name: "usr/lib/libc.so", Size: 2000},
+	}
+	got, err := Check(layers, rules)
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Check() = %+v, want %+v", got, want)
+	}
+	// keys of ssh are reported until they're deleted
+	got, err = Check(layers[:2], rules[1:2])
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if len(got) != 1 || got[0].Name != "root/.ssh/id_rsa" {
+		t.Errorf("Check() = %+v, want root/.ssh/id_rsa", got)
+	}
+}
@@ -0,0 +1,40 @@
+package dolay
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SelectByIndex returns selector of the image by index in the manifest
+func SelectByIndex(index int) func([]ManifestItem) (int, error) {
+	return func(manifests []ManifestItem) (int, error) {
+		if index < 0 || index >= len(manifests) {
+			return 0, fmt.Errorf("%w: index %d is out of range, archive contains %d images", ErrImageNotFound, index, len(manifests))
+		}
+		return index, nil
+	}
+}
+
+// SelectByRepoTag returns selector of the image by repo tag.
+// Tag without version matches the "latest" one
+func SelectByRepoTag(tag string) func([]ManifestItem) (int, error) {
+	return func(manifests []ManifestItem) (int, error) {
+		for i, m := range manifests {
+			for _, t := range m.RepoTags {
+				if t == tag || t == tag+":latest" {
+					return i, nil
+				}
+			}
+		}
+		return 0, fmt.Errorf("%w: %s, available tags: %s", ErrImageNotFound, tag, strings.Join(RepoTags(manifests), ", "))
+	}
+}
+
+// RepoTags returns all repo tags of manifests
+func RepoTags(manifests []ManifestItem) []string {
+	var tags []string
+	for _, m := range manifests {
+		tags = append(tags, m.RepoTags...)
+	}
+	return tags
+}
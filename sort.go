@@ -0,0 +1,72 @@
+package dolay
+
+import (
+	"archive/tar"
+	"fmt"
+	"sort"
+)
+
+// Sort keys of the files
+const (
+	SortBySize  = "size"
+	SortByName  = "name"
+	SortByMtime = "mtime"
+	SortByType  = "type"
+)
+
+// FileOrder defines comparison function of the files
+type FileOrder func(a, b *tar.Header) bool
+
+// orders contains orders of the files by sort keys.
+// Size and mtime are sorted from the largest and the newest
+var orders = map[string]FileOrder{
+	SortBySize: func(a, b *tar.Header) bool {
+		return Files{a, b}.Less(0, 1)
+	},
+	SortByName: func(a, b *tar.Header) bool {
+		return a.Name < b.Name
+	},
+	SortByMtime: func(a, b *tar.Header) bool {
+		if !a.ModTime.Equal(b.ModTime) {
+			return a.ModTime.After(b.ModTime)
+		}
+		return a.Name < b.Name
+	},
+	SortByType: func(a, b *tar.Header) bool {
+		if a.Typeflag != b.Typeflag {
+			return a.Typeflag < b.Typeflag
+		}
+		return Files{a, b}.Less(0, 1)
+	},
+}
+
+// OrderBy returns order of the files by the sort key
+func OrderBy(key string) (FileOrder, error) {
+	order, ok := orders[key]
+	if !ok {
+		return nil, fmt.Errorf("unknown sort key: %s", key)
+	}
+	return order, nil
+}
+
+// filesBy implements sorting of files in the order
+type filesBy struct {
+	Files
+	order FileOrder
+}
+
+// Less provides compare of two files in the order
+func (s filesBy) Less(i, j int) bool {
+	return s.order(s.Files[i], s.Files[j])
+}
+
+// SortBy provides sorting of files in the order. Sorting is stable,
+// so files from several layers with the same path and size keep
+// order of layers, and the output is the same on every run
+func (s Files) SortBy(order FileOrder, reverse bool) {
+	var data sort.Interface = filesBy{s, order}
+	if reverse {
+		data = sort.Reverse(data)
+	}
+	sort.Stable(data)
+}
@@ -0,0 +1,53 @@
+package dolay
+
+import (
+	"archive/tar"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSortBy(t *testing.T) {
+	header := func(name string, typeflag byte, size int64, hours int) *tar.Header {
+		return &tar.Header{Name: name, Typeflag: typeflag, Size: size, ModTime: testTime.Add(time.Duration(hours) * time.Hour)}
+	}
+	files := Files{
+		header("usr/lib/libc.so", tar.TypeReg, 300, 1),
+		header("bin/sh", tar.TypeSymlink, 0, 3),
+		header("bin/busybox", tar.TypeReg, 900, 2),
+		header("etc/passwd", tar.TypeReg, 300, 0),
+	}
+	tests := []struct {
+		key     string
+		reverse bool
+		want    []string
+	}{
+		{SortBySize, false, []string{"bin/busybox", "etc/passwd", "usr/lib/libc.so", "bin/sh"}},
+		{SortBySize, true, []string{"bin/sh", "usr/lib/libc.so", "etc/passwd", "bin/busybox"}},
+		{SortByName, false, []string{"bin/busybox", "bin/sh", "etc/passwd", "usr/lib/libc.so"}},
+		{SortByName, true, []string{"usr/lib/libc.so", "etc/passwd", "bin/sh", "bin/busybox"}},
+		{SortByMtime, false, []string{"bin/sh", "bin/busybox", "usr/lib/libc.so", "etc/passwd"}},
+		{SortByMtime, true, []string{"etc/passwd", "usr/lib/libc.so", "bin/busybox", "bin/sh"}},
+		{SortByType, false, []string{"bin/busybox", "etc/passwd", "usr/lib/libc.so", "bin/sh"}},
+	}
+	for _, tt := range tests {
+		name := tt.key
+		if tt.reverse {
+			name += " reverse"
+		}
+		t.Run(name, func(t *testing.T) {
+			order, err := OrderBy(tt.key)
+			if err != nil {
+				t.Fatalf("OrderBy(%s) error = %v", tt.key, err)
+			}
+			sorted := append(Files(nil), files...)
+			sorted.SortBy(order, tt.reverse)
+			if got := names(sorted); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SortBy(%s) = %v, want %v", name, got, tt.want)
+			}
+		})
+	}
+	if _, err := OrderBy("owner"); err == nil {
+		t.Errorf("OrderBy(owner) error = nil, want unknown sort key")
+	}
+}
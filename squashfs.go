@@ -0,0 +1,482 @@
+package dolay
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"path"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// squashfsMagic defines first bytes of the squashfs superblock
+var squashfsMagic = []byte("hsqs")
+
+// sifMagic defines magic of the SIF image of Singularity/Apptainer,
+// which follows the launch script at the start of the image
+var sifMagic = []byte("SIF_MAGIC")
+
+const (
+	// sifMagicOffset is offset of the magic in the SIF header
+	sifMagicOffset = 32
+	// metadataSize is size of the uncompressed metadata block
+	metadataSize = 8192
+	// metadataUncompressed marks the metadata block stored as is
+	metadataUncompressed = 0x8000
+	// sifScanChunk is size of chunks of the SIF image
+	// which are scanned for the squashfs superblock
+	sifScanChunk = 1 << 20
+	// maxSymlinkSize limits targets of symlinks like PATH_MAX,
+	// so the size of the broken image doesn't allocate memory
+	maxSymlinkSize = 4096
+)
+
+// Compressions of squashfs
+const (
+	squashfsGzip = 1
+	squashfsXZ   = 4
+	squashfsZstd = 6
+)
+
+// Types of squashfs inodes. Extended types are basic ones plus 7
+const (
+	inodeDir = iota + 1
+	inodeFile
+	inodeSymlink
+	inodeBlockDev
+	inodeCharDev
+	inodeFifo
+	inodeSocket
+	inodeExtended = 7
+)
+
+// squashfsSuperblock defines superblock of squashfs 4.0
+type squashfsSuperblock struct {
+	Magic          [4]byte
+	InodeCount     uint32
+	ModTime        uint32
+	BlockSize      uint32
+	FragmentCount  uint32
+	Compression    uint16
+	BlockLog       uint16
+	Flags          uint16
+	IDCount        uint16
+	VersionMajor   uint16
+	VersionMinor   uint16
+	RootInode      uint64
+	BytesUsed      uint64
+	IDTable        uint64
+	XattrTable     uint64
+	InodeTable     uint64
+	DirectoryTable uint64
+	FragmentTable  uint64
+	ExportTable    uint64
+}
+
+// squashfsInode defines header common for all inodes
+type squashfsInode struct {
+	Type        uint16
+	Permissions uint16
+	UIDIndex    uint16
+	GIDIndex    uint16
+	ModTime     uint32
+	Number      uint32
+}
+
+// squashfsDirHeader defines header of the run of directory entries
+type squashfsDirHeader struct {
+	Count  uint32
+	Start  uint32
+	Number uint32
+}
+
+// squashfsDirEntry defines entry of the directory
+type squashfsDirEntry struct {
+	Offset   uint16
+	Delta    int16
+	Type     uint16
+	NameSize uint16
+}
+
+// squashfs defines image being read
+type squashfs struct {
+	r      io.ReaderAt
+	base   int64
+	sb     squashfsSuperblock
+	decode func([]byte) ([]byte, error)
+	ids    []uint32
+	// files contains names of regular files by inode number,
+	// so next entries of the same inode are hardlinks
+	files map[uint32]string
+	layer *Layer
+}
+
+// metadata provides reading of the metadata table, which is
+// the sequence of blocks compressed separately
+type metadata struct {
+	fs    *squashfs
+	start int64
+	next  int64
+	buf   []byte
+}
+
+// ReadSquashfs provides reading of names and sizes of files of the
+// squashfs image, or of the first squashfs partition of the SIF image
+// of Singularity/Apptainer. Content of files isn't read, and the image
+// is returned as the single layer. Images compressed with gzip, xz or
+// zstd are supported
+func ReadSquashfs(r io.ReaderAt) (*Layer, error) {
+	start := time.Now()
+	base, err := findSquashfs(r)
+	if err != nil {
+		return nil, err
+	}
+	fs := &squashfs{r: r, base: base, files: make(map[uint32]string), layer: &Layer{}}
+	if err := binary.Read(io.NewSectionReader(r, base, 96), binary.LittleEndian, &fs.sb); err != nil {
+		return nil, fmt.Errorf("%w: unable to read superblock: %v", ErrCorruptArchive, err)
+	}
+	if fs.sb.VersionMajor != 4 {
+		return nil, fmt.Errorf("%w: squashfs %d.%d", ErrUnsupportedFormat, fs.sb.VersionMajor, fs.sb.VersionMinor)
+	}
+	if fs.decode, err = squashfsDecoder(fs.sb.Compression); err != nil {
+		return nil, err
+	}
+	if err := fs.readIDs(); err != nil {
+		return nil, fmt.Errorf("%w: unable to read ids: %v", ErrCorruptArchive, err)
+	}
+	if err := fs.walk(fs.sb.RootInode, "", make(map[uint64]bool)); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCorruptArchive, err)
+	}
+	fs.layer.Count = len(fs.layer.Files)
+	fs.layer.DecodeTime = time.Since(start)
+	return fs.layer, nil
+}
+
+// findSquashfs returns offset of the squashfs superblock, which is
+// at the start of the squashfs image or in the data of the SIF image
+func findSquashfs(r io.ReaderAt) (int64, error) {
+	head := make([]byte, sifMagicOffset+len(sifMagic))
+	n, err := r.ReadAt(head, 0)
+	if err != nil && err != io.EOF {
+		return 0, err
+	}
+	head = head[:n]
+	switch {
+	case bytes.HasPrefix(head, squashfsMagic):
+		return 0, nil
+	case len(head) == cap(head) && bytes.Equal(head[sifMagicOffset:], sifMagic):
+		return scanSquashfs(r)
+	}
+	return 0, fmt.Errorf("%w: neither squashfs nor SIF image", ErrUnsupportedFormat)
+}
+
+// scanSquashfs returns offset of the first squashfs superblock
+// of version 4.0 in the SIF image
+func scanSquashfs(r io.ReaderAt) (int64, error) {
+	buf := make([]byte, sifScanChunk+len(squashfsMagic))
+	for off := int64(0); ; off += sifScanChunk {
+		n, err := r.ReadAt(buf, off)
+		data := buf[:n]
+		for i := 0; ; {
+			j := bytes.Index(data[i:], squashfsMagic)
+			if j < 0 {
+				break
+			}
+			i += j
+			// superblock is checked by the version
+			version := make([]byte, 4)
+			if _, err := r.ReadAt(version, off+int64(i)+28); err == nil &&
+				binary.LittleEndian.Uint16(version) == 4 && binary.LittleEndian.Uint16(version[2:]) == 0 {
+				return off + int64(i), nil
+			}
+			i++
+		}
+		if err == io.EOF || n < len(buf) {
+			return 0, fmt.Errorf("%w: SIF image has no squashfs partition", ErrUnsupportedFormat)
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+}
+
+// squashfsDecoder returns decompression of metadata blocks
+func squashfsDecoder(compression uint16) (func([]byte) ([]byte, error), error) {
+	switch compression {
+	case squashfsGzip:
+		return func(data []byte) ([]byte, error) {
+			zr, err := zlib.NewReader(bytes.NewReader(data))
+			if err != nil {
+				return nil, err
+			}
+			defer zr.Close()
+			return io.ReadAll(zr)
+		}, nil
+	case squashfsXZ:
+		return func(data []byte) ([]byte, error) {
+			xr, err := xz.NewReader(bytes.NewReader(data))
+			if err != nil {
+				return nil, err
+			}
+			return io.ReadAll(xr)
+		}, nil
+	case squashfsZstd:
+		dec, err := zstd.NewReader(nil, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		return func(data []byte) ([]byte, error) {
+			return dec.DecodeAll(data, make([]byte, 0, metadataSize))
+		}, nil
+	}
+	return nil, fmt.Errorf("%w: squashfs compression %d", ErrUnsupportedFormat, compression)
+}
+
+// table returns reader of the metadata table from the block
+// at the offset relative to the table start
+func (fs *squashfs) table(start uint64, block int64, offset int) (*metadata, error) {
+	m := &metadata{fs: fs, start: fs.base + int64(start), next: block}
+	if err := m.load(); err != nil {
+		return nil, err
+	}
+	if offset > len(m.buf) {
+		return nil, fmt.Errorf("offset %d is out of the metadata block", offset)
+	}
+	m.buf = m.buf[offset:]
+	return m, nil
+}
+
+// load provides reading of the next metadata block
+func (m *metadata) load() error {
+	pos := m.start + m.next
+	if pos+2 > m.fs.base+int64(m.fs.sb.BytesUsed) {
+		return io.ErrUnexpectedEOF
+	}
+	var header [2]byte
+	if _, err := m.fs.r.ReadAt(header[:], pos); err != nil {
+		return err
+	}
+	h := binary.LittleEndian.Uint16(header[:])
+	size := int(h &^ metadataUncompressed)
+	if size == 0 || size > metadataSize {
+		return fmt.Errorf("invalid metadata block at %d", pos)
+	}
+	data := make([]byte, size)
+	if _, err := m.fs.r.ReadAt(data, pos+2); err != nil {
+		return err
+	}
+	if h&metadataUncompressed == 0 {
+		var err error
+		if data, err = m.fs.decode(data); err != nil {
+			return fmt.Errorf("unable to decompress metadata block at %d: %v", pos, err)
+		}
+	}
+	m.buf = data
+	m.next += int64(2 + size)
+	return nil
+}
+
+// Read provides reading of the table across metadata blocks
+func (m *metadata) Read(p []byte) (int, error) {
+	if len(m.buf) == 0 {
+		if err := m.load(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, m.buf)
+	m.buf = m.buf[n:]
+	return n, nil
+}
+
+// readIDs provides reading of the table of uid and gid values
+func (fs *squashfs) readIDs() error {
+	if fs.sb.IDCount == 0 {
+		return nil
+	}
+	var first uint64
+	if err := binary.Read(io.NewSectionReader(fs.r, fs.base+int64(fs.sb.IDTable), 8), binary.LittleEndian, &first); err != nil {
+		return err
+	}
+	m, err := fs.table(first, 0, 0)
+	if err != nil {
+		return err
+	}
+	fs.ids = make([]uint32, fs.sb.IDCount)
+	return binary.Read(m, binary.LittleEndian, fs.ids)
+}
+
+// id returns uid or gid by the index of the table
+func (fs *squashfs) id(index uint16) int {
+	if int(index) < len(fs.ids) {
+		return int(fs.ids[index])
+	}
+	return 0
+}
+
+// walk provides adding of entries of the directory by the inode
+// reference. Visited directories are skipped, so loops of the
+// broken image don't recurse
+func (fs *squashfs) walk(ref uint64, dir string, visited map[uint64]bool) error {
+	if visited[ref] {
+		return nil
+	}
+	visited[ref] = true
+	m, err := fs.table(fs.sb.InodeTable, int64(ref>>16), int(ref&0xffff))
+	if err != nil {
+		return err
+	}
+	var inode squashfsInode
+	if err := binary.Read(m, binary.LittleEndian, &inode); err != nil {
+		return err
+	}
+	var block uint32
+	var size uint32
+	var offset uint16
+	switch inode.Type {
+	case inodeDir:
+		var d struct {
+			Block  uint32
+			Links  uint32
+			Size   uint16
+			Offset uint16
+			Parent uint32
+		}
+		if err := binary.Read(m, binary.LittleEndian, &d); err != nil {
+			return err
+		}
+		block, size, offset = d.Block, uint32(d.Size), d.Offset
+	case inodeDir + inodeExtended:
+		var d struct {
+			Links  uint32
+			Size   uint32
+			Block  uint32
+			Parent uint32
+			Index  uint16
+			Offset uint16
+			Xattr  uint32
+		}
+		if err := binary.Read(m, binary.LittleEndian, &d); err != nil {
+			return err
+		}
+		block, size, offset = d.Block, d.Size, d.Offset
+	default:
+		return fmt.Errorf("inode %d of %s is not a directory", inode.Number, dir)
+	}
+	// size counts "." and ".." entries, which are not stored
+	if size <= 3 {
+		return nil
+	}
+	entries, err := fs.table(fs.sb.DirectoryTable, int64(block), int(offset))
+	if err != nil {
+		return err
+	}
+	r := io.LimitReader(entries, int64(size-3))
+	for {
+		var h squashfsDirHeader
+		if err := binary.Read(r, binary.LittleEndian, &h); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		for i := uint32(0); i <= h.Count; i++ {
+			var e squashfsDirEntry
+			if err := binary.Read(r, binary.LittleEndian, &e); err != nil {
+				return err
+			}
+			name := make([]byte, int(e.NameSize)+1)
+			if _, err := io.ReadFull(r, name); err != nil {
+				return err
+			}
+			ref := uint64(h.Start)<<16 | uint64(e.Offset)
+			if err := fs.entry(ref, path.Join(dir, string(name)), visited); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// entry provides adding of the entry by the inode reference
+func (fs *squashfs) entry(ref uint64, name string, visited map[uint64]bool) error {
+	m, err := fs.table(fs.sb.InodeTable, int64(ref>>16), int(ref&0xffff))
+	if err != nil {
+		return err
+	}
+	var inode squashfsInode
+	if err := binary.Read(m, binary.LittleEndian, &inode); err != nil {
+		return err
+	}
+	h := &tar.Header{
+		Name:    name,
+		Mode:    int64(inode.Permissions),
+		Uid:     fs.id(inode.UIDIndex),
+		Gid:     fs.id(inode.GIDIndex),
+		ModTime: time.Unix(int64(inode.ModTime), 0),
+	}
+	switch inode.Type {
+	case inodeDir, inodeDir + inodeExtended:
+		fs.layer.Dirs++
+		return fs.walk(ref, name, visited)
+	case inodeFile, inodeFile + inodeExtended:
+		if inode.Type == inodeFile {
+			var f struct {
+				Blocks   uint32
+				Fragment uint32
+				Offset   uint32
+				Size     uint32
+			}
+			if err := binary.Read(m, binary.LittleEndian, &f); err != nil {
+				return err
+			}
+			h.Size = int64(f.Size)
+		} else {
+			var f struct {
+				Blocks uint64
+				Size   uint64
+			}
+			if err := binary.Read(m, binary.LittleEndian, &f); err != nil {
+				return err
+			}
+			h.Size = int64(f.Size)
+		}
+		h.Typeflag = tar.TypeReg
+		// inode shared by entries is the hardlink,
+		// so its content is counted once
+		if target, ok := fs.files[inode.Number]; ok {
+			h.Typeflag, h.Linkname, h.Size = tar.TypeLink, target, 0
+		} else {
+			fs.files[inode.Number] = name
+		}
+	case inodeSymlink, inodeSymlink + inodeExtended:
+		var s struct {
+			Links uint32
+			Size  uint32
+		}
+		if err := binary.Read(m, binary.LittleEndian, &s); err != nil {
+			return err
+		}
+		if s.Size > maxSymlinkSize {
+			return fmt.Errorf("target of symlink %s is %d bytes, which exceeds %d", name, s.Size, maxSymlinkSize)
+		}
+		target := make([]byte, s.Size)
+		if _, err := io.ReadFull(m, target); err != nil {
+			return fmt.Errorf("target of symlink %s is out of the inode table: %v", name, err)
+		}
+		h.Typeflag, h.Linkname = tar.TypeSymlink, string(target)
+	case inodeBlockDev, inodeBlockDev + inodeExtended:
+		h.Typeflag = tar.TypeBlock
+	case inodeCharDev, inodeCharDev + inodeExtended:
+		h.Typeflag = tar.TypeChar
+	case inodeFifo, inodeFifo + inodeExtended, inodeSocket, inodeSocket + inodeExtended:
+		h.Typeflag = tar.TypeFifo
+	default:
+		return fmt.Errorf("unknown type %d of inode %s", inode.Type, name)
+	}
+	fs.layer.Files = append(fs.layer.Files, h)
+	fs.layer.Size += uint64(h.Size)
+	return nil
+}
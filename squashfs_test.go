@@ -0,0 +1,290 @@
+package dolay
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"sort"
+	"testing"
+)
+
+// sqfsEntry defines entry of the generated squashfs image
+type sqfsEntry struct {
+	name     string
+	mode     uint16
+	uid      uint32
+	size     uint32
+	target   string
+	linkSize uint32
+	// link is name of the file in the same directory,
+	// which inode is shared with the entry
+	link     string
+	children []sqfsEntry
+	dir      bool
+}
+
+// sqfsWriter provides writing of the squashfs image with the single
+// inode table block and the single directory table block. Content
+// of files is not written, since only metadata is read
+type sqfsWriter struct {
+	inodes   bytes.Buffer
+	dirs     bytes.Buffer
+	ids      []uint32
+	next     uint32
+	compress bool
+}
+
+// id returns index of the uid in the id table
+func (w *sqfsWriter) id(uid uint32) uint16 {
+	for i, v := range w.ids {
+		if v == uid {
+			return uint16(i)
+		}
+	}
+	w.ids = append(w.ids, uid)
+	return uint16(len(w.ids) - 1)
+}
+
+// inode provides writing of the entry and returns its offset
+// in the inode table with its number. Children of directories
+// are written before the directory
+func (w *sqfsWriter) inode(e sqfsEntry) (uint16, uint32) {
+	type child struct {
+		name   string
+		offset uint16
+		number uint32
+		kind   uint16
+	}
+	var children []child
+	if e.dir {
+		written := make(map[string]child)
+		for _, c := range e.children {
+			if c.link != "" {
+				continue
+			}
+			offset, number := w.inode(c)
+			kind := uint16(inodeFile)
+			switch {
+			case c.dir:
+				kind = inodeDir
+			case c.target != "" || c.linkSize > 0:
+				kind = inodeSymlink
+			}
+			written[c.name] = child{name: c.name, offset: offset, number: number, kind: kind}
+			children = append(children, written[c.name])
+		}
+		for _, c := range e.children {
+			if c.link != "" {
+				target := written[c.link]
+				target.name = c.name
+				children = append(children, target)
+			}
+		}
+		sort.Slice(children, func(i, j int) bool { return children[i].name < children[j].name })
+	}
+
+	w.next++
+	number := w.next
+	offset := uint16(w.inodes.Len())
+	header := squashfsInode{Permissions: e.mode, UIDIndex: w.id(e.uid), GIDIndex: w.id(e.uid), ModTime: 1600000000, Number: number}
+	switch {
+	case e.dir:
+		start := w.dirs.Len()
+		if len(children) > 0 {
+			binary.Write(&w.dirs, binary.LittleEndian, squashfsDirHeader{Count: uint32(len(children) - 1), Number: children[0].number})
+			for _, c := range children {
+				binary.Write(&w.dirs, binary.LittleEndian, squashfsDirEntry{
+					Offset:   c.offset,
+					Delta:    int16(c.number) - int16(children[0].number),
+					Type:     c.kind,
+					NameSize: uint16(len(c.name) - 1),
+				})
+				w.dirs.WriteString(c.name)
+			}
+		}
+		header.Type = inodeDir
+		binary.Write(&w.inodes, binary.LittleEndian, header)
+		binary.Write(&w.inodes, binary.LittleEndian, struct {
+			Block  uint32
+			Links  uint32
+			Size   uint16
+			Offset uint16
+			Parent uint32
+		}{Links: 2, Size: uint16(w.dirs.Len() - start + 3), Offset: uint16(start)})
+	case e.target != "" || e.linkSize > 0:
+		size := e.linkSize
+		if size == 0 {
+			size = uint32(len(e.target))
+		}
+		header.Type = inodeSymlink
+		binary.Write(&w.inodes, binary.LittleEndian, header)
+		binary.Write(&w.inodes, binary.LittleEndian, struct {
+			Links uint32
+			Size  uint32
+		}{1, size})
+		w.inodes.WriteString(e.target)
+	default:
+		header.Type = inodeFile
+		binary.Write(&w.inodes, binary.LittleEndian, header)
+		binary.Write(&w.inodes, binary.LittleEndian, struct {
+			Blocks   uint32
+			Fragment uint32
+			Offset   uint32
+			Size     uint32
+		}{Fragment: 0xffffffff, Size: e.size})
+		binary.Write(&w.inodes, binary.LittleEndian, uint32(e.size)|1<<24)
+	}
+	return offset, number
+}
+
+// block returns the metadata block with its header
+func (w *sqfsWriter) block(data []byte) []byte {
+	var out bytes.Buffer
+	if !w.compress {
+		binary.Write(&out, binary.LittleEndian, uint16(len(data))|metadataUncompressed)
+		out.Write(data)
+		return out.Bytes()
+	}
+	var z bytes.Buffer
+	zw := zlib.NewWriter(&z)
+	zw.Write(data)
+	zw.Close()
+	binary.Write(&out, binary.LittleEndian, uint16(z.Len()))
+	out.Write(z.Bytes())
+	return out.Bytes()
+}
+
+// buildSquashfs returns squashfs image with the root directory
+func buildSquashfs(root sqfsEntry, compress bool) []byte {
+	w := &sqfsWriter{compress: compress}
+	root.dir = true
+	rootOffset, _ := w.inode(root)
+	sb := squashfsSuperblock{
+		Magic:         [4]byte{'h', 's', 'q', 's'},
+		InodeCount:    w.next,
+		BlockSize:     131072,
+		Compression:   squashfsGzip,
+		BlockLog:      17,
+		IDCount:       uint16(len(w.ids)),
+		VersionMajor:  4,
+		RootInode:     uint64(rootOffset),
+		XattrTable:    ^uint64(0),
+		FragmentTable: ^uint64(0),
+		ExportTable:   ^uint64(0),
+	}
+	inodes := w.block(w.inodes.Bytes())
+	dirs := w.block(w.dirs.Bytes())
+	var idData bytes.Buffer
+	binary.Write(&idData, binary.LittleEndian, w.ids)
+	ids := w.block(idData.Bytes())
+
+	sb.InodeTable = 96
+	sb.DirectoryTable = sb.InodeTable + uint64(len(inodes))
+	idBlock := sb.DirectoryTable + uint64(len(dirs))
+	sb.IDTable = idBlock + uint64(len(ids))
+	sb.BytesUsed = sb.IDTable + 8
+
+	var out bytes.Buffer
+	binary.Write(&out, binary.LittleEndian, sb)
+	out.Write(inodes)
+	out.Write(dirs)
+	out.Write(ids)
+	binary.Write(&out, binary.LittleEndian, idBlock)
+	return out.Bytes()
+}
+
+// buildSIF returns SIF image with the squashfs partition
+func buildSIF(image []byte) []byte {
+	header := make([]byte, 4096)
+	copy(header, "#!/usr/bin/env run-singularity\n")
+	copy(header[sifMagicOffset:], sifMagic)
+	return append(header, image...)
+}
+
+// sqfsTree defines the image with a file, a directory, a symlink and a hardlink
+var sqfsTree = sqfsEntry{children: []sqfsEntry{
+	{name: "bin", dir: true, mode: 0755, children: []sqfsEntry{
+		{name: "busybox", mode: 0755, size: 900000},
+		{name: "sh", link: "busybox"},
+		{name: "ls", target: "busybox", mode: 0777},
+	}},
+	{name: "etc", dir: true, mode: 0755, children: []sqfsEntry{
+		{name: "passwd", mode: 0644, size: 120, uid: 1000},
+	}},
+	{name: "empty", dir: true, mode: 0755},
+}}
+
+func TestReadSquashfs(t *testing.T) {
+	tests := []struct {
+		name  string
+		image []byte
+	}{
+		{"uncompressed metadata", buildSquashfs(sqfsTree, false)},
+		{"gzip metadata", buildSquashfs(sqfsTree, true)},
+		{"SIF image", buildSIF(buildSquashfs(sqfsTree, true))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			layer, err := ReadSquashfs(bytes.NewReader(tt.image))
+			if err != nil {
+				t.Fatalf("ReadSquashfs() error = %v", err)
+			}
+			files := make(map[string]*tar.Header)
+			for _, f := range layer.Files {
+				files[f.Name] = f
+			}
+			if layer.Count != 4 || len(files) != 4 {
+				t.Fatalf("files = %v, want 4", files)
+			}
+			if layer.Dirs != 3 {
+				t.Errorf("Dirs = %d, want 3", layer.Dirs)
+			}
+			if layer.Size != 900120 {
+				t.Errorf("Size = %d, want 900120", layer.Size)
+			}
+			if f := files["bin/busybox"]; f == nil || f.Typeflag != tar.TypeReg || f.Size != 900000 || f.Mode != 0755 {
+				t.Errorf("bin/busybox = %+v", f)
+			}
+			if f := files["bin/sh"]; f == nil || f.Typeflag != tar.TypeLink || f.Linkname != "bin/busybox" || f.Size != 0 {
+				t.Errorf("bin/sh = %+v, want hardlink to bin/busybox", f)
+			}
+			if f := files["bin/ls"]; f == nil || f.Typeflag != tar.TypeSymlink || f.Linkname != "busybox" {
+				t.Errorf("bin/ls = %+v, want symlink to busybox", f)
+			}
+			if f := files["etc/passwd"]; f == nil || f.Uid != 1000 || f.Gid != 1000 {
+				t.Errorf("etc/passwd = %+v, want owner 1000", f)
+			}
+		})
+	}
+}
+
+func TestReadSquashfsErrors(t *testing.T) {
+	version := buildSquashfs(sqfsTree, false)
+	binary.LittleEndian.PutUint16(version[28:], 3)
+	tests := []struct {
+		name  string
+		image []byte
+		want  error
+	}{
+		{"not squashfs", []byte("not an image at all, just text"), ErrUnsupportedFormat},
+		{"SIF without squashfs", buildSIF(nil), ErrUnsupportedFormat},
+		{"squashfs 3", version, ErrUnsupportedFormat},
+		{"truncated", buildSquashfs(sqfsTree, false)[:120], ErrCorruptArchive},
+		{"huge symlink", buildSquashfs(sqfsEntry{children: []sqfsEntry{
+			{name: "link", target: "x", linkSize: 0xffffffff},
+		}}, false), ErrCorruptArchive},
+		{"symlink out of table", buildSquashfs(sqfsEntry{children: []sqfsEntry{
+			{name: "link", target: "x", linkSize: 4000},
+		}}, false), ErrCorruptArchive},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReadSquashfs(bytes.NewReader(tt.image))
+			if !errors.Is(err, tt.want) {
+				t.Errorf("ReadSquashfs() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
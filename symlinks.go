@@ -0,0 +1,84 @@
+package dolay
+
+import (
+	"archive/tar"
+	"path"
+	"strings"
+)
+
+// maxLinkHops defines the most of symlinks followed while resolving
+// the path, like the limit of the kernel. Longer chains are loops
+const maxLinkHops = 40
+
+// BrokenLink defines symlink of the image filesystem
+// which target doesn't exist
+type BrokenLink struct {
+	Name   string `json:"name"`
+	Target string `json:"target"`
+}
+
+// BrokenLinks returns symlinks of the filesystem composed from layers,
+// which targets don't exist in it. Relative targets are resolved from
+// the directory of the link, and symlinks among parents of the target
+// are followed. Directories are known by paths of files, so link to
+// the directory without files is reported too
+func BrokenLinks(layers []*Layer) []BrokenLink {
+	files := Merge(layers)
+	present := make(map[string]bool, len(files))
+	links := make(map[string]string)
+	for _, f := range files {
+		name := cleanPath(f.Name)
+		present[name] = true
+		for dir := path.Dir(name); dir != "." && !present[dir]; dir = path.Dir(dir) {
+			present[dir] = true
+		}
+		if f.Typeflag == tar.TypeSymlink {
+			links[name] = f.Linkname
+		}
+	}
+
+	broken := []BrokenLink{}
+	for _, f := range files {
+		if f.Typeflag != tar.TypeSymlink {
+			continue
+		}
+		name := cleanPath(f.Name)
+		target, ok := resolveLink(links, linkTarget(name, f.Linkname), 0)
+		if !ok || (!present[target] && target != "") {
+			broken = append(broken, BrokenLink{Name: name, Target: f.Linkname})
+		}
+	}
+	return broken
+}
+
+// linkTarget returns path of the link target relative to the image root
+func linkTarget(name, target string) string {
+	if path.IsAbs(target) {
+		return cleanPath(target)
+	}
+	return cleanPath(path.Join(path.Dir(name), target))
+}
+
+// resolveLink returns path with symlinks replaced by their targets.
+// false is returned if there are more than maxLinkHops symlinks
+func resolveLink(links map[string]string, p string, hops int) (string, bool) {
+	if p == "" {
+		return p, true
+	}
+	parts := strings.Split(p, "/")
+	var cur string
+	for i, part := range parts {
+		next := path.Join(cur, part)
+		target, ok := links[next]
+		if !ok {
+			cur = next
+			continue
+		}
+		if hops++; hops > maxLinkHops {
+			return "", false
+		}
+		rest := append([]string{linkTarget(next, target)}, parts[i+1:]...)
+		return resolveLink(links, cleanPath(path.Join(rest...)), hops)
+	}
+	return cur, true
+}
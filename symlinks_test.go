@@ -0,0 +1,47 @@
+package dolay
+
+import (
+	"archive/tar"
+	"reflect"
+	"testing"
+)
+
+func TestBrokenLinks(t *testing.T) {
+	base := testLayer(0, "ADD rootfs",
+		regular("bin/busybox", 900),
+		regular("usr/lib/libc.so.6", 100),
+		link(tar.TypeSymlink, "bin/sh", "busybox"),
+		link(tar.TypeSymlink, "lib", "usr/lib"))
+	tests := []struct {
+		name    string
+		entries []tarEntry
+		want    []BrokenLink
+	}{
+		{"valid and dangling", []tarEntry{
+			link(tar.TypeSymlink, "usr/bin/vi", "/bin/busybox"),
+			link(tar.TypeSymlink, "usr/bin/python", "python3"),
+		}, []BrokenLink{{Name: "usr/bin/python", Target: "python3"}}},
+		{"relative to parent", []tarEntry{
+			link(tar.TypeSymlink, "usr/bin/libc", "../lib/libc.so.6"),
+			link(tar.TypeSymlink, "usr/bin/libm", "../lib/libm.so.6"),
+		}, []BrokenLink{{Name: "usr/bin/libm", Target: "../lib/libm.so.6"}}},
+		{"through linked directory", []tarEntry{
+			link(tar.TypeSymlink, "etc/libc", "/lib/libc.so.6"),
+		}, []BrokenLink{}},
+		{"directory", []tarEntry{link(tar.TypeSymlink, "opt/lib", "/usr/lib")}, []BrokenLink{}},
+		{"loop", []tarEntry{
+			link(tar.TypeSymlink, "a", "b"),
+			link(tar.TypeSymlink, "b", "a"),
+		}, []BrokenLink{{Name: "a", Target: "b"}, {Name: "b", Target: "a"}}},
+		{"deleted target", []tarEntry{regular("bin/.wh.busybox", 0)}, []BrokenLink{{Name: "bin/sh", Target: "busybox"}}},
+		{"root", []tarEntry{link(tar.TypeSymlink, "rootfs", "/")}, []BrokenLink{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := BrokenLinks([]*Layer{base, testLayer(1, "RUN ln", tt.entries...)})
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("BrokenLinks() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package dolay
+
+import (
+	"archive/tar"
+	"container/heap"
+)
+
+// topFiles defines heap of the largest files, which root is the file
+// going last in order of Files, so it's replaced by larger ones
+type topFiles struct {
+	Files
+}
+
+// Less provides reversed compare of files
+func (t *topFiles) Less(i, j int) bool {
+	return t.Files.Less(j, i)
+}
+
+// Push provides adding of the file to the heap
+func (t *topFiles) Push(x interface{}) {
+	t.Files = append(t.Files, x.(*tar.Header))
+}
+
+// Pop provides removing of the last file of the heap
+func (t *topFiles) Pop() interface{} {
+	last := t.Files[len(t.Files)-1]
+	t.Files = t.Files[:len(t.Files)-1]
+	return last
+}
+
+// add provides adding of the file, so at most n largest files are held
+func (t *topFiles) add(h *tar.Header, n int) {
+	if len(t.Files) < n {
+		heap.Push(t, h)
+		return
+	}
+	if !(Files{h, t.Files[0]}).Less(0, 1) {
+		return
+	}
+	t.Files[0] = h
+	heap.Fix(t, 0)
+}
@@ -0,0 +1,78 @@
+package dolay
+
+import (
+	"sort"
+	"strings"
+)
+
+// Node defines node of the files tree.
+// Size and Files of the directory are summed over its subtree
+type Node struct {
+	Name     string  `json:"name"`
+	Size     uint64  `json:"size"`
+	Files    int     `json:"files"`
+	Children []*Node `json:"children,omitempty"`
+}
+
+// IsDir returns true if node is a directory
+func (n *Node) IsDir() bool {
+	return len(n.Children) > 0
+}
+
+// NewTree returns tree of the files. Children of each
+// directory are sorted by size from the largest
+func NewTree(files Files) *Node {
+	root := &Node{}
+	index := map[string]*Node{"": root}
+	for _, f := range files {
+		parts := strings.Split(strings.Trim(f.Name, "/"), "/")
+		parent := root
+		var prefix string
+		for _, p := range parts {
+			prefix += "/" + p
+			node, ok := index[prefix]
+			if !ok {
+				node = &Node{Name: p}
+				index[prefix] = node
+				parent.Children = append(parent.Children, node)
+			}
+			node.Size += uint64(f.Size)
+			node.Files++
+			parent = node
+		}
+		root.Size += uint64(f.Size)
+		root.Files++
+	}
+	root.sort()
+	return root
+}
+
+func (n *Node) sort() {
+	sort.Slice(n.Children, func(i, j int) bool {
+		if n.Children[i].Size != n.Children[j].Size {
+			return n.Children[i].Size > n.Children[j].Size
+		}
+		return n.Children[i].Name < n.Children[j].Name
+	})
+	for _, c := range n.Children {
+		c.sort()
+	}
+}
+
+// Collapse provides merging of directories, which contain
+// the single child, with the child (like "usr/lib")
+func (n *Node) Collapse() *Node {
+	for i, c := range n.Children {
+		for len(c.Children) == 1 {
+			child := c.Children[0]
+			c = &Node{
+				Name:     c.Name + "/" + child.Name,
+				Size:     c.Size,
+				Files:    c.Files,
+				Children: child.Children,
+			}
+		}
+		n.Children[i] = c.Collapse()
+	}
+	return n
+}
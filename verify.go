@@ -0,0 +1,52 @@
+package dolay
+
+import (
+	"encoding/hex"
+	"hash"
+	"io"
+)
+
+// DigestCheck defines result of the verification of the layer digest
+type DigestCheck struct {
+	Index int    `json:"index"`
+	Path  string `json:"path"`
+	// Expected is digest of the blob path, or the diff ID of the
+	// config for paths without digest. It's empty if it's unknown
+	Expected string `json:"expected,omitempty"`
+	Actual   string `json:"actual"`
+}
+
+// OK returns true if the computed digest matches the expected one
+func (c DigestCheck) OK() bool {
+	return c.Expected == "" || c.Expected == c.Actual
+}
+
+// blobDigest returns digest of the entry read through the hash.
+// Rest of the entry is read, since the layer reading stops at
+// the end of its tar archive
+func blobDigest(r io.Reader, sum hash.Hash) (string, error) {
+	if _, err := io.Copy(io.Discard, r); err != nil {
+		return "", err
+	}
+	return "sha256:" + hex.EncodeToString(sum.Sum(nil)), nil
+}
+
+// CheckDigests returns results of comparison of digests of layers read
+// with Options.VerifyDigests with their expected digests. Digests of
+// blobs are compared with their paths, which are digests of the stored
+// (compressed) content, and layers of the legacy docker archive are
+// compared with diff IDs of the config, since they are not compressed
+func CheckDigests(layers []*Layer, diffIDs []string) []DigestCheck {
+	checks := make([]DigestCheck, 0, len(layers))
+	for _, l := range layers {
+		c := DigestCheck{Index: l.Index, Path: l.Path, Actual: l.BlobDigest}
+		switch {
+		case isBlob(l.Path):
+			c.Expected = Digest(l.Path)
+		case l.Compression == "" && l.Index < len(diffIDs):
+			c.Expected = diffIDs[l.Index]
+		}
+		checks = append(checks, c)
+	}
+	return checks
+}
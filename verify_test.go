@@ -0,0 +1,57 @@
+package dolay
+
+import (
+	"bytes"
+	"testing"
+)
+
+// tampered returns copy of the layer with a changed byte of the content
+// of its first file, so it's still a valid tar of the same size
+func tampered(layer []byte) []byte {
+	data := append([]byte(nil), layer...)
+	data[512] ^= 1
+	return data
+}
+
+func TestCheckDigests(t *testing.T) {
+	good := buildTar(t, regular("bin/busybox", 900))
+	bad := buildTar(t, regular("app/main", 300))
+	img := testImage{Layers: [][]byte{good, bad}}
+	// the config keeps diff IDs of the original layers
+	forged := testImage{Layers: [][]byte{good, tampered(bad)}, Config: img.config(t)}
+	gzipped := testImage{Layers: [][]byte{gzipData(t, good)}}
+	tests := []struct {
+		name    string
+		archive []byte
+		blobs   [][]byte
+		ok      []bool
+		known   []bool
+	}{
+		{"docker", img.docker(t), img.Layers, []bool{true, true}, []bool{true, true}},
+		{"docker tampered", forged.docker(t), forged.Layers, []bool{true, false}, []bool{true, true}},
+		{"oci", img.oci(t), img.Layers, []bool{true, true}, []bool{true, true}},
+		{"oci tampered", bytes.Replace(img.oci(t), bad, tampered(bad), 1), forged.Layers, []bool{true, false}, []bool{true, true}},
+		// diff IDs are digests of uncompressed layers
+		{"docker gzip", gzipped.docker(t), gzipped.Layers, []bool{true}, []bool{false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := AnalyzeWithOptions(bytes.NewReader(tt.archive), Options{VerifyDigests: true})
+			if err != nil {
+				t.Fatalf("AnalyzeWithOptions() error = %v", err)
+			}
+			checks := CheckDigests(report.Layers, report.Image.RootFS.DiffIDs)
+			if len(checks) != len(tt.ok) {
+				t.Fatalf("CheckDigests() = %d checks, want %d", len(checks), len(tt.ok))
+			}
+			for i, c := range checks {
+				if c.OK() != tt.ok[i] || (c.Expected != "") != tt.known[i] || c.Index != i {
+					t.Errorf("check %d = %+v, want ok %v, known %v", i, c, tt.ok[i], tt.known[i])
+				}
+				if want := "sha256:" + sha256Hex(tt.blobs[i]); c.Actual != want {
+					t.Errorf("check %d actual = %s, want %s", i, c.Actual, want)
+				}
+			}
+		})
+	}
+}
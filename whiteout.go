@@ -0,0 +1,28 @@
+package dolay
+
+import (
+	"path"
+	"strings"
+)
+
+const (
+	whiteoutPrefix = ".wh."
+	whiteoutOpaque = ".wh..wh..opq"
+)
+
+// IsWhiteout returns true if entry marks deletion
+// of the file from the lower layers
+func IsWhiteout(name string) bool {
+	return strings.HasPrefix(path.Base(name), whiteoutPrefix)
+}
+
+// WhiteoutTarget returns path which is deleted by the whiteout entry.
+// For opaque marker it's the directory, whose content from
+// lower layers is hidden, and opaque is true
+func WhiteoutTarget(name string) (target string, opaque bool) {
+	dir, base := path.Split(name)
+	if base == whiteoutOpaque {
+		return path.Clean(dir), true
+	}
+	return dir + strings.TrimPrefix(base, whiteoutPrefix), false
+}
@@ -0,0 +1,58 @@
+package dolay
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestWhiteoutTarget(t *testing.T) {
+	tests := []struct {
+		name     string
+		whiteout bool
+		target   string
+		opaque   bool
+	}{
+		{"etc/.wh.passwd", true, "etc/passwd", false},
+		{".wh.app", true, "app", false},
+		{"var/cache/.wh..wh..opq", true, "var/cache", true},
+		{"etc/passwd", false, "", false},
+		{"etc/.whitelist", false, "", false},
+		{".wh.dir/file", false, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsWhiteout(tt.name); got != tt.whiteout {
+				t.Fatalf("IsWhiteout(%q) = %v, want %v", tt.name, got, tt.whiteout)
+			}
+			if !tt.whiteout {
+				return
+			}
+			target, opaque := WhiteoutTarget(tt.name)
+			if target != tt.target || opaque != tt.opaque {
+				t.Errorf("WhiteoutTarget(%q) = %q, %v, want %q, %v", tt.name, target, opaque, tt.target, tt.opaque)
+			}
+		})
+	}
+}
+
+func TestReadLayerWhiteouts(t *testing.T) {
+	layer, err := ReadLayer(bytes.NewReader(buildTar(t,
+		regular("etc/passwd", 100),
+		regular("etc/.wh.shadow", 0),
+		directory("var/cache/"),
+		regular("var/cache/.wh..wh..opq", 0),
+	)))
+	if err != nil {
+		t.Fatalf("ReadLayer() error = %v", err)
+	}
+	if got := names(layer.Files); !reflect.DeepEqual(got, []string{"etc/passwd"}) {
+		t.Errorf("ReadLayer() files = %v, want only etc/passwd", got)
+	}
+	if got, want := names(layer.Whiteouts), []string{"etc/.wh.shadow", "var/cache/.wh..wh..opq"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadLayer() whiteouts = %v, want %v", got, want)
+	}
+	if layer.Count != 1 || layer.Size != 100 {
+		t.Errorf("ReadLayer() count = %d, size = %d, want 1 and 100", layer.Count, layer.Size)
+	}
+}
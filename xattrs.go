@@ -0,0 +1,129 @@
+package dolay
+
+import (
+	"archive/tar"
+	"encoding/binary"
+	"encoding/hex"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// xattrPrefix defines prefix of PAX records with extended attributes
+const xattrPrefix = "SCHILY.xattr."
+
+// capabilityXattr defines extended attribute with file capabilities
+const capabilityXattr = "security.capability"
+
+// Xattrs returns extended attributes of the file by names. Capabilities
+// are decoded like "cap_net_bind_service=ep", printable values are
+// returned as is and other values as hex
+func Xattrs(h *tar.Header) map[string]string {
+	var attrs map[string]string
+	for key, value := range h.PAXRecords {
+		if !strings.HasPrefix(key, xattrPrefix) {
+			continue
+		}
+		if attrs == nil {
+			attrs = make(map[string]string)
+		}
+		name := strings.TrimPrefix(key, xattrPrefix)
+		attrs[name] = xattrValue(name, value)
+	}
+	return attrs
+}
+
+// xattrValue returns readable value of the extended attribute
+func xattrValue(name, value string) string {
+	if name == capabilityXattr {
+		if caps, ok := Capabilities([]byte(value)); ok {
+			return caps
+		}
+	}
+	if utf8.ValidString(value) && strings.IndexFunc(value, func(r rune) bool { return !unicode.IsPrint(r) }) < 0 {
+		return value
+	}
+	return "0x" + hex.EncodeToString([]byte(value))
+}
+
+// capabilityNames contains names of linux capabilities by numbers
+var capabilityNames = []string{
+	"chown", "dac_override", "dac_read_search", "fowner", "fsetid", "kill",
+	"setgid", "setuid", "setpcap", "linux_immutable", "net_bind_service",
+	"net_broadcast", "net_admin", "net_raw", "ipc_lock", "ipc_owner",
+	"sys_module", "sys_rawio", "sys_chroot", "sys_ptrace", "sys_pacct",
+	"sys_admin", "sys_boot", "sys_nice", "sys_resource", "sys_time",
+	"sys_tty_config", "mknod", "lease", "audit_write", "audit_control",
+	"setfcap", "mac_override", "mac_admin", "syslog", "wake_alarm",
+	"block_suspend", "audit_read", "perfmon", "bpf", "checkpoint_restore",
+}
+
+// Revisions and flags of vfs_cap_data
+const (
+	capRevisionMask = 0xff000000
+	capRevision1    = 0x01000000
+	capRevision2    = 0x02000000
+	capRevision3    = 0x03000000
+	capEffective    = 0x000001
+)
+
+// Capabilities returns file capabilities from the value of the
+// security.capability attribute in the form of getcap, like
+// "cap_net_admin,cap_net_raw=ep". false is returned if the value
+// isn't valid vfs_cap_data
+func Capabilities(data []byte) (string, bool) {
+	if len(data) < 4 {
+		return "", false
+	}
+	magic := binary.LittleEndian.Uint32(data)
+	words := 2
+	switch magic & capRevisionMask {
+	case capRevision1:
+		words = 1
+		if len(data) < 12 {
+			return "", false
+		}
+	case capRevision2, capRevision3:
+		if len(data) < 20 {
+			return "", false
+		}
+	default:
+		return "", false
+	}
+	var permitted, inheritable uint64
+	for i := 0; i < words; i++ {
+		permitted |= uint64(binary.LittleEndian.Uint32(data[4+8*i:])) << (32 * i)
+		inheritable |= uint64(binary.LittleEndian.Uint32(data[8+8*i:])) << (32 * i)
+	}
+
+	// capabilities are grouped by flags like "cap_a,cap_b=ep cap_c=i"
+	groups := make(map[string][]string)
+	for bit := 0; bit < 64; bit++ {
+		var flags string
+		if magic&capEffective != 0 && (permitted|inheritable)&(1<<bit) != 0 {
+			flags += "e"
+		}
+		if inheritable&(1<<bit) != 0 {
+			flags += "i"
+		}
+		if permitted&(1<<bit) != 0 {
+			flags += "p"
+		}
+		if flags == "" {
+			continue
+		}
+		name := "cap_" + strconv.Itoa(bit)
+		if bit < len(capabilityNames) {
+			name = "cap_" + capabilityNames[bit]
+		}
+		groups[flags] = append(groups[flags], name)
+	}
+	var parts []string
+	for flags, names := range groups {
+		parts = append(parts, strings.Join(names, ",")+"="+flags)
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, " "), true
+}